@@ -12,23 +12,26 @@ import (
 )
 
 type ApificationBreeze struct {
-	Breeze             *BreezeConnect
+	Breeze             *BreezeInstance
 	Hostname           string
 	Base64SessionToken string
+	HistV2Hostname     string
+	HistV2Parallelism  int
 }
 
-func NewApificationBreeze(breezeInstance *BreezeConnect) *ApificationBreeze {
+func NewApificationBreeze(breezeInstance *BreezeInstance) *ApificationBreeze {
 	base64SessionToken := base64.StdEncoding.EncodeToString([]byte(breezeInstance.UserID + ":" + breezeInstance.SessionKey))
 	return &ApificationBreeze{
 		Breeze:             breezeInstance,
 		Hostname:           breezeInstance.APIURL,
 		Base64SessionToken: base64SessionToken,
+		HistV2Hostname:     BREEZE_NEW_URL,
+		HistV2Parallelism:  defaultHistV2Parallelism,
 	}
 }
 
-func (a *ApificationBreeze) ErrorException(funcName string, err error) {
-	message := fmt.Sprintf("%s() Error: %s", funcName, err)
-	panic(message)
+func (a *ApificationBreeze) ErrorException(funcName string, err error) error {
+	return fmt.Errorf("%s() Error: %w", funcName, err)
 }
 
 func (a *ApificationBreeze) ValidationErrorResponse(message string) map[string]interface{} {
@@ -75,25 +78,16 @@ func (a *ApificationBreeze) MakeRequest(method, endpoint, body string, headers m
 
 func (a *ApificationBreeze) GetCustomerDetails(apiSession string) (map[string]interface{}, error) {
 	if apiSession == "" {
-		return a.ValidationErrorResponse("API session is missing"), nil
+		return nil, NewValidationError("API session is missing")
 	}
 
-	headers := map[string]string{
-		"Content-Type": "application/json",
-	}
-	body := map[string]string{
-		"SessionToken": apiSession,
-		"AppKey":       a.Breeze.APIKey,
-	}
-	bodyJSON, _ := json.Marshal(body)
-	response, err := a.MakeRequest("GET", "/cust_details", string(bodyJSON), headers)
+	response, err := a.customerDetailsRequest(apiSession)
 	if err != nil {
 		return nil, err
 	}
 	defer response.Body.Close()
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
+	result, err := a.parseResponseBody(response)
 	if err != nil {
 		return nil, err
 	}
@@ -103,146 +97,269 @@ func (a *ApificationBreeze) GetCustomerDetails(apiSession string) (map[string]in
 	return result, nil
 }
 
-func (a *ApificationBreeze) GetDematHoldings() (map[string]interface{}, error) {
-	body := "{}"
-	headers, err := a.GenerateHeaders(body)
-	if err != nil {
-		return nil, err
+func (a *ApificationBreeze) GetCustomerDetailsTyped(apiSession string) (*CustomerDetails, error) {
+	if apiSession == "" {
+		return nil, NewValidationError("API session is missing")
 	}
 
-	response, err := a.MakeRequest("GET", "/demat_holdings", body, headers)
+	response, err := a.customerDetailsRequest(apiSession)
 	if err != nil {
 		return nil, err
 	}
 	defer response.Body.Close()
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
-	if err != nil {
-		return nil, err
+	return decodeResponse[*CustomerDetails](response, a.Breeze.ExceptMessage)
+}
+
+func (a *ApificationBreeze) customerDetailsRequest(apiSession string) (*http.Response, error) {
+	headers := map[string]string{
+		"Content-Type": "application/json",
 	}
-	return result, nil
+	body := map[string]string{
+		"SessionToken": apiSession,
+		"AppKey":       a.Breeze.APIKey,
+	}
+	bodyJSON, _ := json.Marshal(body)
+	return a.MakeRequest("GET", "/cust_details", string(bodyJSON), headers)
+}
+
+func (a *ApificationBreeze) GetDematHoldings() (map[string]interface{}, error) {
+	return a.requestMap("GET", "/demat_holdings", map[string]string{})
+}
+
+func (a *ApificationBreeze) GetDematHoldingsTyped() ([]DematHolding, error) {
+	return callAPI[[]DematHolding](a, "GET", "/demat_holdings", map[string]string{})
 }
 
 func (a *ApificationBreeze) GetFunds() (map[string]interface{}, error) {
-	body := "{}"
-	headers, err := a.GenerateHeaders(body)
-	if err != nil {
-		return nil, err
+	return a.requestMap("GET", "/funds", map[string]string{})
+}
+
+func (a *ApificationBreeze) GetFundsTyped() (*Funds, error) {
+	return callAPI[*Funds](a, "GET", "/funds", map[string]string{})
+}
+
+func (a *ApificationBreeze) SetFunds(transactionType, amount, segment string) (map[string]interface{}, error) {
+	if transactionType == "" || amount == "" || segment == "" {
+		return nil, NewValidationError("Transaction type, amount or segment cannot be empty")
 	}
+	return a.requestMap("POST", "/funds", FundsRequest{TransactionType: transactionType, Amount: amount, Segment: segment})
+}
 
-	response, err := a.MakeRequest("GET", "/funds", body, headers)
-	if err != nil {
-		return nil, err
+func (a *ApificationBreeze) SetFundsTyped(req FundsRequest) (*StatusResult, error) {
+	if req.TransactionType == "" || req.Amount == "" || req.Segment == "" {
+		return nil, NewValidationError("Transaction type, amount or segment cannot be empty")
 	}
-	defer response.Body.Close()
+	return callAPI[*StatusResult](a, "POST", "/funds", req)
+}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
-	if err != nil {
-		return nil, err
+func (a *ApificationBreeze) GetHistoricalData(interval, fromDate, toDate, stockCode, exchangeCode, productType, expiryDate, right, strikePrice string) (map[string]interface{}, error) {
+	req := HistoricalDataRequest{
+		Interval:     interval,
+		FromDate:     fromDate,
+		ToDate:       toDate,
+		StockCode:    stockCode,
+		ExchangeCode: exchangeCode,
+		ProductType:  productType,
+		ExpiryDate:   expiryDate,
+		Right:        right,
+		StrikePrice:  strikePrice,
+	}
+	if req.Interval == "" || req.FromDate == "" || req.ToDate == "" || req.StockCode == "" || req.ExchangeCode == "" {
+		return nil, NewValidationError("Required parameters are missing")
+	}
+	return a.requestMap("GET", "/hist_chart", req)
+}
+
+func (a *ApificationBreeze) GetHistoricalDataTyped(req HistoricalDataRequest) ([]Candle, error) {
+	if req.Interval == "" || req.FromDate == "" || req.ToDate == "" || req.StockCode == "" || req.ExchangeCode == "" {
+		return nil, NewValidationError("Required parameters are missing")
 	}
-	return result, nil
+	return callAPI[[]Candle](a, "GET", "/hist_chart", req)
 }
 
-func (a *ApificationBreeze) SetFunds(transactionType, amount, segment string) (map[string]interface{}, error) {
-	if transactionType == "" || amount == "" || segment == "" {
-		return a.ValidationErrorResponse("Transaction type, amount or segment cannot be empty"), nil
+func (a *ApificationBreeze) GetOrderDetail(exchangeCode, orderID string) (map[string]interface{}, error) {
+	if exchangeCode == "" || orderID == "" {
+		return nil, NewValidationError("Exchange code or order ID cannot be empty")
 	}
+	return a.requestMap("GET", "/"+string(ORDER), orderIDBody(exchangeCode, orderID))
+}
 
-	body := map[string]string{
-		"transaction_type": transactionType,
-		"amount":           amount,
-		"segment":          segment,
+func (a *ApificationBreeze) GetOrderDetailTyped(exchangeCode, orderID string) ([]OrderDetail, error) {
+	if exchangeCode == "" || orderID == "" {
+		return nil, NewValidationError("Exchange code or order ID cannot be empty")
 	}
-	bodyJSON, _ := json.Marshal(body)
-	headers, err := a.GenerateHeaders(string(bodyJSON))
-	if err != nil {
+	return callAPI[[]OrderDetail](a, "GET", "/"+string(ORDER), orderIDBody(exchangeCode, orderID))
+}
+
+func (a *ApificationBreeze) GetOrderList(exchangeCode, fromDate, toDate string) (map[string]interface{}, error) {
+	if err := validateOrderList(exchangeCode, fromDate, toDate); err != nil {
 		return nil, err
 	}
+	return a.requestMap("GET", "/"+string(ORDER), orderListBody(exchangeCode, fromDate, toDate))
+}
 
-	response, err := a.MakeRequest("POST", "/funds", string(bodyJSON), headers)
-	if err != nil {
+func (a *ApificationBreeze) GetOrderListTyped(exchangeCode, fromDate, toDate string) ([]OrderDetail, error) {
+	if err := validateOrderList(exchangeCode, fromDate, toDate); err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
+	return callAPI[[]OrderDetail](a, "GET", "/"+string(ORDER), orderListBody(exchangeCode, fromDate, toDate))
+}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
-	if err != nil {
+func (a *ApificationBreeze) PlaceOrder(stockCode, exchangeCode, product, action, orderType, stoploss, quantity, price, validity, validityDate, disclosedQuantity, expiryDate, right, strikePrice, userRemark string) (map[string]interface{}, error) {
+	req := OrderRequest{
+		StockCode:         stockCode,
+		ExchangeCode:      exchangeCode,
+		Product:           product,
+		Action:            action,
+		OrderType:         orderType,
+		Quantity:          quantity,
+		Price:             price,
+		Validity:          validity,
+		Stoploss:          stoploss,
+		ValidityDate:      validityDate,
+		DisclosedQuantity: disclosedQuantity,
+		ExpiryDate:        expiryDate,
+		Right:             right,
+		StrikePrice:       strikePrice,
+		UserRemark:        userRemark,
+	}
+	if err := req.validate(); err != nil {
 		return nil, err
 	}
-	return result, nil
+	return a.requestMap("POST", "/"+string(ORDER), req)
 }
 
-func (a *ApificationBreeze) GetHistoricalData(interval, fromDate, toDate, stockCode, exchangeCode, productType, expiryDate, right, strikePrice string) (map[string]interface{}, error) {
-	if interval == "" || fromDate == "" || toDate == "" || stockCode == "" || exchangeCode == "" {
-		return a.ValidationErrorResponse("Required parameters are missing"), nil
+func (a *ApificationBreeze) PlaceOrderTyped(req OrderRequest) (*OrderAck, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
 	}
+	return callAPI[*OrderAck](a, "POST", "/"+string(ORDER), req)
+}
 
-	body := map[string]string{
-		"interval":      interval,
-		"from_date":     fromDate,
-		"to_date":       toDate,
-		"stock_code":    stockCode,
-		"exchange_code": exchangeCode,
-		"product_type":  productType,
-		"expiry_date":   expiryDate,
-		"right":         right,
-		"strike_price":  strikePrice,
-	}
-	bodyJSON, _ := json.Marshal(body)
-	headers, err := a.GenerateHeaders(string(bodyJSON))
-	if err != nil {
+func (a *ApificationBreeze) ModifyOrder(orderID, exchangeCode, orderType, stoploss, quantity, price, validity, disclosedQuantity, validityDate string) (map[string]interface{}, error) {
+	req := ModifyOrderRequest{
+		OrderID:           orderID,
+		ExchangeCode:      exchangeCode,
+		OrderType:         orderType,
+		Stoploss:          stoploss,
+		Quantity:          quantity,
+		Price:             price,
+		Validity:          validity,
+		DisclosedQuantity: disclosedQuantity,
+		ValidityDate:      validityDate,
+	}
+	if err := req.validate(); err != nil {
 		return nil, err
 	}
+	return a.requestMap("PUT", "/"+string(ORDER), req)
+}
 
-	response, err := a.MakeRequest("GET", "/hist_chart", string(bodyJSON), headers)
-	if err != nil {
+func (a *ApificationBreeze) ModifyOrderTyped(req ModifyOrderRequest) (*OrderAck, error) {
+	if err := req.validate(); err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
+	return callAPI[*OrderAck](a, "PUT", "/"+string(ORDER), req)
+}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
-	if err != nil {
-		return nil, err
+func (a *ApificationBreeze) CancelOrder(exchangeCode, orderID string) (map[string]interface{}, error) {
+	if exchangeCode == "" || orderID == "" {
+		return nil, NewValidationError("Exchange code or order ID cannot be empty")
 	}
-	return result, nil
+	return a.requestMap("DELETE", "/"+string(ORDER), orderIDBody(exchangeCode, orderID))
 }
 
-func (a *ApificationBreeze) GetOrderDetail(exchangeCode, orderID string) (map[string]interface{}, error) {
+func (a *ApificationBreeze) CancelOrderTyped(exchangeCode, orderID string) (*OrderAck, error) {
 	if exchangeCode == "" || orderID == "" {
-		return a.ValidationErrorResponse("Exchange code or order ID cannot be empty"), nil
+		return nil, NewValidationError("Exchange code or order ID cannot be empty")
 	}
+	return callAPI[*OrderAck](a, "DELETE", "/"+string(ORDER), orderIDBody(exchangeCode, orderID))
+}
 
-	body := map[string]string{
+func orderIDBody(exchangeCode, orderID string) map[string]string {
+	return map[string]string{
 		"exchange_code": exchangeCode,
 		"order_id":      orderID,
 	}
-	bodyJSON, _ := json.Marshal(body)
-	headers, err := a.GenerateHeaders(string(bodyJSON))
+}
+
+func validateOrderList(exchangeCode, fromDate, toDate string) error {
+	if exchangeCode == "" {
+		return &ValidationError{Message: "Exchange code cannot be empty"}
+	}
+	if fromDate == "" || toDate == "" {
+		return &ValidationError{Message: "From date or to date cannot be empty"}
+	}
+	return nil
+}
+
+func orderListBody(exchangeCode, fromDate, toDate string) map[string]string {
+	return map[string]string{
+		"exchange_code": exchangeCode,
+		"from_date":     fromDate,
+		"to_date":       toDate,
+	}
+}
+
+// Add other methods as needed...
+
+// Helper functions
+
+// signedRequest serialises body, signs it through GenerateHeaders and sends it.
+func (a *ApificationBreeze) signedRequest(method, endpoint string, body interface{}) (*http.Response, error) {
+	bodyJSON, err := a.convertToJSON(body)
 	if err != nil {
 		return nil, err
 	}
+	headers, err := a.GenerateHeaders(bodyJSON)
+	if err != nil {
+		return nil, err
+	}
+	return a.MakeRequest(method, endpoint, bodyJSON, headers)
+}
 
-	response, err := a.MakeRequest("GET", "/order", string(bodyJSON), headers)
+func (a *ApificationBreeze) requestMap(method, endpoint string, body interface{}) (map[string]interface{}, error) {
+	response, err := a.signedRequest(method, endpoint, body)
 	if err != nil {
 		return nil, err
 	}
 	defer response.Body.Close()
+	return a.parseResponseBody(response)
+}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
+// callAPI sends a signed request and unwraps the Success payload of the
+// response envelope into T.
+func callAPI[T any](a *ApificationBreeze, method, endpoint string, body interface{}) (T, error) {
+	response, err := a.signedRequest(method, endpoint, body)
 	if err != nil {
-		return nil, err
+		var zero T
+		return zero, err
 	}
-	return result, nil
+	defer response.Body.Close()
+	return decodeResponse[T](response, a.Breeze.ExceptMessage)
 }
 
-// Add other methods as needed...
+// decodeResponse reads the envelope and turns a failed Status into an
+// APIError, AuthError or SessionExpiredError carrying the raw body.
+func decodeResponse[T any](response *http.Response, exceptMessage map[string]string) (T, error) {
+	var zero T
+	var envelope Response[T]
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return zero, err
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		if response.StatusCode != http.StatusOK {
+			return zero, newAPIError(response.StatusCode, http.StatusText(response.StatusCode), data, exceptMessage)
+		}
+		return zero, err
+	}
+	if envelope.Status != http.StatusOK {
+		return zero, newAPIError(envelope.Status, envelope.Error, data, exceptMessage)
+	}
+	return envelope.Success, nil
+}
 
-// Helper functions
 func (a *ApificationBreeze) convertToJSON(v interface{}) (string, error) {
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -260,6 +377,9 @@ func (a *ApificationBreeze) parseResponseBody(body *http.Response) (map[string]i
 	var result map[string]interface{}
 	err = json.Unmarshal(data, &result)
 	if err != nil {
+		if body.StatusCode != http.StatusOK {
+			return nil, newAPIError(body.StatusCode, http.StatusText(body.StatusCode), data, a.Breeze.ExceptMessage)
+		}
 		return nil, err
 	}
 	return result, nil
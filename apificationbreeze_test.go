@@ -0,0 +1,375 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// apiCall is one request received by fakeBreezeAPI.
+type apiCall struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   map[string]interface{}
+	Raw    string
+}
+
+// fakeBreezeAPI stands in for the Breeze REST API. It records every
+// request and answers each with the reply routed to its method and path,
+// or with Status and Body.
+type fakeBreezeAPI struct {
+	Status int
+	Body   string
+
+	mu     sync.Mutex
+	calls  []apiCall
+	routes map[string]apiReply
+	srv    *httptest.Server
+}
+
+type apiReply struct {
+	Status int
+	Body   string
+}
+
+func newFakeBreezeAPI(t *testing.T) (*fakeBreezeAPI, *ApificationBreeze) {
+	t.Helper()
+	f := &fakeBreezeAPI{Status: http.StatusOK, Body: `{"Success":null,"Status":200,"Error":null}`}
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		call := apiCall{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Raw: string(raw)}
+		json.Unmarshal(raw, &call.Body)
+		f.mu.Lock()
+		f.calls = append(f.calls, call)
+		status, body := f.Status, f.Body
+		if reply, ok := f.routes[r.Method+" "+r.URL.Path]; ok {
+			status, body = reply.Status, reply.Body
+		}
+		f.mu.Unlock()
+		if status == 0 {
+			// No status: drop the connection without answering.
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(f.srv.Close)
+
+	b := NewBreezeInstance("app-key")
+	b.UserID = "user"
+	b.SessionKey = "session"
+	b.SecretKey = "secret"
+	b.APIURL = f.srv.URL
+	a := NewApificationBreeze(b)
+	b.APIHandler = a
+	return f, a
+}
+
+func (f *fakeBreezeAPI) respond(status int, body string) {
+	f.mu.Lock()
+	f.Status, f.Body = status, body
+	f.mu.Unlock()
+}
+
+// route answers requests for route, such as "GET /funds", with status and
+// body instead of the default reply. A zero status drops the connection.
+func (f *fakeBreezeAPI) route(route string, status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.routes == nil {
+		f.routes = map[string]apiReply{}
+	}
+	f.routes[route] = apiReply{Status: status, Body: body}
+}
+
+func (f *fakeBreezeAPI) received() []apiCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]apiCall(nil), f.calls...)
+}
+
+func (f *fakeBreezeAPI) last(t *testing.T) apiCall {
+	t.Helper()
+	calls := f.received()
+	if len(calls) == 0 {
+		t.Fatal("no request reached the API")
+	}
+	return calls[len(calls)-1]
+}
+
+// checkSigned verifies the headers GenerateHeaders puts on every request.
+func checkSigned(t *testing.T, call apiCall) {
+	t.Helper()
+	timestamp := call.Header.Get("X-Timestamp")
+	want := fmt.Sprintf("token %x", sha256.Sum256([]byte(timestamp+call.Raw+"secret")))
+	if got := call.Header.Get("X-Checksum"); got != want {
+		t.Errorf("X-Checksum = %q, want %q", got, want)
+	}
+	if got := call.Header.Get("X-AppKey"); got != "app-key" {
+		t.Errorf("X-AppKey = %q", got)
+	}
+	session := base64.StdEncoding.EncodeToString([]byte("user:session"))
+	if got := call.Header.Get("X-SessionToken"); got != session {
+		t.Errorf("X-SessionToken = %q, want %q", got, session)
+	}
+}
+
+func checkBody(t *testing.T, call apiCall, want map[string]string) {
+	t.Helper()
+	for key, value := range want {
+		if got := call.Body[key]; got != value {
+			t.Errorf("%s %s: body[%q] = %v, want %q", call.Method, call.Path, key, got, value)
+		}
+	}
+}
+
+func TestPlaceOrderTyped(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	f.respond(http.StatusOK, `{"Success":{"order_id":"20230620N100012345","message":"Successfully Placed the order"},"Status":200,"Error":null}`)
+
+	ack, err := a.PlaceOrderTyped(OrderRequest{
+		StockCode:    "NIFTY",
+		ExchangeCode: "NFO",
+		Product:      "Options",
+		Action:       "BUY",
+		OrderType:    "Limit",
+		Quantity:     "50",
+		Price:        "112.5",
+		Validity:     "Day",
+		ExpiryDate:   "2023-07-27T06:00:00.000Z",
+		Right:        "Call",
+		StrikePrice:  "19500",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.OrderID != "20230620N100012345" {
+		t.Errorf("OrderID = %q", ack.OrderID)
+	}
+
+	call := f.last(t)
+	if call.Method != "POST" || call.Path != "/order" {
+		t.Errorf("request = %s %s, want POST /order", call.Method, call.Path)
+	}
+	checkSigned(t, call)
+	checkBody(t, call, map[string]string{
+		"stock_code":   "NIFTY",
+		"product":      "options",
+		"action":       "buy",
+		"order_type":   "limit",
+		"validity":     "day",
+		"right":        "call",
+		"strike_price": "19500",
+	})
+}
+
+func TestPlaceOrderReturnsEnvelope(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	f.respond(http.StatusOK, `{"Success":{"order_id":"1"},"Status":200,"Error":null}`)
+
+	result, err := a.PlaceOrder("ITC", "NSE", "cash", "sell", "market", "", "10", "", "day", "", "", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if success, _ := result["Success"].(map[string]interface{}); success["order_id"] != "1" {
+		t.Errorf("result = %v", result)
+	}
+	checkSigned(t, f.last(t))
+}
+
+func TestPlaceOrderTypedValidation(t *testing.T) {
+	valid := OrderRequest{
+		StockCode:    "ITC",
+		ExchangeCode: "NSE",
+		Product:      "cash",
+		Action:       "buy",
+		OrderType:    "limit",
+		Quantity:     "1",
+		Price:        "420",
+	}
+	tests := []struct {
+		name   string
+		change func(r *OrderRequest)
+	}{
+		{"missing stock code", func(r *OrderRequest) { r.StockCode = "" }},
+		{"missing quantity", func(r *OrderRequest) { r.Quantity = "" }},
+		{"unknown product", func(r *OrderRequest) { r.Product = "delivery" }},
+		{"unknown action", func(r *OrderRequest) { r.Action = "short" }},
+		{"unknown order type", func(r *OrderRequest) { r.OrderType = "bracket" }},
+		{"unknown validity", func(r *OrderRequest) { r.Validity = "gtc" }},
+		{"limit without price", func(r *OrderRequest) { r.Price = "" }},
+		{"stoploss without trigger", func(r *OrderRequest) { r.OrderType = "stoploss" }},
+		{"futures without expiry", func(r *OrderRequest) { r.Product = "futures" }},
+		{"options without right", func(r *OrderRequest) {
+			r.Product, r.ExpiryDate, r.StrikePrice = "options", "27-Jul-2023", "19500"
+		}},
+		{"options without strike", func(r *OrderRequest) {
+			r.Product, r.ExpiryDate, r.Right = "options", "27-Jul-2023", "put"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, a := newFakeBreezeAPI(t)
+			req := valid
+			tt.change(&req)
+			if _, err := a.PlaceOrderTyped(req); !errors.Is(err, ErrValidation) {
+				t.Errorf("PlaceOrderTyped error = %v, want ErrValidation", err)
+			}
+			if calls := f.received(); len(calls) != 0 {
+				t.Errorf("invalid order reached the API: %v", calls)
+			}
+		})
+	}
+}
+
+func TestValidationErrorResponse(t *testing.T) {
+	a := NewApificationBreeze(&BreezeInstance{})
+	got := a.ValidationErrorResponse("Stock code cannot be empty")
+	if got["Success"] != "" || got["Status"] != 500 || got["Error"] != "Stock code cannot be empty" {
+		t.Errorf("ValidationErrorResponse = %v", got)
+	}
+	if err := NewValidationError("Stock code cannot be empty"); !errors.Is(err, ErrValidation) || err.Error() != "Stock code cannot be empty" {
+		t.Errorf("NewValidationError = %v, want a ValidationError", err)
+	}
+}
+
+func TestModifyOrderTyped(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	f.respond(http.StatusOK, `{"Success":{"order_id":"7","message":"Successfully Modified the order"},"Status":200,"Error":null}`)
+
+	ack, err := a.ModifyOrderTyped(ModifyOrderRequest{OrderID: "7", ExchangeCode: "NSE", OrderType: "LIMIT", Price: "421", Quantity: "5", Validity: "IOC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.OrderID != "7" {
+		t.Errorf("OrderID = %q", ack.OrderID)
+	}
+	call := f.last(t)
+	if call.Method != "PUT" || call.Path != "/order" {
+		t.Errorf("request = %s %s, want PUT /order", call.Method, call.Path)
+	}
+	checkSigned(t, call)
+	checkBody(t, call, map[string]string{"order_id": "7", "order_type": "limit", "validity": "ioc", "price": "421"})
+
+	for _, req := range []ModifyOrderRequest{
+		{ExchangeCode: "NSE"},
+		{OrderID: "7"},
+		{OrderID: "7", ExchangeCode: "NSE", OrderType: "bracket"},
+		{OrderID: "7", ExchangeCode: "NSE", Validity: "gtc"},
+	} {
+		if _, err := a.ModifyOrder(req.OrderID, req.ExchangeCode, req.OrderType, "", "", "", req.Validity, "", ""); !errors.Is(err, ErrValidation) {
+			t.Errorf("ModifyOrder(%+v) error = %v, want ErrValidation", req, err)
+		}
+	}
+	if n := len(f.received()); n != 1 {
+		t.Errorf("%d requests reached the API, want 1", n)
+	}
+}
+
+func TestCancelOrderTyped(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	f.respond(http.StatusOK, `{"Success":{"order_id":"7","message":"Successfully cancelled the order"},"Status":200,"Error":null}`)
+
+	if _, err := a.CancelOrderTyped("NSE", "7"); err != nil {
+		t.Fatal(err)
+	}
+	call := f.last(t)
+	if call.Method != "DELETE" || call.Path != "/order" {
+		t.Errorf("request = %s %s, want DELETE /order", call.Method, call.Path)
+	}
+	checkSigned(t, call)
+	checkBody(t, call, map[string]string{"exchange_code": "NSE", "order_id": "7"})
+
+	if _, err := a.CancelOrder("NSE", ""); !errors.Is(err, ErrValidation) {
+		t.Errorf("CancelOrder without an ID: error = %v, want ErrValidation", err)
+	}
+	if _, err := a.CancelOrderTyped("", "7"); !errors.Is(err, ErrValidation) {
+		t.Errorf("CancelOrderTyped without an exchange: error = %v, want ErrValidation", err)
+	}
+}
+
+func TestGetOrderListTyped(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	f.respond(http.StatusOK, `{"Success":[
+		{"order_id":"1","stock_code":"ITC","action":"Buy","quantity":"10","price":"420.5","status":"Executed"},
+		{"order_id":"2","stock_code":"TCS","action":"Sell","quantity":5,"price":3300,"status":"Ordered"}
+	],"Status":200,"Error":null}`)
+
+	orders, err := a.GetOrderListTyped("NSE", "2023-06-20T00:00:00.000Z", "2023-06-21T00:00:00.000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 || orders[0].Quantity != 10 || orders[1].Price != 3300 {
+		t.Errorf("orders = %+v", orders)
+	}
+	call := f.last(t)
+	if call.Method != "GET" || call.Path != "/order" {
+		t.Errorf("request = %s %s, want GET /order", call.Method, call.Path)
+	}
+	checkSigned(t, call)
+	checkBody(t, call, map[string]string{"exchange_code": "NSE", "from_date": "2023-06-20T00:00:00.000Z"})
+
+	if _, err := a.GetOrderList("NSE", "", "2023-06-21T00:00:00.000Z"); !errors.Is(err, ErrValidation) {
+		t.Errorf("GetOrderList without a from date: error = %v, want ErrValidation", err)
+	}
+	if _, err := a.GetOrderDetailTyped("", "1"); !errors.Is(err, ErrValidation) {
+		t.Errorf("GetOrderDetailTyped without an exchange: error = %v, want ErrValidation", err)
+	}
+}
+
+func TestOrderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+		code   int
+	}{
+		{"envelope failure", http.StatusOK, `{"Success":null,"Status":500,"Error":"Insufficient balance"}`, ErrAPI, 500},
+		{"invalid session", http.StatusOK, `{"Success":null,"Status":401,"Error":"Invalid session."}`, ErrAuth, 401},
+		{"unknown app key", http.StatusOK, `{"Success":null,"Status":401,"Error":"Public Key does not exist."}`, ErrAuth, 401},
+		{"expired session", http.StatusOK, `{"Success":null,"Status":500,"Error":"Resource not available."}`, ErrSessionExpired, 500},
+		{"gateway error", http.StatusBadGateway, `<html>Bad Gateway</html>`, ErrAPI, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, a := newFakeBreezeAPI(t)
+			f.respond(tt.status, tt.body)
+			_, err := a.CancelOrderTyped("NSE", "7")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error %v does not wrap an APIError", err)
+			}
+			if apiErr.StatusCode != tt.code || string(apiErr.Body) != tt.body {
+				t.Errorf("APIError = %d %q, want %d %q", apiErr.StatusCode, apiErr.Body, tt.code, tt.body)
+			}
+		})
+	}
+}
+
+func TestDecodeResponseMalformed(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	// The Success object decodes before the bad Status does; none of it
+	// may leak out alongside the error.
+	f.respond(http.StatusOK, `{"Success":{"order_id":"7"},"Status":"ok"}`)
+	ack, err := a.CancelOrderTyped("NSE", "7")
+	if err == nil {
+		t.Fatal("malformed envelope decoded without error")
+	}
+	if ack != nil {
+		t.Errorf("ack = %+v alongside %v, want nil", ack, err)
+	}
+}
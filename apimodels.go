@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// istLocation is the zone Breeze reports exchange timestamps in when the
+// payload carries no offset of its own.
+var istLocation = time.FixedZone("IST", 5*3600+30*60)
+
+var breezeTimeLayouts = []string{
+	time.RFC3339Nano, // also "2006-01-02T15:04:05.000Z", read as UTC
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"02-Jan-2006 15:04:05",
+	"02-Jan-2006 15:04",
+	"02-Jan-2006",
+	"2006-01-02",
+	"02-01-2006",
+}
+
+// Response is the Success/Status/Error envelope every Breeze REST call is
+// wrapped in.
+type Response[T any] struct {
+	Success T      `json:"Success"`
+	Status  int    `json:"Status"`
+	Error   string `json:"Error"`
+}
+
+// Err reports a non-200 envelope as an APIError, AuthError or
+// SessionExpiredError.
+func (r *Response[T]) Err() error {
+	if r.Status == 200 {
+		return nil
+	}
+	return newAPIError(r.Status, r.Error, nil, nil)
+}
+
+// Float is a number Breeze may send either bare or quoted.
+type Float float64
+
+func (f *Float) UnmarshalJSON(data []byte) error {
+	s := unquoteNumber(data)
+	if s == "" {
+		*f = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s", data)
+	}
+	*f = Float(v)
+	return nil
+}
+
+// Int is an integer Breeze may send either bare or quoted.
+type Int int64
+
+func (i *Int) UnmarshalJSON(data []byte) error {
+	s := unquoteNumber(data)
+	if s == "" {
+		*i = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		fv, ferr := strconv.ParseFloat(s, 64)
+		if ferr != nil {
+			return fmt.Errorf("invalid integer %s", data)
+		}
+		v = int64(fv)
+	}
+	*i = Int(v)
+	return nil
+}
+
+func unquoteNumber(data []byte) string {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return ""
+	}
+	s := strings.Trim(string(data), `"`)
+	return strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+}
+
+// Time is a Breeze timestamp in any of the layouts the API uses.
+type Time struct {
+	time.Time
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSpace(unquoteNumber(data))
+	if s == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+	parsed, err := parseBreezeTime(s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(t.Time.Format(time.RFC3339))
+}
+
+func parseBreezeTime(s string) (time.Time, error) {
+	for _, layout := range breezeTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, s, istLocation); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+type CustomerDetails struct {
+	IdirectUserID           string            `json:"idirect_userid"`
+	IdirectUserName         string            `json:"idirect_user_name"`
+	IdirectOrderType        string            `json:"idirect_ORD_TYP"`
+	IdirectLastLoginTime    string            `json:"idirect_lastlogin_time"`
+	MFHoldingModePopupFlag  string            `json:"mf_holding_mode_popup_flg"`
+	CommodityExchangeStatus string            `json:"commodity_exchange_status"`
+	CommodityTradeDate      string            `json:"commodity_trade_date"`
+	CommodityAllowed        string            `json:"commodity_allowed"`
+	ExchangeTradeDate       map[string]string `json:"exg_trade_date"`
+	ExchangeStatus          map[string]string `json:"exg_status"`
+	SegmentsAllowed         map[string]string `json:"segments_allowed"`
+}
+
+type Funds struct {
+	BankAccount           string `json:"bank_account"`
+	TotalBankBalance      Float  `json:"total_bank_balance"`
+	AllocatedEquity       Float  `json:"allocated_equity"`
+	AllocatedFNO          Float  `json:"allocated_fno"`
+	AllocatedCommodity    Float  `json:"allocated_commodity"`
+	AllocatedCurrency     Float  `json:"allocated_currency"`
+	BlockByTradeEquity    Float  `json:"block_by_trade_equity"`
+	BlockByTradeFNO       Float  `json:"block_by_trade_fno"`
+	BlockByTradeCommodity Float  `json:"block_by_trade_commodity"`
+	BlockByTradeCurrency  Float  `json:"block_by_trade_currency"`
+	BlockByTradeBalance   Float  `json:"block_by_trade_balance"`
+	UnallocatedBalance    Float  `json:"unallocated_balance"`
+}
+
+type FundsRequest struct {
+	TransactionType string `json:"transaction_type"`
+	Amount          string `json:"amount"`
+	Segment         string `json:"segment"`
+}
+
+type StatusResult struct {
+	Status string `json:"status"`
+}
+
+type DematHolding struct {
+	StockCode              string `json:"stock_code"`
+	StockISIN              string `json:"stock_ISIN"`
+	Quantity               Int    `json:"quantity"`
+	DematTotalBulkQuantity Int    `json:"demat_total_bulk_quantity"`
+	DematAvailQuantity     Int    `json:"demat_avail_quantity"`
+	BlockedQuantity        Int    `json:"blocked_quantity"`
+	DematAllocatedQuantity Int    `json:"demat_allocated_quantity"`
+}
+
+type HistoricalDataRequest struct {
+	Interval     string `json:"interval"`
+	FromDate     string `json:"from_date"`
+	ToDate       string `json:"to_date"`
+	StockCode    string `json:"stock_code"`
+	ExchangeCode string `json:"exchange_code"`
+	ProductType  string `json:"product_type"`
+	ExpiryDate   string `json:"expiry_date"`
+	Right        string `json:"right"`
+	StrikePrice  string `json:"strike_price"`
+}
+
+type Candle struct {
+	Datetime     Time   `json:"datetime"`
+	StockCode    string `json:"stock_code"`
+	ExchangeCode string `json:"exchange_code"`
+	ProductType  string `json:"product_type"`
+	ExpiryDate   string `json:"expiry_date"`
+	Right        string `json:"right"`
+	StrikePrice  Float  `json:"strike_price"`
+	Open         Float  `json:"open"`
+	High         Float  `json:"high"`
+	Low          Float  `json:"low"`
+	Close        Float  `json:"close"`
+	Volume       Int    `json:"volume"`
+	OpenInterest Int    `json:"open_interest"`
+}
+
+type OrderRequest struct {
+	StockCode         string `json:"stock_code"`
+	ExchangeCode      string `json:"exchange_code"`
+	Product           string `json:"product"`
+	Action            string `json:"action"`
+	OrderType         string `json:"order_type"`
+	Quantity          string `json:"quantity"`
+	Price             string `json:"price"`
+	Validity          string `json:"validity"`
+	Stoploss          string `json:"stoploss"`
+	ValidityDate      string `json:"validity_date"`
+	DisclosedQuantity string `json:"disclosed_quantity"`
+	ExpiryDate        string `json:"expiry_date"`
+	Right             string `json:"right"`
+	StrikePrice       string `json:"strike_price"`
+	UserRemark        string `json:"user_remark"`
+}
+
+// validate lower-cases the enumerated fields and checks them against the
+// values the order endpoint accepts.
+func (r *OrderRequest) validate() error {
+	if r.StockCode == "" || r.ExchangeCode == "" || r.Product == "" || r.Action == "" || r.OrderType == "" || r.Quantity == "" {
+		return &ValidationError{Message: "Stock code, exchange code, product, action, order type or quantity cannot be empty"}
+	}
+	r.Product = strings.ToLower(r.Product)
+	r.Action = strings.ToLower(r.Action)
+	r.OrderType = strings.ToLower(r.OrderType)
+	r.Validity = strings.ToLower(r.Validity)
+	r.Right = strings.ToLower(r.Right)
+	if !contains(PRODUCT_TYPES, r.Product) {
+		return &ValidationError{Message: "Product should be one of " + strings.Join(PRODUCT_TYPES, ", ")}
+	}
+	if !contains(ACTION_TYPES, r.Action) {
+		return &ValidationError{Message: "Action should be one of " + strings.Join(ACTION_TYPES, ", ")}
+	}
+	if !contains(ORDER_TYPES, r.OrderType) {
+		return &ValidationError{Message: "Order type should be one of " + strings.Join(ORDER_TYPES, ", ")}
+	}
+	if r.Validity != "" && !contains(VALIDITY_TYPES, r.Validity) {
+		return &ValidationError{Message: "Validity should be one of " + strings.Join(VALIDITY_TYPES, ", ")}
+	}
+	if r.OrderType == "limit" && r.Price == "" {
+		return &ValidationError{Message: "Price cannot be empty for limit order"}
+	}
+	if r.OrderType == "stoploss" && r.Stoploss == "" {
+		return &ValidationError{Message: "Stoploss cannot be empty for stoploss order"}
+	}
+	switch r.Product {
+	case "futures", "options", "futureplus", "optionplus":
+		if r.ExpiryDate == "" {
+			return &ValidationError{Message: "Expiry date cannot be empty for " + r.Product}
+		}
+	}
+	if r.Product == "options" || r.Product == "optionplus" {
+		if !contains(RIGHT_TYPES, r.Right) {
+			return &ValidationError{Message: "Right should be one of " + strings.Join(RIGHT_TYPES, ", ")}
+		}
+		if r.StrikePrice == "" {
+			return &ValidationError{Message: "Strike price cannot be empty for options"}
+		}
+	}
+	return nil
+}
+
+type ModifyOrderRequest struct {
+	OrderID           string `json:"order_id"`
+	ExchangeCode      string `json:"exchange_code"`
+	OrderType         string `json:"order_type"`
+	Stoploss          string `json:"stoploss"`
+	Quantity          string `json:"quantity"`
+	Price             string `json:"price"`
+	Validity          string `json:"validity"`
+	DisclosedQuantity string `json:"disclosed_quantity"`
+	ValidityDate      string `json:"validity_date"`
+}
+
+func (r *ModifyOrderRequest) validate() error {
+	if r.OrderID == "" || r.ExchangeCode == "" {
+		return &ValidationError{Message: "Exchange code or order ID cannot be empty"}
+	}
+	r.OrderType = strings.ToLower(r.OrderType)
+	r.Validity = strings.ToLower(r.Validity)
+	if r.OrderType != "" && !contains(ORDER_TYPES, r.OrderType) {
+		return &ValidationError{Message: "Order type should be one of " + strings.Join(ORDER_TYPES, ", ")}
+	}
+	if r.Validity != "" && !contains(VALIDITY_TYPES, r.Validity) {
+		return &ValidationError{Message: "Validity should be one of " + strings.Join(VALIDITY_TYPES, ", ")}
+	}
+	return nil
+}
+
+type OrderAck struct {
+	OrderID    string `json:"order_id"`
+	Message    string `json:"message"`
+	UserRemark string `json:"user_remark"`
+}
+
+type OrderDetail struct {
+	OrderID                     string `json:"order_id"`
+	ExchangeOrderID             string `json:"exchange_order_id"`
+	ExchangeCode                string `json:"exchange_code"`
+	StockCode                   string `json:"stock_code"`
+	ProductType                 string `json:"product_type"`
+	Action                      string `json:"action"`
+	OrderType                   string `json:"order_type"`
+	Stoploss                    Float  `json:"stoploss"`
+	Quantity                    Int    `json:"quantity"`
+	Price                       Float  `json:"price"`
+	Validity                    string `json:"validity"`
+	DisclosedQuantity           Int    `json:"disclosed_quantity"`
+	ExpiryDate                  Time   `json:"expiry_date"`
+	Right                       string `json:"right"`
+	StrikePrice                 Float  `json:"strike_price"`
+	AveragePrice                Float  `json:"average_price"`
+	CancelledQuantity           Int    `json:"cancelled_quantity"`
+	PendingQuantity             Int    `json:"pending_quantity"`
+	Status                      string `json:"status"`
+	UserRemark                  string `json:"user_remark"`
+	OrderDatetime               Time   `json:"order_datetime"`
+	ParentOrderID               string `json:"parent_order_id"`
+	ModificationNumber          string `json:"modification_number"`
+	ExchangeAcknowledgementDate Time   `json:"exchange_acknowledgement_date"`
+	SLTPPrice                   Float  `json:"SLTP_price"`
+	ExchangeAcknowledgeNumber   string `json:"exchange_acknowledge_number"`
+	InitialLimit                Float  `json:"initial_limit"`
+	InitialSLTP                 Float  `json:"intial_sltp"`
+	LTP                         Float  `json:"LTP"`
+	LimitOffset                 Float  `json:"limit_offset"`
+	MBCFlag                     string `json:"mbc_flag"`
+	CutoffPrice                 Float  `json:"cutoff_price"`
+	ValidityDate                Time   `json:"validity_date"`
+}
@@ -2,12 +2,13 @@ package main
 
 import (
 	"encoding/base64"
-	"encoding/csv"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,14 +17,24 @@ type BreezeInstance struct {
 	APIKey                    string
 	SessionKey                string
 	SecretKey                 string
+	APIURL                    string
 	SIORateRefreshHandler     *SocketEventBreeze
 	SIOOrderRefreshHandler    *SocketEventBreeze
 	SIOOhlcvStreamHandler     *SocketEventBreeze
 	APIHandler                *ApificationBreeze
 	OnTicks                   func(map[string]interface{})
 	OnTicks2                  func(map[string]interface{})
-	StockScriptDictList       []map[string]string
-	TokenScriptDictList       []map[string][]string
+	OnTypedTick               func(*Tick)
+	OnConnected               func(hostname string)
+	OnReconnecting            func(hostname string, attempt int, delay time.Duration)
+	OnDisconnected            func(hostname string, err error)
+	Reconnect                 ReconnectPolicy
+	MaxFeedTokens             int
+	SubscribeBatchWindow      time.Duration
+	Instruments               *InstrumentRegistry
+	ticks                     *tickHub
+	tickHandlers              handlerRegistry[map[string]interface{}]
+	typedTickHandlers         handlerRegistry[*Tick]
 	TuxToUserValue            map[string]map[string]string
 	OrderConnect              int
 	Interval                  string
@@ -31,6 +42,9 @@ type BreezeInstance struct {
 	LiveStreamURL             string
 	LiveOhlcStreamURL         string
 	StockScriptCSVURL         string
+	ScripFile                 string
+	ScripCachePath            string
+	ScripCacheMaxAge          time.Duration
 	CustomerDetailsEndpoint   string
 	ExceptMessage             map[string]string
 	ConfigChannelIntervalMap  map[string]string
@@ -40,19 +54,39 @@ type BreezeInstance struct {
 
 func NewBreezeInstance(apiKey string) *BreezeInstance {
 	return &BreezeInstance{
-		APIKey:              apiKey,
-		StockScriptDictList: make([]map[string]string, 6),
-		TokenScriptDictList: make([]map[string][]string, 6),
-		OrderConnect:        0,
+		APIKey:                    apiKey,
+		APIURL:                    API_URL,
+		LiveFeedsURL:              LIVE_FEEDS_URL,
+		LiveStreamURL:             LIVE_STREAM_URL,
+		LiveOhlcStreamURL:         LIVE_OHLC_STREAM_URL,
+		StockScriptCSVURL:         STOCK_SCRIPT_CSV_URL,
+		CustomerDetailsEndpoint:   API_URL + string(CUST_DETAILS),
+		Instruments:               NewInstrumentRegistry(),
+		TuxToUserValue:            DefaultTuxTables.copyValues(),
+		ticks:                     newTickHub(),
+		OrderConnect:              0,
+		ExceptMessage:             copyMessages(EXCEPTION_MESSAGE),
+		ResponseMessage:           copyMessages(RESPONSE_MESSAGE),
+		Reconnect:                 DefaultReconnectPolicy,
+		ConfigChannelIntervalMap:  ChannelIntervalMap,
+		ConfigIntervalTypesStream: INTERVAL_TYPES_STREAM,
 	}
 }
 
+func copyMessages(messages map[string]string) map[string]string {
+	copied := make(map[string]string, len(messages))
+	for k, v := range messages {
+		copied[k] = v
+	}
+	return copied
+}
+
 func (b *BreezeInstance) socketConnectionResponse(message string) map[string]string {
 	return map[string]string{"message": message}
 }
 
 func (b *BreezeInstance) subscribeException(message string) error {
-	return errors.New(message)
+	return &ValidationError{Message: message}
 }
 
 func (b *BreezeInstance) _wsConnect(handler *SocketEventBreeze, orderFlag bool, ohlcvFlag bool, strategyFlag bool) error {
@@ -62,7 +96,7 @@ func (b *BreezeInstance) _wsConnect(handler *SocketEventBreeze, orderFlag bool,
 			b.SIOOrderRefreshHandler = NewSocketEventBreeze("/", b)
 		}
 		if b.OrderConnect == 0 {
-			err = b.SIOOrderRefreshHandler.Connect(b.LiveFeedsURL, false, false)
+			err = b.SIOOrderRefreshHandler.Connect(b.LiveFeedsURL, false, strategyFlag)
 			b.OrderConnect++
 		}
 	} else if ohlcvFlag {
@@ -125,6 +159,109 @@ func (b *BreezeInstance) WSConnect() error {
 	return b._wsConnect(b.SIORateRefreshHandler, false, false, false)
 }
 
+// addTypedTickHandler registers an internal consumer of decoded ticks and
+// returns a func that removes it. Handlers see each tick before
+// OnTypedTick does.
+func (b *BreezeInstance) addTypedTickHandler(handler func(*Tick)) (remove func()) {
+	return b.typedTickHandlers.add(handler)
+}
+
+// addTickHandler registers an internal consumer of the map ticks OnTicks
+// receives and returns a func that removes it. Handlers see each tick
+// before OnTicks does.
+func (b *BreezeInstance) addTickHandler(handler func(map[string]interface{})) (remove func()) {
+	return b.tickHandlers.add(handler)
+}
+
+// handlerRegistry holds the internal consumers of a tick stream, such as
+// the ones TrackPositions and EnableOrderBook install. It is dispatched
+// apart from the OnTicks and OnTypedTick callbacks, so a user assigning
+// those never detaches an internal consumer. The zero value is ready to
+// use.
+type handlerRegistry[T any] struct {
+	mu       sync.RWMutex
+	handlers []*registeredHandler[T]
+}
+
+type registeredHandler[T any] struct {
+	fn func(T)
+}
+
+// add registers fn and returns a func that removes it again; calling the
+// remove func more than once is harmless.
+func (r *handlerRegistry[T]) add(fn func(T)) (remove func()) {
+	h := &registeredHandler[T]{fn: fn}
+	r.mu.Lock()
+	r.handlers = append(r.handlers, h)
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for i, registered := range r.handlers {
+			if registered == h {
+				// Copy rather than shift in place: dispatch may be
+				// iterating over the old slice.
+				r.handlers = append(r.handlers[:i:i], r.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (r *handlerRegistry[T]) active() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.handlers) > 0
+}
+
+// dispatch calls every handler in registration order, outside the lock so
+// a handler may remove itself.
+func (r *handlerRegistry[T]) dispatch(v T) {
+	r.mu.RLock()
+	handlers := r.handlers
+	r.mu.RUnlock()
+	for _, h := range handlers {
+		h.fn(v)
+	}
+}
+
+// feedExchangeCodes maps the exchange prefix of a feed symbol ("4" in
+// "4.1!2885", "2" for the BFO tokens getStockTokenValue builds) to its
+// exchange.
+var feedExchangeCodes = map[string]string{
+	"1":  "BSE",
+	"2":  "BFO",
+	"4":  "NSE",
+	"13": "NDX",
+	"6":  "MCX",
+	"8":  "BFO",
+}
+
+// tokenInstrument looks token up on exchange. NSE cash and NFO contracts
+// share the "4." prefix, so an NSE miss is retried on NFO.
+func (b *BreezeInstance) tokenInstrument(exchange, token string) (Instrument, bool) {
+	inst, found := b.Instruments.ByToken(exchange, token)
+	if !found && exchange == "NSE" {
+		inst, found = b.Instruments.ByToken("NFO", token)
+	}
+	return inst, found
+}
+
+// feedInstrument resolves a feed symbol such as "4.1!2885" to its
+// instrument.
+func (b *BreezeInstance) feedInstrument(symbol string) (Instrument, bool) {
+	prefix, rest, ok := strings.Cut(symbol, ".")
+	if !ok {
+		return Instrument{}, false
+	}
+	_, token, ok := strings.Cut(rest, "!")
+	exchange, known := feedExchangeCodes[prefix]
+	if !ok || !known {
+		return Instrument{}, false
+	}
+	return b.tokenInstrument(exchange, token)
+}
+
 func (b *BreezeInstance) GetDataFromStockTokenValue(inputStockToken string) (map[string]interface{}, error) {
 	outputData := map[string]interface{}{}
 	parts := strings.Split(inputStockToken, ".")
@@ -138,58 +275,33 @@ func (b *BreezeInstance) GetDataFromStockTokenValue(inputStockToken string) (map
 	}
 	stockToken := stockTokenParts[1]
 
-	exchangeCodeList := map[string]string{
-		"1":  "BSE",
-		"4":  "NSE",
-		"13": "NDX",
-		"6":  "MCX",
-	}
-	exchangeCodeName, ok := exchangeCodeList[exchangeType]
+	exchangeCodeName, ok := feedExchangeCodes[exchangeType]
 	if !ok {
 		return nil, b.subscribeException(b.ExceptMessage["WRONG_EXCHANGE_CODE_EXCEPTION"])
 	}
 
-	var stockData []string
-	switch exchangeCodeName {
-	case "BSE":
-		stockData = b.TokenScriptDictList[0][stockToken]
-	case "NSE":
-		stockData = b.TokenScriptDictList[1][stockToken]
-		if stockData == nil {
-			stockData = b.TokenScriptDictList[4][stockToken]
-			if stockData != nil {
-				exchangeCodeName = "NFO"
-			}
-		}
-	case "NDX":
-		stockData = b.TokenScriptDictList[2][stockToken]
-	case "MCX":
-		stockData = b.TokenScriptDictList[3][stockToken]
-	}
-
-	if stockData == nil {
+	inst, found := b.tokenInstrument(exchangeCodeName, stockToken)
+	if !found {
 		return nil, b.subscribeException(fmt.Sprintf(b.ExceptMessage["STOCK_NOT_EXIST_EXCEPTION"], exchangeCodeName, inputStockToken))
 	}
 
-	outputData["stock_name"] = stockData[1]
-	if exchangeCodeName != "NSE" && exchangeCodeName != "BSE" {
-		productType := strings.Split(stockData[0], "-")[0]
-		if productType == "FUT" {
-			outputData["product_type"] = "Futures"
-		}
-		if productType == "OPT" {
-			outputData["product_type"] = "Options"
-		}
-		dateString := strings.Join(stockData[0:5], "-")
-		outputData["expiry_date"] = dateString
-		if len(stockData) > 5 {
-			outputData["strike_price"] = stockData[5]
-			right := stockData[6]
-			if right == "PE" {
-				outputData["right"] = "Put"
-			} else if right == "CE" {
-				outputData["right"] = "Call"
-			}
+	outputData["stock_name"] = inst.Name
+	switch inst.ProductType() {
+	case "futures":
+		outputData["product_type"] = "Futures"
+	case "options":
+		outputData["product_type"] = "Options"
+	default:
+		return outputData, nil
+	}
+	outputData["expiry_date"] = inst.ExpiryDate
+	if inst.ProductType() == "options" {
+		outputData["strike_price"] = strconv.FormatFloat(inst.StrikePrice, 'f', -1, 64)
+		switch inst.Right {
+		case "put":
+			outputData["right"] = "Put"
+		case "call":
+			outputData["right"] = "Call"
 		}
 	}
 
@@ -223,53 +335,37 @@ func (b *BreezeInstance) getStockTokenValue(exchangeCode, stockCode, productType
 		return "", "", b.subscribeException(b.ExceptMessage["STOCK_CODE_EXCEPTION"])
 	}
 
-	var tokenValue string
+	var inst Instrument
+	var found bool
 	switch exchangeCode {
-	case "BSE":
-		tokenValue = b.StockScriptDictList[0][stockCode]
-	case "NSE":
-		tokenValue = b.StockScriptDictList[1][stockCode]
+	case "BSE", "NSE":
+		inst, found = b.Instruments.Stock(exchangeCode, stockCode)
 	default:
 		if expiryDate == "" {
 			return "", "", b.subscribeException(b.ExceptMessage["EXPIRY_DATE_EXCEPTION"])
 		}
-		var contractDetailValue string
-		if productType == "futures" {
-			contractDetailValue = "FUT"
-		} else if productType == "options" {
-			contractDetailValue = "OPT"
-		} else {
+		if productType != "futures" && productType != "options" {
 			return "", "", b.subscribeException(b.ExceptMessage["PRODUCT_TYPE_EXCEPTION"])
 		}
-		contractDetailValue += "-" + stockCode + "-" + expiryDate
+		var strike float64
 		if productType == "options" {
 			if strikePrice == "" {
 				return "", "", b.subscribeException(b.ExceptMessage["STRIKE_PRICE_EXCEPTION"])
 			}
-			contractDetailValue += "-" + strikePrice
-			if right == "put" {
-				contractDetailValue += "-PE"
-			} else if right == "call" {
-				contractDetailValue += "-CE"
-			} else {
+			if right != "put" && right != "call" {
 				return "", "", b.subscribeException(b.ExceptMessage["RIGHT_EXCEPTION"])
 			}
+			var err error
+			if strike, err = strconv.ParseFloat(strikePrice, 64); err != nil {
+				return "", "", b.subscribeException(b.ExceptMessage["STRIKE_PRICE_EXCEPTION"])
+			}
 		}
-		switch exchangeCode {
-		case "NDX":
-			tokenValue = b.StockScriptDictList[2][contractDetailValue]
-		case "MCX":
-			tokenValue = b.StockScriptDictList[3][contractDetailValue]
-		case "NFO":
-			tokenValue = b.StockScriptDictList[4][contractDetailValue]
-		case "BFO":
-			tokenValue = b.StockScriptDictList[5][contractDetailValue]
-		}
+		inst, found = b.Instruments.Contract(exchangeCode, stockCode, productType, expiryDate, strike, right)
 	}
-
-	if tokenValue == "" {
+	if !found || inst.Token == "" {
 		return "", "", b.subscribeException(b.ExceptMessage["STOCK_INVALID_EXCEPTION"])
 	}
+	tokenValue := inst.Token
 
 	var exchangeQuotesTokenValue, marketDepthTokenValue string
 	if getExchangeQuotes {
@@ -283,25 +379,27 @@ func (b *BreezeInstance) getStockTokenValue(exchangeCode, stockCode, productType
 
 func (b *BreezeInstance) SubscribeFeeds(stockToken, exchangeCode, stockCode, productType, expiryDate, strikePrice, right, interval string, getExchangeQuotes, getMarketDepth, getOrderNotification bool) (map[string]string, error) {
 	b.Interval = interval
-	if b.SIORateRefreshHandler != nil && !b.SIORateRefreshHandler.Authentication {
-		return nil, errors.New(b.ExceptMessage["AUTHENICATION_EXCEPTION"])
+	if b.SIORateRefreshHandler != nil && !b.SIORateRefreshHandler.authentication {
+		return nil, &AuthError{Message: b.ExceptMessage["AUTHENICATION_EXCEPTION"]}
 	}
 
 	if interval != "" {
 		if !contains(b.ConfigIntervalTypesStream, interval) {
-			return nil, errors.New(b.ExceptMessage["STREAM_OHLC_INTERVAL_ERROR"])
+			return nil, b.subscribeException(b.ExceptMessage["STREAM_OHLC_INTERVAL_ERROR"])
 		}
 		interval = b.ConfigChannelIntervalMap[interval]
 	}
 
 	var returnObject map[string]string
 	if b.SIORateRefreshHandler != nil {
-		if b.SIOOrderRefreshHandler != nil && contains(config.STRATEGY_SUBSCRIPTION, stockToken) {
+		if contains(STRATEGY_SUBSCRIPTION, stockToken) {
 			err := b._wsConnect(b.SIOOrderRefreshHandler, false, false, true)
 			if err != nil {
 				return nil, err
 			}
-			b.SIOOrderRefreshHandler.Watch(stockToken)
+			if err := b.SIOOrderRefreshHandler.Watch(stockToken); err != nil {
+				return nil, err
+			}
 			returnObject = b.socketConnectionResponse(fmt.Sprintf(b.ResponseMessage["STRATEGY_STREAM_SUBSCRIBED"], stockToken))
 			return returnObject, nil
 		}
@@ -323,8 +421,8 @@ func (b *BreezeInstance) SubscribeFeeds(stockToken, exchangeCode, stockCode, pro
 					}
 				}
 				b.SIOOhlcvStreamHandler.WatchStreamData(stockToken, interval)
-			} else {
-				b.SIORateRefreshHandler.Watch(stockToken)
+			} else if err := b.SIORateRefreshHandler.Watch(stockToken); err != nil {
+				return nil, err
 			}
 			returnObject = b.socketConnectionResponse(fmt.Sprintf(b.ResponseMessage["STOCK_SUBSCRIBE_MESSAGE"], stockToken))
 		} else if getOrderNotification && exchangeCode == "" {
@@ -343,11 +441,14 @@ func (b *BreezeInstance) SubscribeFeeds(stockToken, exchangeCode, stockCode, pro
 				}
 				b.SIOOhlcvStreamHandler.WatchStreamData(exchangeQuotesToken, interval)
 			} else {
-				if exchangeQuotesToken != "" {
-					b.SIORateRefreshHandler.Watch(exchangeQuotesToken)
+				tokens := []string{}
+				for _, token := range []string{exchangeQuotesToken, marketDepthToken} {
+					if token != "" {
+						tokens = append(tokens, token)
+					}
 				}
-				if marketDepthToken != "" {
-					b.SIORateRefreshHandler.Watch(marketDepthToken)
+				if err := b.SIORateRefreshHandler.Watch(tokens); err != nil {
+					return nil, err
 				}
 			}
 			returnObject = b.socketConnectionResponse(fmt.Sprintf(b.ResponseMessage["STOCK_SUBSCRIBE_MESSAGE"], stockCode))
@@ -359,7 +460,7 @@ func (b *BreezeInstance) SubscribeFeeds(stockToken, exchangeCode, stockCode, pro
 func (b *BreezeInstance) UnsubscribeFeeds(stockToken, exchangeCode, stockCode, productType, expiryDate, strikePrice, right, interval string, getExchangeQuotes, getMarketDepth, getOrderNotification bool) (map[string]string, error) {
 	if interval != "" {
 		if !contains(b.ConfigIntervalTypesStream, interval) {
-			return nil, errors.New(b.ExceptMessage["STREAM_OHLC_INTERVAL_ERROR"])
+			return nil, b.subscribeException(b.ExceptMessage["STREAM_OHLC_INTERVAL_ERROR"])
 		}
 		interval = b.ConfigChannelIntervalMap[interval]
 	}
@@ -374,7 +475,7 @@ func (b *BreezeInstance) UnsubscribeFeeds(stockToken, exchangeCode, stockCode, p
 		return b.socketConnectionResponse(b.ResponseMessage["ORDER_REFRESH_NOT_CONNECTED"]), nil
 	}
 
-	if contains(config.STRATEGY_SUBSCRIPTION, stockToken) {
+	if contains(STRATEGY_SUBSCRIPTION, stockToken) {
 		if b.SIOOrderRefreshHandler != nil {
 			b.SIOOrderRefreshHandler.Unwatch(stockToken)
 			return b.socketConnectionResponse(fmt.Sprintf(b.ResponseMessage["STRATEGY_STREAM_UNSUBSCRIBED"], stockToken)), nil
@@ -386,7 +487,7 @@ func (b *BreezeInstance) UnsubscribeFeeds(stockToken, exchangeCode, stockCode, p
 		if stockToken != "" {
 			if interval != "" {
 				if b.SIOOhlcvStreamHandler != nil {
-					b.SIOOhlcvStreamHandler.Unwatch(stockToken)
+					b.SIOOhlcvStreamHandler.UnwatchStreamData(stockToken)
 				}
 			} else {
 				b.SIORateRefreshHandler.Unwatch(stockToken)
@@ -399,7 +500,7 @@ func (b *BreezeInstance) UnsubscribeFeeds(stockToken, exchangeCode, stockCode, p
 			}
 			if interval != "" {
 				if b.SIOOhlcvStreamHandler != nil {
-					b.SIOOhlcvStreamHandler.Unwatch(exchangeQuotesToken)
+					b.SIOOhlcvStreamHandler.UnwatchStreamData(exchangeQuotesToken)
 				}
 			} else {
 				if exchangeQuotesToken != "" {
@@ -420,7 +521,7 @@ func (b *BreezeInstance) parseOhlcData(data string) map[string]interface{} {
 	parsedData := make(map[string]interface{})
 	if len(splitData) == 9 {
 		parsedData = map[string]interface{}{
-			"interval":      config.feedIntervalMap[splitData[8]],
+			"interval":      FeedIntervalMap[splitData[8]],
 			"exchange_code": splitData[0],
 			"stock_code":    splitData[1],
 			"low":           splitData[2],
@@ -432,7 +533,7 @@ func (b *BreezeInstance) parseOhlcData(data string) map[string]interface{} {
 		}
 	} else if len(splitData) == 13 {
 		parsedData = map[string]interface{}{
-			"interval":      config.feedIntervalMap[splitData[12]],
+			"interval":      FeedIntervalMap[splitData[12]],
 			"exchange_code": splitData[0],
 			"stock_code":    splitData[1],
 			"expiry_date":   splitData[2],
@@ -448,7 +549,7 @@ func (b *BreezeInstance) parseOhlcData(data string) map[string]interface{} {
 		}
 	} else if len(splitData) == 11 {
 		parsedData = map[string]interface{}{
-			"interval":      config.feedIntervalMap[splitData[10]],
+			"interval":      FeedIntervalMap[splitData[10]],
 			"exchange_code": splitData[0],
 			"stock_code":    splitData[1],
 			"expiry_date":   splitData[2],
@@ -466,7 +567,17 @@ func (b *BreezeInstance) parseOhlcData(data string) map[string]interface{} {
 
 func (b *BreezeInstance) parseMarketDepth(data [][]string, exchange string) []map[string]interface{} {
 	depth := []map[string]interface{}{}
+	width := 8
+	switch exchange {
+	case "1":
+		width = 4
+	case "8":
+		width = 6
+	}
 	for i, lis := range data {
+		if len(lis) < width {
+			continue
+		}
 		dict := make(map[string]interface{})
 		if exchange == "1" {
 			dict[fmt.Sprintf("BestBuyRate-%d", i+1)] = lis[0]
@@ -495,6 +606,37 @@ func (b *BreezeInstance) parseMarketDepth(data [][]string, exchange string) []ma
 	return depth
 }
 
+// tickTime formats an epoch-seconds field of a tick, which may arrive as a
+// number or a numeric string.
+func tickTime(v interface{}) string {
+	sec, ok := tickFloat(v)
+	if !ok {
+		return ""
+	}
+	return time.Unix(int64(sec), 0).Format(time.RFC3339)
+}
+
+// depthRows converts the decoded depth levels, which JSON gives as
+// []interface{} of strings or numbers, into the rows parseMarketDepth takes.
+func depthRows(v interface{}) [][]string {
+	levels, _ := v.([]interface{})
+	rows := make([][]string, 0, len(levels))
+	for _, level := range levels {
+		cells, _ := level.([]interface{})
+		row := make([]string, len(cells))
+		for i, cell := range cells {
+			switch c := cell.(type) {
+			case string:
+				row[i] = c
+			case float64:
+				row[i] = strconv.FormatFloat(c, 'f', -1, 64)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
 func (b *BreezeInstance) parseData(data []interface{}) map[string]interface{} {
 	if len(data) == 0 {
 		return nil
@@ -554,109 +696,32 @@ func (b *BreezeInstance) parseData(data []interface{}) map[string]interface{} {
 				"status":                  data[27],
 			}
 			return strategyDict
-		} else if len(data) == 42 {
-			orderDict := map[string]interface{}{
-				"sourceNumber":              data[0],
-				"group":                     data[1],
-				"userId":                    data[2],
-				"key":                       data[3],
-				"messageLength":             data[4],
-				"requestType":               data[5],
-				"messageSequence":           data[6],
-				"messageDate":               data[7],
-				"messageTime":               data[8],
-				"messageCategory":           data[9],
-				"messagePriority":           data[10],
-				"messageType":               data[11],
-				"orderMatchAccount":         data[12],
-				"orderExchangeCode":         data[13],
-				"stockCode":                 data[14],
-				"orderFlow":                 b.TuxToUserValue["orderFlow"][data[15].(string)],
-				"limitMarketFlag":           b.TuxToUserValue["limitMarketFlag"][data[16].(string)],
-				"orderType":                 b.TuxToUserValue["orderType"][data[17].(string)],
-				"orderLimitRate":            data[18],
-				"productType":               b.TuxToUserValue["productType"][data[19].(string)],
-				"orderStatus":               b.TuxToUserValue["orderStatus"][data[20].(string)],
-				"orderDate":                 data[21],
-				"orderTradeDate":            data[22],
-				"orderReference":            data[23],
-				"orderQuantity":             data[24],
-				"openQuantity":              data[25],
-				"orderExecutedQuantity":     data[26],
-				"cancelledQuantity":         data[27],
-				"expiredQuantity":           data[28],
-				"orderDisclosedQuantity":    data[29],
-				"orderStopLossTrigger":      data[30],
-				"orderSquareFlag":           data[31],
-				"orderAmountBlocked":        data[32],
-				"orderPipeId":               data[33],
-				"channel":                   data[34],
-				"exchangeSegmentCode":       data[35],
-				"exchangeSegmentSettlement": data[36],
-				"segmentDescription":        data[37],
-				"marginSquareOffMode":       data[38],
-				"orderValidDate":            data[40],
-				"orderMessageCharacter":     data[41],
-				"averageExecutedRate":       data[42],
-				"orderPriceImprovementFlag": data[43],
-				"orderMBCFlag":              data[44],
-				"orderLimitOffset":          data[45],
-				"systemPartnerCode":         data[46],
-			}
-			return orderDict
-		} else if len(data) == 43 {
-			orderDict := map[string]interface{}{
-				"sourceNumber":              data[0],
-				"group":                     data[1],
-				"userId":                    data[2],
-				"key":                       data[3],
-				"messageLength":             data[4],
-				"requestType":               data[5],
-				"messageSequence":           data[6],
-				"messageDate":               data[7],
-				"messageTime":               data[8],
-				"messageCategory":           data[9],
-				"messagePriority":           data[10],
-				"messageType":               data[11],
-				"orderMatchAccount":         data[12],
-				"orderExchangeCode":         data[13],
-				"stockCode":                 data[14],
-				"orderFlow":                 b.TuxToUserValue["orderFlow"][data[21].(string)],
-				"limitMarketFlag":           b.TuxToUserValue["limitMarketFlag"][data[22].(string)],
-				"orderType":                 b.TuxToUserValue["orderType"][data[23].(string)],
-				"orderLimitRate":            data[24],
-				"productType":               b.TuxToUserValue["productType"][data[15].(string)],
-				"orderStatus":               b.TuxToUserValue["orderStatus"][data[25].(string)],
-				"orderReference":            data[26],
-				"orderTotalQuantity":        data[27],
-				"executedQuantity":          data[28],
-				"cancelledQuantity":         data[29],
-				"expiredQuantity":           data[30],
-				"stopLossTrigger":           data[31],
-				"specialFlag":               data[32],
-				"pipeId":                    data[33],
-				"channel":                   data[34],
-				"modificationOrCancelFlag":  data[35],
-				"tradeDate":                 data[36],
-				"acknowledgeNumber":         data[37],
-				"stopLossOrderReference":    data[37],
-				"totalAmountBlocked":        data[38],
-				"averageExecutedRate":       data[39],
-				"cancelFlag":                data[40],
-				"squareOffMarket":           data[41],
-				"quickExitFlag":             data[42],
-				"stopValidTillDateFlag":     data[43],
-				"priceImprovementFlag":      data[44],
-				"conversionImprovementFlag": data[45],
-				"trailUpdateCondition":      data[45],
-				"systemPartnerCode":         data[46],
-			}
-			return orderDict
 		}
+		// Anything else without a feed symbol is an order notification;
+		// it is never decoded as depth.
+		return b.parseOrderData(data)
 	}
 
-	exchange := strings.Split(data[0].(string), "!")[0]
-	dataType := strings.Split(data[0].(string), "!")[1]
+	// Symbols look like "4.1!2885": exchange "4", data type "1", token "2885".
+	symbol, ok := data[0].(string)
+	if !ok {
+		return nil
+	}
+	exchangeAndType := strings.Split(strings.Split(symbol, "!")[0], ".")
+	exchange := exchangeAndType[0]
+	dataType := ""
+	if len(exchangeAndType) > 1 {
+		dataType = exchangeAndType[1]
+	}
+	minFields := 3
+	if exchange == "6" {
+		minFields = 22
+	} else if dataType == "1" {
+		minFields = 12
+	}
+	if len(data) < minFields {
+		return nil
+	}
 	dataDict := make(map[string]interface{})
 	if exchange == "6" {
 		dataDict["symbol"] = data[0]
@@ -666,7 +731,7 @@ func (b *BreezeInstance) parseData(data []interface{}) map[string]interface{} {
 		dataDict["ttq"] = data[4]
 		dataDict["last"] = data[5]
 		dataDict["ltq"] = data[6]
-		dataDict["ltt"] = time.Unix(int64(data[7].(float64)), 0).Format(time.RFC3339)
+		dataDict["ltt"] = tickTime(data[7])
 		dataDict["AvgTradedPrice"] = data[8]
 		dataDict["TotalBuyQnt"] = data[9]
 		dataDict["TotalSellQnt"] = data[10]
@@ -682,14 +747,18 @@ func (b *BreezeInstance) parseData(data []interface{}) map[string]interface{} {
 		dataDict["LowestPriceEver"] = data[20]
 		dataDict["TotalTradedValue"] = data[21]
 		for i := 22; i < len(data); i++ {
-			dataDict[fmt.Sprintf("Quantity-%d", i-22)] = data[i].([]interface{})[0]
-			dataDict[fmt.Sprintf("OrderPrice-%d", i-22)] = data[i].([]interface{})[1]
-			dataDict[fmt.Sprintf("TotalOrders-%d", i-22)] = data[i].([]interface{})[2]
-			dataDict[fmt.Sprintf("Reserved-%d", i-22)] = data[i].([]interface{})[3]
-			dataDict[fmt.Sprintf("SellQuantity-%d", i-22)] = data[i].([]interface{})[4]
-			dataDict[fmt.Sprintf("SellOrderPrice-%d", i-22)] = data[i].([]interface{})[5]
-			dataDict[fmt.Sprintf("SellTotalOrders-%d", i-22)] = data[i].([]interface{})[6]
-			dataDict[fmt.Sprintf("SellReserved-%d", i-22)] = data[i].([]interface{})[7]
+			level, ok := data[i].([]interface{})
+			if !ok || len(level) < 8 {
+				continue
+			}
+			dataDict[fmt.Sprintf("Quantity-%d", i-22)] = level[0]
+			dataDict[fmt.Sprintf("OrderPrice-%d", i-22)] = level[1]
+			dataDict[fmt.Sprintf("TotalOrders-%d", i-22)] = level[2]
+			dataDict[fmt.Sprintf("Reserved-%d", i-22)] = level[3]
+			dataDict[fmt.Sprintf("SellQuantity-%d", i-22)] = level[4]
+			dataDict[fmt.Sprintf("SellOrderPrice-%d", i-22)] = level[5]
+			dataDict[fmt.Sprintf("SellTotalOrders-%d", i-22)] = level[6]
+			dataDict[fmt.Sprintf("SellReserved-%d", i-22)] = level[7]
 		}
 	} else if dataType == "1" {
 		dataDict["symbol"] = data[0]
@@ -713,7 +782,7 @@ func (b *BreezeInstance) parseData(data []interface{}) map[string]interface{} {
 			dataDict["trend"] = data[16]
 			dataDict["lowerCktLm"] = data[17]
 			dataDict["upperCktLm"] = data[18]
-			dataDict["ltt"] = time.Unix(int64(data[19].(float64)), 0).Format(time.RFC3339)
+			dataDict["ltt"] = tickTime(data[19])
 			dataDict["close"] = data[20]
 		} else if len(data) == 23 {
 			dataDict["OI"] = data[12]
@@ -725,13 +794,13 @@ func (b *BreezeInstance) parseData(data []interface{}) map[string]interface{} {
 			dataDict["trend"] = data[18]
 			dataDict["lowerCktLm"] = data[19]
 			dataDict["upperCktLm"] = data[20]
-			dataDict["ltt"] = time.Unix(int64(data[21].(float64)), 0).Format(time.RFC3339)
+			dataDict["ltt"] = tickTime(data[21])
 			dataDict["close"] = data[22]
 		}
 	} else {
 		dataDict["symbol"] = data[0]
-		dataDict["time"] = time.Unix(int64(data[1].(float64)), 0).Format(time.RFC3339)
-		dataDict["depth"] = b.parseMarketDepth(data[2].([][]string), exchange)
+		dataDict["time"] = tickTime(data[1])
+		dataDict["depth"] = b.parseMarketDepth(depthRows(data[2]), exchange)
 		dataDict["quotes"] = "Market Depth"
 	}
 	switch exchange {
@@ -780,22 +849,25 @@ func (b *BreezeInstance) apiUtil() error {
 	}
 	defer resp.Body.Close()
 
-	var jsonData map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&jsonData)
+	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
+	var jsonData map[string]interface{}
+	if err := json.Unmarshal(raw, &jsonData); err != nil {
+		return &APIError{StatusCode: resp.StatusCode, Message: b.ExceptMessage["CUSTOMERDETAILS_API_EXCEPTION"], Body: raw}
+	}
 
 	if success, ok := jsonData["Success"].(map[string]interface{}); ok {
 		if base64SessionToken, ok := success["session_token"].(string); ok {
 			result, err := base64.StdEncoding.DecodeString(base64SessionToken)
 			if err != nil {
-				return err
+				return &AuthError{Message: "invalid session token encoding", Err: err}
 			}
 			resultStr := string(result)
 			parts := strings.Split(resultStr, ":")
 			if len(parts) < 2 {
-				return errors.New("invalid session token format")
+				return &AuthError{Message: "invalid session token format"}
 			}
 			b.UserID = parts[0]
 			b.SessionKey = parts[1]
@@ -803,62 +875,24 @@ func (b *BreezeInstance) apiUtil() error {
 		}
 	}
 
-	if status, ok := jsonData["Status"].(float64); ok && int(status) != 200 {
-		if errMsg, ok := jsonData["Error"].(string); ok {
-			switch errMsg {
-			case "Invalid session.":
-				return errors.New(b.ExceptMessage["SESSIONKEY_INCORRECT"])
-			case "Public Key does not exist.":
-				return errors.New(b.ExceptMessage["APPKEY_INCORRECT"])
-			case "Resource not available.":
-				return errors.New(b.ExceptMessage["SESSIONKEY_EXPIRED"])
-			default:
-				return errors.New(b.ExceptMessage["CUSTOMERDETAILS_API_EXCEPTION"])
-			}
-		}
+	status := resp.StatusCode
+	if s, ok := jsonData["Status"].(float64); ok {
+		status = int(s)
 	}
-
-	return errors.New("unexpected format in API response")
+	errMsg, _ := jsonData["Error"].(string)
+	err = newAPIError(status, errMsg, raw, b.ExceptMessage)
+	if apiErr, ok := err.(*APIError); ok {
+		apiErr.Message = b.ExceptMessage["CUSTOMERDETAILS_API_EXCEPTION"]
+	}
+	return err
 }
 
 func (b *BreezeInstance) getStockScriptList() error {
-	b.StockScriptDictList = make([]map[string]string, 6)
-	b.TokenScriptDictList = make([]map[string][]string, 6)
-	resp, err := http.Get(b.StockScriptCSVURL)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	reader := csv.NewReader(resp.Body)
-	records, err := reader.ReadAll()
+	registry, err := b.loadInstruments()
 	if err != nil {
 		return err
 	}
-
-	for _, row := range records {
-		switch row[2] {
-		case "BSE":
-			b.StockScriptDictList[0][row[3]] = row[5]
-			b.TokenScriptDictList[0][row[5]] = []string{row[3], row[1]}
-		case "NSE":
-			b.StockScriptDictList[1][row[3]] = row[5]
-			b.TokenScriptDictList[1][row[5]] = []string{row[3], row[1]}
-		case "NDX":
-			b.StockScriptDictList[2][row[7]] = row[5]
-			b.TokenScriptDictList[2][row[5]] = []string{row[7], row[1]}
-		case "MCX":
-			b.StockScriptDictList[3][row[7]] = row[5]
-			b.TokenScriptDictList[3][row[5]] = []string{row[7], row[1]}
-		case "NFO":
-			b.StockScriptDictList[4][row[7]] = row[5]
-			b.TokenScriptDictList[4][row[5]] = []string{row[7], row[1]}
-		case "BFO":
-			b.StockScriptDictList[5][row[7]] = row[5]
-			b.TokenScriptDictList[5][row[5]] = []string{row[7], row[1]}
-		}
-	}
-
+	b.Instruments = registry
 	return nil
 }
 
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NSE and BSE continuous trading opens at 09:15 IST.
+const defaultSessionOpen = 9*time.Hour + 15*time.Minute
+
+// candleClosePeriod is how often EnableCandles checks the wall clock for
+// time bars to close.
+const candleClosePeriod = time.Second
+
+const candleTimeLayout = "2006-01-02 15:04:05"
+
+// CandleOptions selects the kind of bar a CandleBuilder produces. Exactly
+// one of Interval, Ticks or Volume must be set.
+type CandleOptions struct {
+	// Interval builds time bars aligned to SessionOpen, so 15-minute bars
+	// start at 09:15, 09:30 and so on.
+	Interval time.Duration
+	// Ticks closes a bar after that many ticks.
+	Ticks int
+	// Volume closes a bar once it has traded at least that many units.
+	Volume int64
+
+	// SessionOpen is the IST time of day time bars are aligned to; it
+	// defaults to 09:15. Set 9h for MCX.
+	SessionOpen time.Duration
+	// Grace holds a time bar open for this long after its end so ticks
+	// that arrive out of order still land in it. Ticks for a bar that has
+	// already been emitted are dropped and counted in Late.
+	Grace time.Duration
+}
+
+func (o CandleOptions) validate() error {
+	set := 0
+	if o.Interval > 0 {
+		set++
+	}
+	if o.Ticks > 0 {
+		set++
+	}
+	if o.Volume > 0 {
+		set++
+	}
+	if set != 1 {
+		return &ValidationError{Message: "Exactly one of interval, ticks or volume is required for candles"}
+	}
+	if o.Interval > 0 && o.Interval%time.Second != 0 {
+		return &ValidationError{Message: "Candle interval must be a whole number of seconds"}
+	}
+	return nil
+}
+
+// label names the bar in the "interval" field, in the style of
+// FeedIntervalMap: 3minute, 1hour, 500tick.
+func (o CandleOptions) label() string {
+	switch {
+	case o.Ticks > 0:
+		return fmt.Sprintf("%dtick", o.Ticks)
+	case o.Volume > 0:
+		return fmt.Sprintf("%dvolume", o.Volume)
+	case o.Interval%time.Hour == 0:
+		return fmt.Sprintf("%dhour", o.Interval/time.Hour)
+	case o.Interval%time.Minute == 0:
+		return fmt.Sprintf("%dminute", o.Interval/time.Minute)
+	}
+	return fmt.Sprintf("%dsecond", o.Interval/time.Second)
+}
+
+type candle struct {
+	start  time.Time
+	first  time.Time
+	last   time.Time
+	open   float64
+	high   float64
+	low    float64
+	close  float64
+	volume int64
+	oi     float64
+	ticks  int
+}
+
+// add folds in a trade at time at. Open and close follow the trade times,
+// not arrival order, so a tick that arrives late within Grace cannot
+// become the close.
+func (c *candle) add(at time.Time, price float64, volume int64, oi float64) {
+	if c.ticks == 0 {
+		c.open, c.high, c.low, c.close = price, price, price, price
+		c.first, c.last = at, at
+	}
+	if price > c.high {
+		c.high = price
+	}
+	if price < c.low {
+		c.low = price
+	}
+	if at.Before(c.first) {
+		c.open, c.first = price, at
+	}
+	if !at.Before(c.last) {
+		c.close, c.last = price, at
+		if oi != 0 {
+			c.oi = oi
+		}
+	}
+	c.volume += volume
+	c.ticks++
+}
+
+type candleSeries struct {
+	inst      Instrument
+	known     bool
+	exchange  string
+	lastTTQ   int64
+	open      map[int64]*candle
+	watermark time.Time
+	emitted   time.Time
+}
+
+// CandleBuilder aggregates quote ticks, as produced by parseData, into
+// candles of any interval or into tick and volume bars. Candles are
+// delivered to the handler as maps with the fields parseOhlcData produces,
+// in the order they close and outside the builder's lock, so the handler
+// may call back into the builder.
+type CandleBuilder struct {
+	opts    CandleOptions
+	lookup  func(symbol string) (Instrument, bool)
+	handler func(map[string]interface{})
+
+	mu       sync.Mutex
+	series   map[string]*candleSeries
+	late     int
+	finished []map[string]interface{}
+	detach   func()
+
+	// delivering is held by the goroutine passing finished to the
+	// handler.
+	delivering sync.Mutex
+}
+
+// NewCandleBuilder returns a builder that passes finished candles to
+// handler. lookup resolves a feed symbol to its instrument for the
+// exchange and contract fields; it may be nil.
+func NewCandleBuilder(opts CandleOptions, lookup func(symbol string) (Instrument, bool), handler func(map[string]interface{})) (*CandleBuilder, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if opts.SessionOpen == 0 {
+		opts.SessionOpen = defaultSessionOpen
+	}
+	return &CandleBuilder{
+		opts:    opts,
+		lookup:  lookup,
+		handler: handler,
+		series:  map[string]*candleSeries{},
+	}, nil
+}
+
+// EnableCandles hooks a CandleBuilder into the decoded ticks. Subscribe to
+// the quote feed of every instrument the candles should cover. Time bars
+// are also closed from the wall clock, so a bar is emitted Grace after
+// its end even when its symbol stops trading.
+func (b *BreezeInstance) EnableCandles(opts CandleOptions, handler func(map[string]interface{})) (*CandleBuilder, error) {
+	cb, err := NewCandleBuilder(opts, b.feedInstrument, handler)
+	if err != nil {
+		return nil, err
+	}
+	remove := b.addTickHandler(cb.Add)
+	done := make(chan struct{})
+	if opts.Interval > 0 {
+		go cb.closeBars(done, candleClosePeriod)
+	}
+	cb.detach = sync.OnceFunc(func() {
+		remove()
+		close(done)
+	})
+	return cb, nil
+}
+
+func (cb *CandleBuilder) closeBars(done <-chan struct{}, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			cb.Release(now)
+		}
+	}
+}
+
+// Stop detaches a builder returned by EnableCandles from the tick stream
+// and the wall clock. Candles still open are kept; call Flush to emit
+// them.
+func (cb *CandleBuilder) Stop() {
+	if cb.detach != nil {
+		cb.detach()
+	}
+}
+
+// Late returns the number of ticks dropped for arriving after their bar
+// was emitted.
+func (cb *CandleBuilder) Late() int {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.late
+}
+
+// Add folds one quote tick into its symbol's candle. Ticks without a
+// symbol, last price or trade time are ignored.
+func (cb *CandleBuilder) Add(tick map[string]interface{}) {
+	symbol, ok := tick["symbol"].(string)
+	if !ok {
+		return
+	}
+	price, ok := tickFloat(tick["last"])
+	if !ok {
+		return
+	}
+	ltt, _ := tick["ltt"].(string)
+	at, err := time.Parse(time.RFC3339, ltt)
+	if err != nil {
+		return
+	}
+
+	cb.mu.Lock()
+	cb.add(symbol, at, price, tick)
+	cb.mu.Unlock()
+	cb.deliver()
+}
+
+func (cb *CandleBuilder) add(symbol string, at time.Time, price float64, tick map[string]interface{}) {
+	s := cb.seriesFor(symbol, tick)
+	volume := s.tradedVolume(tick)
+	oi, _ := tickFloat(tick["OI"])
+
+	if cb.opts.Interval == 0 {
+		c := s.open[0]
+		if c == nil {
+			c = &candle{start: at}
+			s.open[0] = c
+		}
+		c.add(at, price, volume, oi)
+		if (cb.opts.Ticks > 0 && c.ticks >= cb.opts.Ticks) || (cb.opts.Volume > 0 && c.volume >= cb.opts.Volume) {
+			delete(s.open, 0)
+			cb.emit(s, c)
+		}
+		return
+	}
+
+	start := cb.bucket(at)
+	if !s.emitted.IsZero() && !start.After(s.emitted) {
+		cb.late++
+		return
+	}
+	c := s.open[start.Unix()]
+	if c == nil {
+		c = &candle{start: start}
+		s.open[start.Unix()] = c
+	}
+	c.add(at, price, volume, oi)
+	if at.After(s.watermark) {
+		s.watermark = at
+	}
+	cb.release(s, s.watermark)
+}
+
+// Release emits the time bars whose end plus Grace is not after now,
+// including those of symbols that have stopped ticking. Otherwise a bar
+// is only emitted when a later tick for its symbol arrives. EnableCandles
+// calls Release every second. A builder from NewCandleBuilder must be
+// driven the same way, or by Flush. Tick and volume bars are not
+// affected.
+func (cb *CandleBuilder) Release(now time.Time) {
+	if cb.opts.Interval == 0 || now.IsZero() {
+		return
+	}
+	cb.mu.Lock()
+	for _, s := range cb.series {
+		cb.release(s, now)
+	}
+	cb.mu.Unlock()
+	cb.deliver()
+}
+
+// Flush emits every candle still open, whether or not its interval has
+// ended; call it at the session close.
+func (cb *CandleBuilder) Flush() {
+	cb.mu.Lock()
+	for _, s := range cb.series {
+		if cb.opts.Interval == 0 {
+			if c := s.open[0]; c != nil {
+				delete(s.open, 0)
+				cb.emit(s, c)
+			}
+			continue
+		}
+		cb.release(s, time.Time{})
+	}
+	cb.mu.Unlock()
+	cb.deliver()
+}
+
+// deliver passes the finished candles to the handler, oldest first. Only
+// one goroutine delivers at a time; a caller that finds another one
+// delivering, including the handler itself, leaves its candles to it.
+func (cb *CandleBuilder) deliver() {
+	if !cb.delivering.TryLock() {
+		return
+	}
+	for {
+		cb.mu.Lock()
+		bars := cb.finished
+		cb.finished = nil
+		if len(bars) == 0 {
+			// Unlock under mu, so a candle queued after this check finds
+			// delivering free.
+			cb.delivering.Unlock()
+			cb.mu.Unlock()
+			return
+		}
+		cb.mu.Unlock()
+		for _, bar := range bars {
+			cb.handler(bar)
+		}
+	}
+}
+
+// release emits, oldest first, the open candles whose end plus Grace is
+// not after now. A zero now releases them all.
+func (cb *CandleBuilder) release(s *candleSeries, now time.Time) {
+	keys := make([]int64, 0, len(s.open))
+	for k := range s.open {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, k := range keys {
+		c := s.open[k]
+		if !now.IsZero() && c.start.Add(cb.opts.Interval+cb.opts.Grace).After(now) {
+			break
+		}
+		delete(s.open, k)
+		s.emitted = c.start
+		cb.emit(s, c)
+	}
+}
+
+// bucket returns the start of the time bar holding at, counted in whole
+// intervals from the session open of at's IST day.
+func (cb *CandleBuilder) bucket(at time.Time) time.Time {
+	local := at.In(istLocation)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, istLocation)
+	offset := local.Sub(day.Add(cb.opts.SessionOpen))
+	n := offset / cb.opts.Interval
+	if offset < 0 && offset%cb.opts.Interval != 0 {
+		n--
+	}
+	return day.Add(cb.opts.SessionOpen + n*cb.opts.Interval)
+}
+
+func (cb *CandleBuilder) seriesFor(symbol string, tick map[string]interface{}) *candleSeries {
+	if s, ok := cb.series[symbol]; ok {
+		return s
+	}
+	s := &candleSeries{open: map[int64]*candle{}}
+	if cb.lookup != nil {
+		s.inst, s.known = cb.lookup(symbol)
+	}
+	if s.known {
+		s.exchange = s.inst.Exchange
+	} else {
+		s.inst.ISECCode, _ = tick["stock_name"].(string)
+		if s.inst.ISECCode == "" {
+			s.inst.ISECCode = symbol
+		}
+		if prefix, _, ok := strings.Cut(symbol, "."); ok {
+			s.exchange = feedExchangeCodes[prefix]
+		}
+	}
+	cb.series[symbol] = s
+	return s
+}
+
+// tradedVolume is the volume since the symbol's previous tick, taken from
+// the change in total traded quantity so trades between ticks are not
+// lost. The first tick of a series, or one after ttq resets, counts ltq.
+func (s *candleSeries) tradedVolume(tick map[string]interface{}) int64 {
+	ltq, _ := tickFloat(tick["ltq"])
+	ttq, ok := tickFloat(tick["ttq"])
+	if !ok {
+		return int64(ltq)
+	}
+	previous := s.lastTTQ
+	s.lastTTQ = int64(ttq)
+	if previous == 0 || int64(ttq) < previous {
+		return int64(ltq)
+	}
+	return int64(ttq) - previous
+}
+
+// emit queues a finished candle for deliver; cb.mu is held.
+func (cb *CandleBuilder) emit(s *candleSeries, c *candle) {
+	if cb.handler == nil {
+		return
+	}
+	format := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	out := map[string]interface{}{
+		"interval":      cb.opts.label(),
+		"exchange_code": s.exchange,
+		"stock_code":    s.inst.ISECCode,
+		"low":           format(c.low),
+		"high":          format(c.high),
+		"open":          format(c.open),
+		"close":         format(c.close),
+		"volume":        strconv.FormatInt(c.volume, 10),
+		"datetime":      c.start.In(istLocation).Format(candleTimeLayout),
+	}
+	if s.known && s.inst.ProductType() != "cash" {
+		out["expiry_date"] = s.inst.ExpiryDate
+		out["oi"] = format(c.oi)
+		if s.inst.ProductType() == "options" {
+			out["strike_price"] = format(s.inst.StrikePrice)
+			out["right_type"] = s.inst.Right
+		}
+	}
+	cb.finished = append(cb.finished, out)
+}
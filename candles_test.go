@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func candleTick(symbol string, at time.Time, last float64, ttq int) map[string]interface{} {
+	return map[string]interface{}{
+		"symbol": symbol,
+		"last":   last,
+		"ltq":    10.0,
+		"ttq":    float64(ttq),
+		"ltt":    at.Format(time.RFC3339),
+	}
+}
+
+func TestCandleBuilderReleasesQuietSymbols(t *testing.T) {
+	var got []map[string]interface{}
+	cb, err := NewCandleBuilder(CandleOptions{Interval: time.Minute, Grace: 5 * time.Second}, nil,
+		func(c map[string]interface{}) { got = append(got, c) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hms string) time.Time {
+		v, _ := time.ParseInLocation(candleTimeLayout, "2024-01-25 "+hms, istLocation)
+		return v
+	}
+	cb.Add(candleTick("4.1!2885", at("09:15:10"), 2450, 100))
+	cb.Add(candleTick("4.1!2885", at("09:15:40"), 2452.5, 130))
+
+	cb.Release(at("09:16:04"))
+	if len(got) != 0 {
+		t.Fatalf("bar released %d times inside its grace", len(got))
+	}
+	cb.Release(time.Time{})
+	if len(got) != 0 {
+		t.Fatal("a zero time released the bar")
+	}
+	cb.Release(at("09:16:05"))
+	if len(got) != 1 {
+		t.Fatalf("%d bars after end+Grace, want 1", len(got))
+	}
+	if c := got[0]; c["datetime"] != "2024-01-25 09:15:00" || c["open"] != "2450" || c["close"] != "2452.5" || c["volume"] != "40" {
+		t.Errorf("bar = %v", c)
+	}
+
+	// The bar is gone, so a straggler for it counts as late.
+	cb.Add(candleTick("4.1!2885", at("09:15:50"), 2451, 140))
+	if cb.Late() != 1 {
+		t.Errorf("Late() = %d, want 1", cb.Late())
+	}
+}
+
+// TestCandleHandlerCallsBack has the handler read Late and add a tick of
+// its own, which must neither deadlock nor reorder the bars.
+func TestCandleHandlerCallsBack(t *testing.T) {
+	at := time.Date(2024, 1, 25, 9, 15, 10, 0, istLocation)
+	var cb *CandleBuilder
+	var starts []interface{}
+	cb, err := NewCandleBuilder(CandleOptions{Interval: time.Minute}, nil, func(c map[string]interface{}) {
+		starts = append(starts, c["datetime"])
+		cb.Late()
+		if len(starts) == 1 {
+			cb.Add(candleTick("4.1!2885", at.Add(2*time.Minute), 2452, 120))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb.Add(candleTick("4.1!2885", at, 2450, 100))
+	cb.Add(candleTick("4.1!2885", at.Add(time.Minute), 2451, 110))
+	cb.Flush()
+
+	want := []interface{}{"2024-01-25 09:15:00", "2024-01-25 09:16:00", "2024-01-25 09:17:00"}
+	if len(starts) != len(want) {
+		t.Fatalf("bars = %v, want %v", starts, want)
+	}
+	for i := range want {
+		if starts[i] != want[i] {
+			t.Errorf("bars = %v, want %v", starts, want)
+			break
+		}
+	}
+}
+
+func TestEnableCandlesClosesFromWallClock(t *testing.T) {
+	b := NewBreezeInstance("")
+	bars := make(chan map[string]interface{}, 1)
+	cb, err := b.EnableCandles(CandleOptions{Interval: time.Second}, func(c map[string]interface{}) { bars <- c })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cb.Stop()
+
+	// One tick, and nothing after it to push the bar out.
+	b.tickHandlers.dispatch(candleTick("4.1!2885", time.Now().Add(-3*time.Second), 2450, 100))
+	select {
+	case <-bars:
+	case <-time.After(3 * candleClosePeriod):
+		t.Fatal("the bar of a quiet symbol was never closed")
+	}
+
+	cb.Stop()
+	cb.Stop()
+	if b.tickHandlers.active() {
+		t.Error("tick handler still registered after Stop")
+	}
+}
@@ -14,6 +14,7 @@ var (
 	TRANSACTION_TYPES      = []string{"debit", "credit"}
 	INTERVAL_TYPES         = []string{"1minute", "5minute", "30minute", "1day"}
 	INTERVAL_TYPES_HIST_V2 = []string{"1second", "1minute", "5minute", "30minute", "1day"}
+	INTERVAL_TYPES_STREAM  = []string{"1second", "1minute", "5minute", "30minute"}
 	PRODUCT_TYPES          = []string{"futures", "options", "futureplus", "optionplus", "cash", "eatm", "margin", "mtf", "btst"}
 	PRODUCT_TYPES_HIST     = []string{"futures", "options", "futureplus", "optionplus"}
 	PRODUCT_TYPES_HIST_V2  = []string{"futures", "options", "cash"}
@@ -57,3 +58,37 @@ const (
 	LIMIT_CALCULATOR   APIEndPoint = "fnolmtpriceandqtycal"
 	MARGIN_CALCULATOR  APIEndPoint = "margincalculator"
 )
+
+var EXCEPTION_MESSAGE = map[string]string{
+	"AUTHENICATION_EXCEPTION":       "Could not authenticate credentials. Please check token and keys",
+	"QUOTE_DEPTH_EXCEPTION":         "Either getExchangeQuotes must be true or getMarketDepth must be true",
+	"EXCHANGE_CODE_EXCEPTION":       "Exchange Code allowed are 'BSE', 'NSE', 'NDX', 'MCX', 'NFO' or 'BFO'.",
+	"STOCK_CODE_EXCEPTION":          "Stock-Code cannot be empty.",
+	"EXPIRY_DATE_EXCEPTION":         "Expiry-Date cannot be empty for given Exchange-Code.",
+	"PRODUCT_TYPE_EXCEPTION":        "Product-Type should either be Futures or Options for given Exchange-Code.",
+	"STRIKE_PRICE_EXCEPTION":        "Strike Price cannot be empty for Product-Type 'Options'.",
+	"RIGHT_EXCEPTION":               "Rights should either be Put or Call for Product-Type 'Options'.",
+	"STOCK_INVALID_EXCEPTION":       "Stock-Code not found.",
+	"WRONG_EXCHANGE_CODE_EXCEPTION": "Stock-Token cannot be found due to wrong exchange-code.",
+	"STOCK_NOT_EXIST_EXCEPTION":     "Stock-Data does not exist in exchange-code %s for Stock-Token %s.",
+	"SESSIONKEY_INCORRECT":          "Session Key is incorrect.",
+	"APPKEY_INCORRECT":              "AppKey is incorrect.",
+	"SESSIONKEY_EXPIRED":            "Session Key is expired.",
+	"CUSTOMERDETAILS_API_EXCEPTION": "Unable to retrieve customer details at the moment.",
+	"STREAM_OHLC_INTERVAL_ERROR":    "Interval should be either 1second, 1minute, 5minute or 30minute.",
+}
+
+var RESPONSE_MESSAGE = map[string]string{
+	"RATE_REFRESH_NOT_CONNECTED":    "socket server for rate refresh has not been connected.",
+	"RATE_REFRESH_DISCONNECTED":     "socket server for rate refresh has been disconnected.",
+	"ORDER_REFRESH_NOT_CONNECTED":   "socket server for order streaming has not been connected.",
+	"ORDER_REFRESH_DISCONNECTED":    "socket server for order streaming has been disconnected.",
+	"OHLCV_STREAM_NOT_CONNECTED":    "socket server for ohlcv streaming has not been connected.",
+	"OHLCV_STREAM_DISCONNECTED":     "socket server for ohlcv streaming has been disconnected.",
+	"ORDER_NOTIFICATION_SUBSRIBED":  "Order Notification subscribed successfully",
+	"STOCK_SUBSCRIBE_MESSAGE":       "Stock %s subscribed successfully",
+	"STOCK_UNSUBSCRIBE_MESSAGE":     "Stock %s unsubscribed successfully",
+	"STRATEGY_STREAM_SUBSCRIBED":    "%s streaming subscribed successfully.",
+	"STRATEGY_STREAM_UNSUBSCRIBED":  "%s streaming unsubscribed successfully.",
+	"STRATEGY_STREAM_NOT_CONNECTED": "socket server for strategy streaming has not been connected.",
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// PriceLevel is one rung of a DepthBook ladder.
+type PriceLevel struct {
+	Price  float64
+	Qty    int64
+	Orders int64
+}
+
+// DepthBook is the bid and ask ladder of one instrument, kept current from
+// its depth ticks. Bids are ordered best (highest) first and asks best
+// (lowest) first. It is safe for concurrent use.
+type DepthBook struct {
+	Symbol   string
+	Exchange string
+	Token    string
+
+	mu        sync.RWMutex
+	bids      []PriceLevel
+	asks      []PriceLevel
+	updatedAt time.Time
+}
+
+// Apply replaces the ladders with the levels of a depth or MCX tick and
+// reports whether the book changed. The feed sends the top of the book in
+// full on every tick, so levels missing from it are gone; empty rows are
+// skipped. A tick older than the one last applied is ignored: depth ticks
+// are compared by their timestamp and MCX ticks by their last trade time,
+// so MCX updates that share a trade time are all applied.
+func (db *DepthBook) Apply(t *Tick) bool {
+	var levels []DepthLevel
+	var at time.Time
+	switch t.Kind {
+	case TickDepth:
+		levels, at = t.Depth.Levels[:t.Depth.NumLevels], t.Depth.Time
+	case TickCommodity:
+		levels, at = t.Commodity.Levels[:t.Commodity.NumLevels], t.Commodity.LTT
+	default:
+		return false
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if at.Before(db.updatedAt) {
+		return false
+	}
+	db.bids, db.asks = db.bids[:0], db.asks[:0]
+	for _, l := range levels {
+		if l.BuyPrice > 0 && l.BuyQty > 0 {
+			db.bids = append(db.bids, PriceLevel{Price: l.BuyPrice, Qty: l.BuyQty, Orders: l.BuyOrders})
+		}
+		if l.SellPrice > 0 && l.SellQty > 0 {
+			db.asks = append(db.asks, PriceLevel{Price: l.SellPrice, Qty: l.SellQty, Orders: l.SellOrders})
+		}
+	}
+	sort.SliceStable(db.bids, func(i, j int) bool { return db.bids[i].Price > db.bids[j].Price })
+	sort.SliceStable(db.asks, func(i, j int) bool { return db.asks[i].Price < db.asks[j].Price })
+	if at.After(db.updatedAt) {
+		db.updatedAt = at
+	}
+	return true
+}
+
+// UpdatedAt returns the timestamp, or for MCX the last trade time, of the
+// newest tick applied.
+func (db *DepthBook) UpdatedAt() time.Time {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.updatedAt
+}
+
+// Bids returns a copy of the best n bid levels, all of them when n <= 0.
+func (db *DepthBook) Bids(n int) []PriceLevel {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return append([]PriceLevel(nil), topLevels(db.bids, n)...)
+}
+
+// Asks returns a copy of the best n ask levels, all of them when n <= 0.
+func (db *DepthBook) Asks(n int) []PriceLevel {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return append([]PriceLevel(nil), topLevels(db.asks, n)...)
+}
+
+func topLevels(levels []PriceLevel, n int) []PriceLevel {
+	if n <= 0 || n > len(levels) {
+		return levels
+	}
+	return levels[:n]
+}
+
+// BestBid returns the highest bid; ok is false when there are no bids.
+func (db *DepthBook) BestBid() (level PriceLevel, ok bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if len(db.bids) == 0 {
+		return PriceLevel{}, false
+	}
+	return db.bids[0], true
+}
+
+// BestAsk returns the lowest ask; ok is false when there are no asks.
+func (db *DepthBook) BestAsk() (level PriceLevel, ok bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if len(db.asks) == 0 {
+		return PriceLevel{}, false
+	}
+	return db.asks[0], true
+}
+
+// touch returns the best bid and ask under one read lock, so the metrics
+// below never mix two ticks.
+func (db *DepthBook) touch() (bid, ask PriceLevel, ok bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if len(db.bids) == 0 || len(db.asks) == 0 {
+		return PriceLevel{}, PriceLevel{}, false
+	}
+	return db.bids[0], db.asks[0], true
+}
+
+// Spread returns the best ask less the best bid. It and the other metrics
+// report ok false while either side of the book is empty.
+func (db *DepthBook) Spread() (float64, bool) {
+	bid, ask, ok := db.touch()
+	if !ok {
+		return 0, false
+	}
+	return ask.Price - bid.Price, true
+}
+
+// Mid returns the midpoint of the best bid and ask.
+func (db *DepthBook) Mid() (float64, bool) {
+	bid, ask, ok := db.touch()
+	if !ok {
+		return 0, false
+	}
+	return (bid.Price + ask.Price) / 2, true
+}
+
+// Microprice returns the mid weighted by the opposite side's size,
+// (bid*askQty + ask*bidQty) / (bidQty + askQty), which leans towards the
+// side more likely to trade through.
+func (db *DepthBook) Microprice() (float64, bool) {
+	bid, ask, ok := db.touch()
+	if !ok {
+		return 0, false
+	}
+	total := float64(bid.Qty + ask.Qty)
+	return (bid.Price*float64(ask.Qty) + ask.Price*float64(bid.Qty)) / total, true
+}
+
+// CumulativeDepth returns the bid and ask quantity over the best levels
+// levels of each side, the whole book when levels <= 0.
+func (db *DepthBook) CumulativeDepth(levels int) (bidQty, askQty int64) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, l := range topLevels(db.bids, levels) {
+		bidQty += l.Qty
+	}
+	for _, l := range topLevels(db.asks, levels) {
+		askQty += l.Qty
+	}
+	return bidQty, askQty
+}
+
+// Imbalance returns (bid - ask) / (bid + ask) over the cumulative depth of
+// the best levels levels, from -1 (all asks) to 1 (all bids).
+func (db *DepthBook) Imbalance(levels int) (float64, bool) {
+	bidQty, askQty := db.CumulativeDepth(levels)
+	if bidQty+askQty == 0 {
+		return 0, false
+	}
+	return float64(bidQty-askQty) / float64(bidQty+askQty), true
+}
+
+// DepthBooks holds a DepthBook for every instrument whose depth has been
+// received, keyed by feed symbol ("4.2!2885" for NSE depth, "6.1!..." for
+// MCX).
+type DepthBooks struct {
+	mu     sync.RWMutex
+	books  map[string]*DepthBook
+	detach func()
+}
+
+// NewDepthBooks returns an empty set of books to feed through Apply.
+func NewDepthBooks() *DepthBooks {
+	return &DepthBooks{books: map[string]*DepthBook{}}
+}
+
+// EnableDepthBooks hooks DepthBooks into the decoded ticks. Subscribe to the
+// market depth feed of every instrument to track.
+func (b *BreezeInstance) EnableDepthBooks() *DepthBooks {
+	books := NewDepthBooks()
+	books.detach = b.addTypedTickHandler(func(t *Tick) { books.Apply(t) })
+	return books
+}
+
+// Stop detaches books returned by EnableDepthBooks from the tick stream.
+// The ladders keep their last state.
+func (dbs *DepthBooks) Stop() {
+	if dbs.detach != nil {
+		dbs.detach()
+	}
+}
+
+// Apply routes a depth or MCX tick to its instrument's book, creating the
+// book when its first tick is applied.
+func (dbs *DepthBooks) Apply(t *Tick) bool {
+	var symbol, exchange, token string
+	switch t.Kind {
+	case TickDepth:
+		symbol, exchange, token = t.Depth.Symbol, t.Depth.Exchange, t.Depth.Token
+	case TickCommodity:
+		symbol, exchange, token = t.Commodity.Symbol, "6", t.Commodity.Token
+	default:
+		return false
+	}
+	dbs.mu.RLock()
+	book, ok := dbs.books[symbol]
+	dbs.mu.RUnlock()
+	if ok {
+		return book.Apply(t)
+	}
+	// Only a book that took its first tick is published.
+	book = &DepthBook{Symbol: symbol, Exchange: exchange, Token: token}
+	if !book.Apply(t) {
+		return false
+	}
+	dbs.mu.Lock()
+	if existing, ok := dbs.books[symbol]; ok {
+		dbs.mu.Unlock()
+		return existing.Apply(t)
+	}
+	dbs.books[symbol] = book
+	dbs.mu.Unlock()
+	return true
+}
+
+// Book returns the book of a feed symbol; ok is false until a depth or
+// MCX tick for it has been applied.
+func (dbs *DepthBooks) Book(symbol string) (*DepthBook, bool) {
+	dbs.mu.RLock()
+	defer dbs.mu.RUnlock()
+	book, ok := dbs.books[symbol]
+	return book, ok
+}
+
+// Symbols returns the feed symbols of every book, sorted.
+func (dbs *DepthBooks) Symbols() []string {
+	dbs.mu.RLock()
+	defer dbs.mu.RUnlock()
+	out := make([]string, 0, len(dbs.books))
+	for symbol := range dbs.books {
+		out = append(out, symbol)
+	}
+	sort.Strings(out)
+	return out
+}
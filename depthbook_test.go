@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func depthTick(at time.Time, levels ...DepthLevel) *Tick {
+	t := &Tick{Kind: TickDepth}
+	t.Depth.Symbol, t.Depth.Exchange, t.Depth.Token = "4.2!2885", "4", "2885"
+	t.Depth.Time = at
+	t.Depth.NumLevels = copy(t.Depth.Levels[:], levels)
+	return t
+}
+
+func TestDepthBookMetrics(t *testing.T) {
+	at := time.Date(2024, 1, 25, 10, 0, 0, 0, istLocation)
+	touch := func(bidQty, askQty int64) DepthLevel {
+		return DepthLevel{BuyPrice: 100, BuyQty: bidQty, SellPrice: 101, SellQty: askQty}
+	}
+	second := func(bidQty, askQty int64) DepthLevel {
+		return DepthLevel{BuyPrice: 99.95, BuyQty: bidQty, SellPrice: 101.05, SellQty: askQty}
+	}
+	tests := []struct {
+		name       string
+		levels     []DepthLevel
+		levelsUsed int
+		micro      float64
+		microOK    bool
+		imbalance  float64
+		imbOK      bool
+	}{
+		{"balanced touch", []DepthLevel{touch(100, 100)}, 0, 100.5, true, 0, true},
+		{"heavy bid leans to the ask", []DepthLevel{touch(300, 100)}, 0, 100.75, true, 0.5, true},
+		{"heavy ask leans to the bid", []DepthLevel{touch(100, 300)}, 0, 100.25, true, -0.5, true},
+		{"imbalance over the whole book", []DepthLevel{touch(300, 100), second(100, 100)}, 0, 100.75, true, 1.0 / 3, true},
+		{"imbalance over the best level", []DepthLevel{touch(300, 100), second(100, 500)}, 1, 100.75, true, 0.5, true},
+		{"bids only", []DepthLevel{{BuyPrice: 100, BuyQty: 100}}, 0, 0, false, 1, true},
+		{"empty book", nil, 0, 0, false, 0, false},
+	}
+	for _, tt := range tests {
+		book := &DepthBook{}
+		book.Apply(depthTick(at, tt.levels...))
+
+		micro, ok := book.Microprice()
+		if ok != tt.microOK || math.Abs(micro-tt.micro) > 1e-9 {
+			t.Errorf("%s: Microprice = %v, %v; want %v, %v", tt.name, micro, ok, tt.micro, tt.microOK)
+		}
+		imbalance, ok := book.Imbalance(tt.levelsUsed)
+		if ok != tt.imbOK || math.Abs(imbalance-tt.imbalance) > 1e-9 {
+			t.Errorf("%s: Imbalance(%d) = %v, %v; want %v, %v", tt.name, tt.levelsUsed, imbalance, ok, tt.imbalance, tt.imbOK)
+		}
+	}
+}
+
+func TestDepthBookIgnoresStaleTicks(t *testing.T) {
+	at := time.Date(2024, 1, 25, 10, 0, 0, 0, istLocation)
+	commodity := func(ltt time.Time, bid float64) *Tick {
+		t := &Tick{Kind: TickCommodity}
+		t.Commodity.Symbol, t.Commodity.Token = "6.1!245470", "245470"
+		t.Commodity.LTT = ltt
+		t.Commodity.Levels[0] = DepthLevel{BuyPrice: bid, BuyQty: 1, SellPrice: bid + 1, SellQty: 1}
+		t.Commodity.NumLevels = 1
+		return t
+	}
+	tests := []struct {
+		name  string
+		fresh *Tick
+		same  *Tick
+		stale *Tick
+	}{
+		{
+			"depth",
+			depthTick(at, DepthLevel{BuyPrice: 100, BuyQty: 1, SellPrice: 101, SellQty: 1}),
+			depthTick(at, DepthLevel{BuyPrice: 100.05, BuyQty: 1, SellPrice: 101, SellQty: 1}),
+			depthTick(at.Add(-time.Second), DepthLevel{BuyPrice: 99, BuyQty: 1, SellPrice: 101, SellQty: 1}),
+		},
+		{"commodity", commodity(at, 100), commodity(at, 100.05), commodity(at.Add(-time.Second), 99)},
+	}
+	for _, tt := range tests {
+		book := &DepthBook{}
+		if !book.Apply(tt.fresh) {
+			t.Fatalf("%s: first tick not applied", tt.name)
+		}
+		if !book.Apply(tt.same) {
+			t.Errorf("%s: tick with the same time not applied", tt.name)
+		}
+		if book.Apply(tt.stale) {
+			t.Errorf("%s: older tick applied", tt.name)
+		}
+		if bid, _ := book.BestBid(); bid.Price != 100.05 {
+			t.Errorf("%s: best bid = %v, want 100.05", tt.name, bid.Price)
+		}
+		if !book.UpdatedAt().Equal(at) {
+			t.Errorf("%s: UpdatedAt = %v, want %v", tt.name, book.UpdatedAt(), at)
+		}
+	}
+}
+
+func TestDepthBooksRouting(t *testing.T) {
+	books := NewDepthBooks()
+	if books.Apply(&Tick{Kind: TickQuote}) {
+		t.Error("a quote tick was applied")
+	}
+	if _, ok := books.Book("4.2!2885"); ok {
+		t.Error("book exists before any depth tick")
+	}
+	at := time.Date(2024, 1, 25, 10, 0, 0, 0, istLocation)
+	if !books.Apply(depthTick(at, DepthLevel{BuyPrice: 100, BuyQty: 1, SellPrice: 101, SellQty: 1})) {
+		t.Fatal("first depth tick not applied")
+	}
+	book, ok := books.Book("4.2!2885")
+	if !ok || book.Exchange != "4" || book.Token != "2885" {
+		t.Fatalf("Book = %+v, %v", book, ok)
+	}
+	if books.Apply(depthTick(at.Add(-time.Second), DepthLevel{BuyPrice: 99, BuyQty: 1})) {
+		t.Error("stale tick applied through DepthBooks")
+	}
+	if got := books.Symbols(); len(got) != 1 || got[0] != "4.2!2885" {
+		t.Errorf("Symbols() = %v", got)
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+// Engine.IO v4 packet types.
+const (
+	eioOpen    = '0'
+	eioClose   = '1'
+	eioPing    = '2'
+	eioPong    = '3'
+	eioMessage = '4'
+)
+
+// Socket.IO v5 packet types, carried inside an Engine.IO message.
+const (
+	sioConnect      = '0'
+	sioDisconnect   = '1'
+	sioEvent        = '2'
+	sioConnectError = '4'
+)
+
+const (
+	socketIOUserAgent        = "python-socketio[client]/socket"
+	socketIOHandshakeTimeout = 10 * time.Second
+)
+
+// socketIOClient is a minimal Socket.IO client over the Engine.IO v4
+// websocket transport: it performs the handshake, answers pings, connects
+// one namespace with an auth payload and exchanges events.
+type socketIOClient struct {
+	conn         *websocket.Conn
+	namespace    string
+	sid          string
+	pingInterval time.Duration
+	pingTimeout  time.Duration
+	writeMu      sync.Mutex
+}
+
+type engineIOHandshake struct {
+	SID          string   `json:"sid"`
+	Upgrades     []string `json:"upgrades"`
+	PingInterval int      `json:"pingInterval"`
+	PingTimeout  int      `json:"pingTimeout"`
+	MaxPayload   int      `json:"maxPayload"`
+}
+
+// socketIOURL turns an http(s) endpoint into the websocket URL of its
+// Engine.IO transport, mounted at path.
+func socketIOURL(hostname, path string) (string, error) {
+	u, err := url.Parse(hostname)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https", "wss":
+		u.Scheme = "wss"
+	case "http", "ws":
+		u.Scheme = "ws"
+	default:
+		return "", fmt.Errorf("unsupported socket scheme %q", u.Scheme)
+	}
+	u.Path = path
+	q := u.Query()
+	q.Set("EIO", "4")
+	q.Set("transport", "websocket")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func dialSocketIO(ctx context.Context, hostname, path, namespace string, auth interface{}) (*socketIOClient, *http.Response, error) {
+	endpoint, err := socketIOURL(hostname, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := http.Header{}
+	header.Set("User-Agent", socketIOUserAgent)
+	conn, resp, err := websocket.Dial(ctx, endpoint, &websocket.DialOptions{HTTPHeader: header})
+	if err != nil {
+		return nil, resp, err
+	}
+	conn.SetReadLimit(1 << 22)
+
+	if namespace == "" {
+		namespace = "/"
+	}
+	c := &socketIOClient{conn: conn, namespace: namespace}
+	handshakeCtx, cancel := context.WithTimeout(ctx, socketIOHandshakeTimeout)
+	defer cancel()
+	if err := c.handshake(handshakeCtx, auth); err != nil {
+		conn.Close(websocket.StatusPolicyViolation, "handshake failed")
+		return nil, resp, err
+	}
+	return c, resp, nil
+}
+
+func (c *socketIOClient) handshake(ctx context.Context, auth interface{}) error {
+	packet, err := c.readPacket(ctx)
+	if err != nil {
+		return err
+	}
+	if len(packet) == 0 || packet[0] != eioOpen {
+		return fmt.Errorf("engine.io: expected open packet, got %q", packet)
+	}
+	var open engineIOHandshake
+	if err := json.Unmarshal([]byte(packet[1:]), &open); err != nil {
+		return fmt.Errorf("engine.io: invalid open packet: %w", err)
+	}
+	c.pingInterval = time.Duration(open.PingInterval) * time.Millisecond
+	c.pingTimeout = time.Duration(open.PingTimeout) * time.Millisecond
+
+	authJSON, err := json.Marshal(auth)
+	if err != nil {
+		return err
+	}
+	if err := c.writePacket(ctx, string([]byte{eioMessage, sioConnect})+c.namespacePrefix()+string(authJSON)); err != nil {
+		return err
+	}
+
+	for {
+		packet, err := c.readPacket(ctx)
+		if err != nil {
+			return err
+		}
+		switch {
+		case packet == string(eioPing):
+			if err := c.writePacket(ctx, string(eioPong)); err != nil {
+				return err
+			}
+		case len(packet) > 1 && packet[0] == eioMessage:
+			typ, nsp, payload := parseSocketIOPacket(packet[1:])
+			if nsp != c.namespace {
+				continue
+			}
+			switch typ {
+			case sioConnect:
+				var ack struct {
+					SID string `json:"sid"`
+				}
+				json.Unmarshal([]byte(payload), &ack)
+				c.sid = ack.SID
+				return nil
+			case sioConnectError:
+				var refusal struct {
+					Message string `json:"message"`
+				}
+				if json.Unmarshal([]byte(payload), &refusal) != nil || refusal.Message == "" {
+					refusal.Message = payload
+				}
+				return &AuthError{Message: refusal.Message}
+			}
+		}
+	}
+}
+
+func (c *socketIOClient) namespacePrefix() string {
+	if c.namespace == "" || c.namespace == "/" {
+		return ""
+	}
+	return c.namespace + ","
+}
+
+// parseSocketIOPacket splits a Socket.IO packet into its type, namespace
+// and JSON payload, dropping any ack id.
+func parseSocketIOPacket(packet string) (byte, string, string) {
+	if packet == "" {
+		return 0, "", ""
+	}
+	typ := packet[0]
+	rest := packet[1:]
+	nsp := "/"
+	if strings.HasPrefix(rest, "/") {
+		if i := strings.IndexByte(rest, ','); i >= 0 {
+			nsp, rest = rest[:i], rest[i+1:]
+		} else {
+			nsp, rest = rest, ""
+		}
+	}
+	i := 0
+	for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+		i++
+	}
+	return typ, nsp, rest[i:]
+}
+
+// encodeSocketIOEvent builds the "42" frame for an event and its arguments.
+func encodeSocketIOEvent(namespacePrefix, event string, args ...interface{}) (string, error) {
+	payload := make([]interface{}, 0, len(args)+1)
+	payload = append(payload, event)
+	payload = append(payload, args...)
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return string([]byte{eioMessage, sioEvent}) + namespacePrefix + string(data), nil
+}
+
+func (c *socketIOClient) Emit(ctx context.Context, event string, args ...interface{}) error {
+	frame, err := encodeSocketIOEvent(c.namespacePrefix(), event, args...)
+	if err != nil {
+		return err
+	}
+	return c.writePacket(ctx, frame)
+}
+
+// ReadEvent blocks until the next event on the namespace, answering pings on
+// the way. A missed ping, a close packet or a namespace disconnect is
+// returned as an error so the caller can reconnect.
+func (c *socketIOClient) ReadEvent(ctx context.Context) (string, []json.RawMessage, error) {
+	for {
+		readCtx := ctx
+		var cancel context.CancelFunc
+		if c.pingInterval > 0 {
+			readCtx, cancel = context.WithTimeout(ctx, c.pingInterval+c.pingTimeout)
+		}
+		packet, err := c.readPacket(readCtx)
+		// The websocket closes the connection when readCtx expires and does
+		// not always wrap its error, so ask the context what happened.
+		timedOut := ctx.Err() == nil && readCtx.Err() != nil
+		if cancel != nil {
+			cancel()
+		}
+		if err != nil {
+			if timedOut {
+				return "", nil, errors.New("engine.io: ping timeout")
+			}
+			return "", nil, err
+		}
+		if packet == "" {
+			continue
+		}
+		switch packet[0] {
+		case eioPing:
+			if err := c.writePacket(ctx, string(eioPong)+packet[1:]); err != nil {
+				return "", nil, err
+			}
+		case eioClose:
+			return "", nil, errors.New("engine.io: server closed the session")
+		case eioMessage:
+			typ, nsp, payload := parseSocketIOPacket(packet[1:])
+			if nsp != c.namespace {
+				continue
+			}
+			switch typ {
+			case sioDisconnect:
+				return "", nil, errors.New("socket.io: namespace disconnected by server")
+			case sioEvent:
+				var parts []json.RawMessage
+				if err := json.Unmarshal([]byte(payload), &parts); err != nil || len(parts) == 0 {
+					continue
+				}
+				var event string
+				if json.Unmarshal(parts[0], &event) != nil {
+					continue
+				}
+				return event, parts[1:], nil
+			}
+		}
+	}
+}
+
+func (c *socketIOClient) readPacket(ctx context.Context) (string, error) {
+	typ, data, err := c.conn.Read(ctx)
+	if err != nil {
+		return "", err
+	}
+	if typ != websocket.MessageText {
+		return "", nil
+	}
+	return string(data), nil
+}
+
+func (c *socketIOClient) writePacket(ctx context.Context, packet string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.Write(ctx, websocket.MessageText, []byte(packet))
+}
+
+func (c *socketIOClient) Close() error {
+	c.writePacket(context.Background(), string([]byte{eioMessage, sioDisconnect})+c.namespacePrefix())
+	return c.conn.Close(websocket.StatusNormalClosure, "transport close")
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+// scriptedSocketServer accepts one websocket and hands it to script, for
+// exchanges fakeSocketServer does not model. Whatever script returns is
+// reported through the returned channel.
+func scriptedSocketServer(t *testing.T, script func(ctx context.Context, c *websocket.Conn) error) (string, <-chan error) {
+	t.Helper()
+	done := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			done <- err
+			return
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		done <- script(ctx, c)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, done
+}
+
+func writeText(ctx context.Context, c *websocket.Conn, packet string) error {
+	return c.Write(ctx, websocket.MessageText, []byte(packet))
+}
+
+func expectText(ctx context.Context, c *websocket.Conn, want func(string) bool) error {
+	_, data, err := c.Read(ctx)
+	if err != nil {
+		return err
+	}
+	if !want(string(data)) {
+		return errors.New("unexpected packet " + string(data))
+	}
+	return nil
+}
+
+func dialTestSocket(t *testing.T, url, namespace string, auth interface{}) *socketIOClient {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, _, err := dialSocketIO(ctx, url, "/socket.io/", namespace, auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.conn.Close(websocket.StatusNormalClosure, "") })
+	return c
+}
+
+func TestSocketIOURL(t *testing.T) {
+	tests := []struct {
+		hostname, want string
+	}{
+		{"https://livestream.icicidirect.com", "wss://livestream.icicidirect.com/socket.io/?EIO=4&transport=websocket"},
+		{"http://127.0.0.1:8080", "ws://127.0.0.1:8080/socket.io/?EIO=4&transport=websocket"},
+		{"wss://host/ignored", "wss://host/socket.io/?EIO=4&transport=websocket"},
+	}
+	for _, tt := range tests {
+		got, err := socketIOURL(tt.hostname, "/socket.io/")
+		if err != nil || got != tt.want {
+			t.Errorf("socketIOURL(%q) = %q, %v; want %q", tt.hostname, got, err, tt.want)
+		}
+	}
+	if _, err := socketIOURL("ftp://host", "/socket.io/"); err == nil {
+		t.Error("socketIOURL accepted an ftp URL")
+	}
+}
+
+func TestParseSocketIOPacket(t *testing.T) {
+	tests := []struct {
+		packet  string
+		typ     byte
+		nsp     string
+		payload string
+	}{
+		{`2["stock",["4.1!2885",1]]`, sioEvent, "/", `["stock",["4.1!2885",1]]`},
+		{`2/feed,["stock",1]`, sioEvent, "/feed", `["stock",1]`},
+		{`217["order",{}]`, sioEvent, "/", `["order",{}]`},
+		{`2/feed,9["stock"]`, sioEvent, "/feed", `["stock"]`},
+		{`0{"sid":"abc"}`, sioConnect, "/", `{"sid":"abc"}`},
+		{`1/feed`, sioDisconnect, "/feed", ``},
+		{`4{"message":"bad"}`, sioConnectError, "/", `{"message":"bad"}`},
+		{``, 0, "", ""},
+	}
+	for _, tt := range tests {
+		typ, nsp, payload := parseSocketIOPacket(tt.packet)
+		if typ != tt.typ || nsp != tt.nsp || payload != tt.payload {
+			t.Errorf("parseSocketIOPacket(%q) = %q, %q, %q; want %q, %q, %q",
+				tt.packet, typ, nsp, payload, tt.typ, tt.nsp, tt.payload)
+		}
+	}
+}
+
+func TestEncodeSocketIOEvent(t *testing.T) {
+	tests := []struct {
+		prefix string
+		event  string
+		args   []interface{}
+		want   string
+	}{
+		{"", "join", []interface{}{[]string{"4.1!2885", "4.1!1594"}}, `42["join",["4.1!2885","4.1!1594"]]`},
+		{"", "leave", []interface{}{"4.1!2885"}, `42["leave","4.1!2885"]`},
+		{"/feed,", "ping", nil, `42/feed,["ping"]`},
+	}
+	for _, tt := range tests {
+		got, err := encodeSocketIOEvent(tt.prefix, tt.event, tt.args...)
+		if err != nil || got != tt.want {
+			t.Errorf("encodeSocketIOEvent(%q, %q) = %s, %v; want %s", tt.prefix, tt.event, got, err, tt.want)
+		}
+	}
+}
+
+func TestSocketIOHandshake(t *testing.T) {
+	server := newFakeSocketServer(t)
+	server.PingInterval = 1500 * time.Millisecond
+	server.PingTimeout = 500 * time.Millisecond
+
+	c := dialTestSocket(t, server.srv.URL, "", map[string]string{"user": "u", "token": "t"})
+	if c.sid != "nsp" {
+		t.Errorf("namespace sid = %q, want nsp", c.sid)
+	}
+	if c.pingInterval != 1500*time.Millisecond || c.pingTimeout != 500*time.Millisecond {
+		t.Errorf("ping interval, timeout = %v, %v; want 1.5s, 500ms", c.pingInterval, c.pingTimeout)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.auths) != 1 || server.auths[0] != `{"token":"t","user":"u"}` {
+		t.Errorf("auth payloads = %q", server.auths)
+	}
+}
+
+func TestSocketIOHandshakeNamespace(t *testing.T) {
+	url, done := scriptedSocketServer(t, func(ctx context.Context, c *websocket.Conn) error {
+		if err := writeText(ctx, c, `0{"sid":"eio","pingInterval":25000,"pingTimeout":20000}`); err != nil {
+			return err
+		}
+		if err := expectText(ctx, c, func(s string) bool { return s == `40/feed,{"k":"v"}` }); err != nil {
+			return err
+		}
+		// A ping during the handshake must be answered before the connect
+		// is acknowledged, and acks for other namespaces are ignored.
+		if err := writeText(ctx, c, "2"); err != nil {
+			return err
+		}
+		if err := expectText(ctx, c, func(s string) bool { return s == "3" }); err != nil {
+			return err
+		}
+		if err := writeText(ctx, c, `40{"sid":"root"}`); err != nil {
+			return err
+		}
+		if err := writeText(ctx, c, `40/feed,{"sid":"feed"}`); err != nil {
+			return err
+		}
+		return expectText(ctx, c, func(s string) bool { return s == `42/feed,["join","x"]` })
+	})
+
+	c := dialTestSocket(t, url, "/feed", map[string]string{"k": "v"})
+	if c.sid != "feed" {
+		t.Errorf("namespace sid = %q, want feed", c.sid)
+	}
+	if err := c.Emit(context.Background(), "join", "x"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server script did not finish")
+	}
+}
+
+func TestSocketIOConnectError(t *testing.T) {
+	server := newFakeSocketServer(t)
+	server.Refuse = "Invalid session"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _, err := dialSocketIO(ctx, server.srv.URL, "/socket.io/", "", map[string]string{})
+	if !errors.Is(err, ErrAuth) {
+		t.Fatalf("dialSocketIO error = %v, want ErrAuth", err)
+	}
+	if err.Error() != "Invalid session" {
+		t.Errorf("error message = %q, want the server's", err.Error())
+	}
+}
+
+func TestSocketIOHandshakeRejectsNonOpen(t *testing.T) {
+	url, _ := scriptedSocketServer(t, func(ctx context.Context, c *websocket.Conn) error {
+		return writeText(ctx, c, `40{"sid":"early"}`)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, _, err := dialSocketIO(ctx, url, "/socket.io/", "", nil); err == nil || !strings.Contains(err.Error(), "open packet") {
+		t.Errorf("dialSocketIO error = %v, want an open packet error", err)
+	}
+}
+
+func TestSocketIOReadEvent(t *testing.T) {
+	server := newFakeSocketServer(t)
+	c := dialTestSocket(t, server.srv.URL, "", nil)
+	server.waitConnect(t)
+
+	// Pings are answered with their probe payload, and packets for other
+	// namespaces or without a decodable event are skipped.
+	server.send("2probe")
+	server.send(`42/other,["stock",1]`)
+	server.send(`42not json`)
+	server.send(`42[7]`)
+	server.send(`42["stock",["4.1!2885",1],"x"]`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	event, args, err := c.ReadEvent(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event != "stock" || len(args) != 2 {
+		t.Fatalf("ReadEvent = %q with %d args, want stock with 2", event, len(args))
+	}
+	var tick []interface{}
+	if err := json.Unmarshal(args[0], &tick); err != nil || len(tick) != 2 || tick[0] != "4.1!2885" {
+		t.Errorf("first argument = %s", args[0])
+	}
+	if string(args[1]) != `"x"` {
+		t.Errorf("second argument = %s", args[1])
+	}
+	server.waitFrame(t, func(s string) bool { return s == "3probe" })
+}
+
+func TestSocketIOReadEventEnds(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet string
+		want   string
+	}{
+		{"engine.io close", "1", "server closed"},
+		{"namespace disconnect", "41", "namespace disconnected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSocketServer(t)
+			c := dialTestSocket(t, server.srv.URL, "", nil)
+			server.waitConnect(t)
+			server.send(tt.packet)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, _, err := c.ReadEvent(ctx); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ReadEvent error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSocketIOPingTimeout(t *testing.T) {
+	server := newFakeSocketServer(t)
+	server.PingInterval = 30 * time.Millisecond
+	server.PingTimeout = 20 * time.Millisecond
+	c := dialTestSocket(t, server.srv.URL, "", nil)
+	server.waitConnect(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, _, err := c.ReadEvent(ctx)
+	if err == nil || !strings.Contains(err.Error(), "ping timeout") {
+		t.Fatalf("ReadEvent error = %v, want a ping timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("timed out after %v, before pingInterval+pingTimeout", elapsed)
+	}
+}
+
+func TestSocketIOClose(t *testing.T) {
+	server := newFakeSocketServer(t)
+	c := dialTestSocket(t, server.srv.URL, "", nil)
+	server.waitConnect(t)
+	c.Close()
+	server.waitFrame(t, func(s string) bool { return s == "41" })
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinels for errors.Is. Each error type below matches its sentinel, so
+// callers can branch on the category without a type assertion.
+var (
+	ErrValidation     = errors.New("breeze: validation failed")
+	ErrAPI            = errors.New("breeze: api error")
+	ErrAuth           = errors.New("breeze: authentication failed")
+	ErrSessionExpired = errors.New("breeze: session expired")
+	ErrNotConnected   = errors.New("breeze: socket not connected")
+	ErrFeedLimit      = errors.New("breeze: feed token limit reached")
+)
+
+// ValidationError reports input rejected locally, before anything was sent.
+type ValidationError struct {
+	Message string
+}
+
+// NewValidationError returns the error the client reports for a request it
+// refuses to send.
+func NewValidationError(message string) error {
+	return &ValidationError{Message: message}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// APIError reports a response the Breeze API answered with a failure status.
+// StatusCode is the envelope Status when the body carried one, otherwise the
+// HTTP status code.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("breeze api error: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("breeze api error: status %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	return target == ErrAPI
+}
+
+// AuthError reports credentials the server rejected: a wrong app key,
+// secret or session token.
+type AuthError struct {
+	Message string
+	Err     error
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e *AuthError) Is(target error) bool {
+	return target == ErrAuth
+}
+
+// SessionExpiredError reports a session token that was valid but is no
+// longer accepted. It also matches ErrAuth.
+type SessionExpiredError struct {
+	Message string
+	Err     error
+}
+
+func (e *SessionExpiredError) Error() string {
+	return e.Message
+}
+
+func (e *SessionExpiredError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SessionExpiredError) Is(target error) bool {
+	return target == ErrSessionExpired || target == ErrAuth
+}
+
+// NotConnectedError reports a socket operation attempted without a live
+// connection.
+type NotConnectedError struct {
+	Message string
+}
+
+func (e *NotConnectedError) Error() string {
+	return e.Message
+}
+
+func (e *NotConnectedError) Is(target error) bool {
+	return target == ErrNotConnected
+}
+
+// FeedLimitError reports a subscription that would take a connection past
+// its token limit. Nothing was subscribed. It also matches ErrValidation.
+type FeedLimitError struct {
+	Limit     int
+	Held      int
+	Requested int
+}
+
+func (e *FeedLimitError) Error() string {
+	return fmt.Sprintf("cannot subscribe %d more token(s): %d of %d allowed per connection already in use", e.Requested, e.Held, e.Limit)
+}
+
+func (e *FeedLimitError) Is(target error) bool {
+	return target == ErrFeedLimit || target == ErrValidation
+}
+
+// newAPIError classifies a failed response, mapping the messages Breeze uses
+// for credential problems onto AuthError and SessionExpiredError.
+func newAPIError(statusCode int, message string, body []byte, exceptMessage map[string]string) error {
+	apiErr := &APIError{StatusCode: statusCode, Message: message, Body: body}
+	switch {
+	case message == "Invalid session.":
+		return &AuthError{Message: exceptMessageOr(exceptMessage, "SESSIONKEY_INCORRECT", message), Err: apiErr}
+	case message == "Public Key does not exist.":
+		return &AuthError{Message: exceptMessageOr(exceptMessage, "APPKEY_INCORRECT", message), Err: apiErr}
+	case message == "Resource not available.", strings.Contains(strings.ToLower(message), "session") && strings.Contains(strings.ToLower(message), "expire"):
+		return &SessionExpiredError{Message: exceptMessageOr(exceptMessage, "SESSIONKEY_EXPIRED", message), Err: apiErr}
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return &AuthError{Message: exceptMessageOr(exceptMessage, "AUTHENICATION_EXCEPTION", message), Err: apiErr}
+	}
+	return apiErr
+}
+
+func exceptMessageOr(exceptMessage map[string]string, key, fallback string) string {
+	if message, ok := exceptMessage[key]; ok && message != "" {
+		return message
+	}
+	return fallback
+}
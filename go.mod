@@ -2,6 +2,8 @@ module go-breeze-connect
 
 go 1.22.5
 
+require nhooyr.io/websocket v1.8.11
+
 require (
 	github.com/andybalholm/brotli v1.1.0 // indirect
 	github.com/gofrs/uuid v4.0.0+incompatible // indirect
@@ -13,5 +15,4 @@ require (
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.55.0 // indirect
 	golang.org/x/sys v0.21.0 // indirect
-	nhooyr.io/websocket v1.8.11 // indirect
 )
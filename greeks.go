@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go-breeze-connect/pricing"
+)
+
+// Options on NSE and BSE expire at the close of the expiry day.
+const optionExpiryCutoff = 15*time.Hour + 30*time.Minute
+
+var errNoSpot = errors.New("no spot price for the underlying")
+
+// OptionGreeks is the implied volatility and Greeks of one option. Theta is
+// per calendar day, vega per volatility point and rho per rate point.
+type OptionGreeks struct {
+	IV    float64
+	Delta float64
+	Gamma float64
+	Theta float64
+	Vega  float64
+	Rho   float64
+}
+
+// GreeksCalculator adds implied volatility and Greeks to option ticks. The
+// spot of each underlying is taken from its own cash or index ticks, keyed by
+// stock_name, or set explicitly with SetSpot.
+type GreeksCalculator struct {
+	RiskFreeRate  float64
+	DividendYield float64
+
+	// Now returns the valuation time; it defaults to time.Now.
+	Now func() time.Time
+
+	mu     sync.RWMutex
+	spot   map[string]float64
+	detach func()
+}
+
+func NewGreeksCalculator(riskFreeRate float64) *GreeksCalculator {
+	return &GreeksCalculator{
+		RiskFreeRate: riskFreeRate,
+		Now:          time.Now,
+		spot:         map[string]float64{},
+	}
+}
+
+// EnableGreeks hooks a GreeksCalculator into OnTicks so option ticks carry
+// iv, delta, gamma, theta, vega and rho by the time they reach the callback.
+// Subscribe to the underlying as well so the spot stays current.
+func (b *BreezeInstance) EnableGreeks(riskFreeRate float64) *GreeksCalculator {
+	g := NewGreeksCalculator(riskFreeRate)
+	g.detach = b.addTickHandler(g.apply)
+	return g
+}
+
+// Stop detaches a calculator returned by EnableGreeks from the tick stream.
+func (g *GreeksCalculator) Stop() {
+	if g.detach != nil {
+		g.detach()
+	}
+}
+
+func (g *GreeksCalculator) SetSpot(underlying string, price float64) {
+	g.mu.Lock()
+	g.spot[strings.ToUpper(underlying)] = price
+	g.mu.Unlock()
+}
+
+func (g *GreeksCalculator) Spot(underlying string) (float64, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	price, ok := g.spot[strings.ToUpper(underlying)]
+	return price, ok
+}
+
+func (g *GreeksCalculator) apply(tick map[string]interface{}) {
+	if _, isOption := tick["right"]; !isOption {
+		if _, isFuture := tick["expiry_date"]; isFuture {
+			return
+		}
+		name, _ := tick["stock_name"].(string)
+		if last, ok := tickFloat(tick["last"]); ok && name != "" && last > 0 {
+			g.SetSpot(name, last)
+		}
+		return
+	}
+	g.Enrich(tick)
+}
+
+// Enrich computes the Greeks of an option tick from its last traded price,
+// or the bid/ask mid when there is no trade, and stores them in the tick.
+func (g *GreeksCalculator) Enrich(tick map[string]interface{}) (OptionGreeks, error) {
+	right, _ := tick["right"].(string)
+	expiry, _ := tick["expiry_date"].(string)
+	strike, ok := tickFloat(tick["strike_price"])
+	if !ok {
+		return OptionGreeks{}, &ValidationError{Message: "Tick has no strike price"}
+	}
+	spot, ok := tickFloat(tick["spot_price"])
+	if !ok {
+		name, _ := tick["stock_name"].(string)
+		if spot, ok = g.Spot(name); !ok {
+			return OptionGreeks{}, errNoSpot
+		}
+	}
+	price, ok := tickFloat(tick["last"])
+	if !ok || price <= 0 {
+		bid, hasBid := tickFloat(tick["bPrice"])
+		ask, hasAsk := tickFloat(tick["sPrice"])
+		if !hasBid || !hasAsk || bid <= 0 || ask <= 0 {
+			return OptionGreeks{}, &ValidationError{Message: "Tick has no price"}
+		}
+		price = (bid + ask) / 2
+	}
+
+	greeks, err := g.compute(right, expiry, strike, spot, price)
+	if err != nil {
+		return OptionGreeks{}, err
+	}
+	tick["iv"] = greeks.IV
+	tick["delta"] = greeks.Delta
+	tick["gamma"] = greeks.Gamma
+	tick["theta"] = greeks.Theta
+	tick["vega"] = greeks.Vega
+	tick["rho"] = greeks.Rho
+	return greeks, nil
+}
+
+// Quote fills the Greeks of an option chain leg from its LTP. A spot set
+// for the stock code wins over the spot price in the chain snapshot.
+func (g *GreeksCalculator) Quote(q *OptionQuote) error {
+	spot, ok := g.Spot(q.StockCode)
+	if !ok {
+		if spot = float64(q.SpotPrice); spot <= 0 {
+			return errNoSpot
+		}
+	}
+	greeks, err := g.compute(q.Right, q.ExpiryDate, float64(q.StrikePrice), spot, float64(q.LTP))
+	if err != nil {
+		return err
+	}
+	q.Greeks = greeks
+	return nil
+}
+
+func (g *GreeksCalculator) compute(right, expiry string, strike, spot, price float64) (OptionGreeks, error) {
+	var r pricing.Right
+	switch strings.ToLower(right) {
+	case "call", "ce":
+		r = pricing.Call
+	case "put", "pe":
+		r = pricing.Put
+	default:
+		return OptionGreeks{}, &ValidationError{Message: "Right should be call or put"}
+	}
+	expiresAt, err := optionExpiryTime(expiry)
+	if err != nil {
+		return OptionGreeks{}, &ValidationError{Message: "Invalid expiry date " + expiry}
+	}
+	now := time.Now
+	if g.Now != nil {
+		now = g.Now
+	}
+	t := expiresAt.Sub(now()).Hours() / (365 * 24)
+
+	iv, err := pricing.ImpliedVolatility(r, price, spot, strike, t, g.RiskFreeRate, g.DividendYield)
+	if err != nil {
+		return OptionGreeks{}, err
+	}
+	greeks := pricing.ComputeGreeks(r, spot, strike, t, g.RiskFreeRate, g.DividendYield, iv)
+	return OptionGreeks{
+		IV:    iv,
+		Delta: greeks.Delta,
+		Gamma: greeks.Gamma,
+		Theta: greeks.Theta / 365,
+		Vega:  greeks.Vega / 100,
+		Rho:   greeks.Rho / 100,
+	}, nil
+}
+
+// optionExpiryTime returns the moment an option expires. A bare date, and
+// a UTC stamp such as "2024-01-25T06:00:00.000Z" which Breeze uses to name
+// a day, expire at the close of that IST day.
+func optionExpiryTime(expiry string) (time.Time, error) {
+	expiry = strings.TrimSpace(expiry)
+	t, err := parseBreezeTime(expiry)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t = t.In(istLocation)
+	if strings.HasSuffix(expiry, "Z") || t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, istLocation).Add(optionExpiryCutoff)
+	}
+	return t, nil
+}
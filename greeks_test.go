@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOptionExpiryTime(t *testing.T) {
+	close25 := time.Date(2024, 1, 25, 15, 30, 0, 0, istLocation)
+	tests := []struct {
+		expiry string
+		want   time.Time
+	}{
+		{"25-Jan-2024", close25},
+		{"2024-01-25", close25},
+		{"2024-01-25T06:00:00.000Z", close25},
+		{"2024-01-25T06:00:00Z", close25},
+		// 20:00 UTC is already the 26th in IST.
+		{"2024-01-25T20:00:00.000Z", close25.AddDate(0, 0, 1)},
+		{"2024-01-25 10:00:00", time.Date(2024, 1, 25, 10, 0, 0, 0, istLocation)},
+	}
+	for _, tt := range tests {
+		got, err := optionExpiryTime(tt.expiry)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("optionExpiryTime(%q) = %v, %v; want %v", tt.expiry, got, err, tt.want)
+		}
+	}
+	if _, err := optionExpiryTime("someday"); err == nil {
+		t.Error("optionExpiryTime accepted an invalid date")
+	}
+}
+
+// TestEnrichUTCExpiryStamp values an option at noon IST on expiry day. The
+// UTC stamp names the same close as the bare date, so it is still live.
+func TestEnrichUTCExpiryStamp(t *testing.T) {
+	g := NewGreeksCalculator(0.07)
+	g.Now = func() time.Time { return time.Date(2024, 1, 25, 12, 0, 0, 0, istLocation) }
+
+	var got []OptionGreeks
+	for _, expiry := range []string{"2024-01-25T06:00:00.000Z", "25-Jan-2024"} {
+		greeks, err := g.Enrich(map[string]interface{}{
+			"right":        "Call",
+			"expiry_date":  expiry,
+			"strike_price": "21500",
+			"spot_price":   21480.0,
+			"last":         35.0,
+		})
+		if err != nil {
+			t.Fatalf("Enrich with expiry %q: %v", expiry, err)
+		}
+		got = append(got, greeks)
+	}
+	if got[0] != got[1] {
+		t.Errorf("greeks differ between expiry forms: %+v and %+v", got[0], got[1])
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The v2 chart endpoint returns at most this many candles per call, so
+// longer ranges are split into windows of this many intervals.
+const histV2MaxCandles = 1000
+
+const defaultHistV2Parallelism = 4
+
+var histV2IntervalStep = map[string]time.Duration{
+	"1second":  time.Second,
+	"1minute":  time.Minute,
+	"5minute":  5 * time.Minute,
+	"30minute": 30 * time.Minute,
+	"1day":     24 * time.Hour,
+}
+
+// GetHistoricalDataV2 fetches candles from the v2 chart API, which also
+// serves 1second bars. Ranges longer than one request allows are fetched as
+// concurrent windows of at most HistV2Parallelism requests; the merged
+// candles are de-duplicated and returned in time order.
+func (a *ApificationBreeze) GetHistoricalDataV2(interval, fromDate, toDate, stockCode, exchangeCode, productType, expiryDate, right, strikePrice string) ([]Candle, error) {
+	req := HistoricalDataRequest{
+		Interval:     strings.ToLower(interval),
+		FromDate:     fromDate,
+		ToDate:       toDate,
+		StockCode:    stockCode,
+		ExchangeCode: strings.ToLower(exchangeCode),
+		ProductType:  strings.ToLower(productType),
+		ExpiryDate:   expiryDate,
+		Right:        strings.ToLower(right),
+		StrikePrice:  strikePrice,
+	}
+	from, to, err := req.validateV2()
+	if err != nil {
+		return nil, err
+	}
+
+	windows := histV2Windows(from, to, histV2IntervalStep[req.Interval]*histV2MaxCandles)
+	parallelism := a.HistV2Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultHistV2Parallelism
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		results  = make([][]Candle, len(windows))
+		sem      = make(chan struct{}, parallelism)
+	)
+	for i, window := range windows {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, window [2]time.Time) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			candles, err := a.fetchHistoricalV2(req, window[0], window[1])
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			results[i] = candles
+		}(i, window)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return mergeCandles(results), nil
+}
+
+func (r *HistoricalDataRequest) validateV2() (time.Time, time.Time, error) {
+	if r.Interval == "" || r.FromDate == "" || r.ToDate == "" || r.StockCode == "" || r.ExchangeCode == "" {
+		return time.Time{}, time.Time{}, &ValidationError{Message: "Interval, from date, to date, stock code or exchange code cannot be empty"}
+	}
+	if !contains(INTERVAL_TYPES_HIST_V2, r.Interval) {
+		return time.Time{}, time.Time{}, &ValidationError{Message: "Interval should be one of " + strings.Join(INTERVAL_TYPES_HIST_V2, ", ")}
+	}
+	if !contains(EXCHANGE_CODES_HIST_V2, r.ExchangeCode) {
+		return time.Time{}, time.Time{}, &ValidationError{Message: "Exchange code should be one of " + strings.Join(EXCHANGE_CODES_HIST_V2, ", ")}
+	}
+	if r.ProductType != "" && !contains(PRODUCT_TYPES_HIST_V2, r.ProductType) {
+		return time.Time{}, time.Time{}, &ValidationError{Message: "Product type should be one of " + strings.Join(PRODUCT_TYPES_HIST_V2, ", ")}
+	}
+	if contains(FNO_EXCHANGE_TYPES, r.ExchangeCode) {
+		if r.ProductType != "futures" && r.ProductType != "options" {
+			return time.Time{}, time.Time{}, &ValidationError{Message: "Product type should be futures or options for " + r.ExchangeCode}
+		}
+		if r.ExpiryDate == "" {
+			return time.Time{}, time.Time{}, &ValidationError{Message: "Expiry date cannot be empty for " + r.ExchangeCode}
+		}
+		if r.ProductType == "options" {
+			if r.Right != "call" && r.Right != "put" {
+				return time.Time{}, time.Time{}, &ValidationError{Message: "Right should be call or put for options"}
+			}
+			if r.StrikePrice == "" {
+				return time.Time{}, time.Time{}, &ValidationError{Message: "Strike price cannot be empty for options"}
+			}
+		}
+	}
+
+	from, err := parseBreezeTime(r.FromDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, &ValidationError{Message: "Invalid from date " + r.FromDate}
+	}
+	to, err := parseBreezeTime(r.ToDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, &ValidationError{Message: "Invalid to date " + r.ToDate}
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, &ValidationError{Message: "From date cannot be after to date"}
+	}
+	return from, to, nil
+}
+
+// histV2Windows splits [from, to] into consecutive windows no longer than
+// size. Adjacent windows share their boundary instant; mergeCandles drops
+// the duplicate that produces.
+func histV2Windows(from, to time.Time, size time.Duration) [][2]time.Time {
+	windows := [][2]time.Time{}
+	for start := from; ; start = start.Add(size) {
+		end := start.Add(size)
+		if !end.Before(to) {
+			windows = append(windows, [2]time.Time{start, to})
+			return windows
+		}
+		windows = append(windows, [2]time.Time{start, end})
+	}
+}
+
+func (a *ApificationBreeze) fetchHistoricalV2(req HistoricalDataRequest, from, to time.Time) ([]Candle, error) {
+	params := url.Values{}
+	params.Set("interval", req.Interval)
+	params.Set("from_date", from.UTC().Format("2006-01-02T15:04:05.000Z"))
+	params.Set("to_date", to.UTC().Format("2006-01-02T15:04:05.000Z"))
+	params.Set("stock_code", req.StockCode)
+	params.Set("exch_code", req.ExchangeCode)
+	if req.ProductType != "" {
+		params.Set("product_type", req.ProductType)
+	}
+	if req.ExpiryDate != "" {
+		params.Set("expiry_date", req.ExpiryDate)
+	}
+	if req.Right != "" {
+		params.Set("right", req.Right)
+	}
+	if req.StrikePrice != "" {
+		params.Set("strike_price", req.StrikePrice)
+	}
+
+	hostname := a.HistV2Hostname
+	if hostname == "" {
+		hostname = BREEZE_NEW_URL
+	}
+	httpReq, err := http.NewRequest("GET", strings.TrimSuffix(hostname, "/")+"/"+string(HIST_CHART)+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-SessionToken", a.Base64SessionToken)
+	httpReq.Header.Set("apikey", a.Breeze.APIKey)
+
+	client := &http.Client{}
+	response, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	return decodeResponse[[]Candle](response, a.Breeze.ExceptMessage)
+}
+
+func mergeCandles(windows [][]Candle) []Candle {
+	seen := map[int64]bool{}
+	merged := []Candle{}
+	for _, candles := range windows {
+		for _, candle := range candles {
+			key := candle.Datetime.UnixNano()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, candle)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Datetime.Before(merged[j].Datetime.Time)
+	})
+	return merged
+}
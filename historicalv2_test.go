@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistV2Windows(t *testing.T) {
+	base := time.Date(2023, 7, 3, 9, 15, 0, 0, istLocation)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	tests := []struct {
+		name   string
+		from   time.Time
+		to     time.Time
+		size   time.Duration
+		bounds []int
+	}{
+		{"single instant", at(0), at(0), 10 * time.Minute, []int{0, 0}},
+		{"shorter than one window", at(0), at(7), 10 * time.Minute, []int{0, 7}},
+		{"exactly one window", at(0), at(10), 10 * time.Minute, []int{0, 10}},
+		{"just over one window", at(0), at(11), 10 * time.Minute, []int{0, 10, 10, 11}},
+		{"several windows", at(0), at(25), 10 * time.Minute, []int{0, 10, 10, 20, 20, 25}},
+		{"whole windows", at(0), at(30), 10 * time.Minute, []int{0, 10, 10, 20, 20, 30}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows := histV2Windows(tt.from, tt.to, tt.size)
+			if len(windows)*2 != len(tt.bounds) {
+				t.Fatalf("got %d windows %v, want %d", len(windows), windows, len(tt.bounds)/2)
+			}
+			for i, w := range windows {
+				if !w[0].Equal(at(tt.bounds[2*i])) || !w[1].Equal(at(tt.bounds[2*i+1])) {
+					t.Errorf("window %d = %s-%s, want +%dm-+%dm", i,
+						w[0].Format("15:04"), w[1].Format("15:04"), tt.bounds[2*i], tt.bounds[2*i+1])
+				}
+			}
+		})
+	}
+}
+
+func TestMergeCandles(t *testing.T) {
+	base := time.Date(2023, 7, 3, 9, 15, 0, 0, istLocation)
+	candle := func(minutes int, close float64) Candle {
+		return Candle{Datetime: Time{base.Add(time.Duration(minutes) * time.Minute)}, Close: Float(close)}
+	}
+	merged := mergeCandles([][]Candle{
+		{candle(2, 102), candle(0, 100), candle(1, 101)},
+		nil,
+		{candle(4, 104), candle(2, 202), candle(3, 103)},
+	})
+	want := []float64{100, 101, 102, 103, 104}
+	if len(merged) != len(want) {
+		t.Fatalf("merged %d candles, want %d", len(merged), len(want))
+	}
+	for i, c := range merged {
+		// The shared boundary candle is kept from the earlier window.
+		if float64(c.Close) != want[i] || !c.Datetime.Equal(base.Add(time.Duration(i)*time.Minute)) {
+			t.Errorf("candle %d = %s close %v, want +%dm close %v", i, c.Datetime.Format("15:04"), c.Close, i, want[i])
+		}
+	}
+	if got := mergeCandles(nil); got == nil || len(got) != 0 {
+		t.Errorf("mergeCandles(nil) = %#v, want an empty slice", got)
+	}
+}
+
+// fakeChartAPI answers the v2 chart endpoint with one candle per minute of
+// the requested range, newest first, and records the ranges it was asked
+// for.
+type fakeChartAPI struct {
+	mu     sync.Mutex
+	ranges [][2]string
+	fail   string
+}
+
+func (f *fakeChartAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f.mu.Lock()
+	f.ranges = append(f.ranges, [2]string{q.Get("from_date"), q.Get("to_date")})
+	fail := f.fail == q.Get("from_date")
+	f.mu.Unlock()
+	if fail {
+		w.Write([]byte(`{"Success":null,"Status":500,"Error":"Limit exceeded"}`))
+		return
+	}
+	from, _ := time.Parse(time.RFC3339, q.Get("from_date"))
+	to, _ := time.Parse(time.RFC3339, q.Get("to_date"))
+	candles := []Candle{}
+	for at := to; !at.Before(from); at = at.Add(-time.Minute) {
+		candles = append(candles, Candle{Datetime: Time{at}, StockCode: q.Get("stock_code"), Close: Float(at.Minute())})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"Success": candles, "Status": 200, "Error": nil})
+}
+
+func newFakeChartAPI(t *testing.T) (*fakeChartAPI, *ApificationBreeze) {
+	t.Helper()
+	f := &fakeChartAPI{}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	b := NewBreezeInstance("app-key")
+	b.UserID, b.SessionKey, b.SecretKey = "user", "session", "secret"
+	a := NewApificationBreeze(b)
+	a.HistV2Hostname = srv.URL
+	a.HistV2Parallelism = 2
+	return f, a
+}
+
+func TestGetHistoricalDataV2Windows(t *testing.T) {
+	f, a := newFakeChartAPI(t)
+	// 2500 one-minute candles need three requests of at most 1000.
+	candles, err := a.GetHistoricalDataV2("1minute", "2023-07-03T00:00:00.000Z", "2023-07-04T17:40:00.000Z", "NIFTY", "NSE", "cash", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2501 {
+		t.Fatalf("got %d candles, want 2501", len(candles))
+	}
+	start := time.Date(2023, 7, 3, 0, 0, 0, 0, time.UTC)
+	for i, c := range candles {
+		if !c.Datetime.Equal(start.Add(time.Duration(i) * time.Minute)) {
+			t.Fatalf("candle %d at %s, want %s", i, c.Datetime, start.Add(time.Duration(i)*time.Minute))
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	want := map[[2]string]bool{
+		{"2023-07-03T00:00:00.000Z", "2023-07-03T16:40:00.000Z"}: true,
+		{"2023-07-03T16:40:00.000Z", "2023-07-04T09:20:00.000Z"}: true,
+		{"2023-07-04T09:20:00.000Z", "2023-07-04T17:40:00.000Z"}: true,
+	}
+	if len(f.ranges) != len(want) {
+		t.Fatalf("requested ranges %v, want %d", f.ranges, len(want))
+	}
+	for _, r := range f.ranges {
+		if !want[r] {
+			t.Errorf("unexpected range %v", r)
+		}
+	}
+}
+
+func TestGetHistoricalDataV2WindowFailure(t *testing.T) {
+	f, a := newFakeChartAPI(t)
+	f.fail = "2023-07-03T16:40:00.000Z"
+	candles, err := a.GetHistoricalDataV2("1minute", "2023-07-03T00:00:00.000Z", "2023-07-04T17:40:00.000Z", "NIFTY", "NSE", "cash", "", "", "")
+	if !errors.Is(err, ErrAPI) || candles != nil {
+		t.Errorf("GetHistoricalDataV2 = %d candles, %v; want nil and the failed window's ErrAPI", len(candles), err)
+	}
+}
+
+func TestGetHistoricalDataV2Validation(t *testing.T) {
+	f, a := newFakeChartAPI(t)
+	tests := []struct {
+		name                                                 string
+		interval, from, to, exchange, product, expiry, right string
+	}{
+		{"unknown interval", "2minute", "2023-07-03", "2023-07-04", "NSE", "cash", "", ""},
+		{"unknown exchange", "1minute", "2023-07-03", "2023-07-04", "BFO", "cash", "", ""},
+		{"futures without expiry", "1minute", "2023-07-03", "2023-07-04", "NFO", "futures", "", ""},
+		{"options without right", "1minute", "2023-07-03", "2023-07-04", "NFO", "options", "27-Jul-2023", ""},
+		{"bad from date", "1minute", "yesterday", "2023-07-04", "NSE", "cash", "", ""},
+		{"reversed range", "1minute", "2023-07-04", "2023-07-03", "NSE", "cash", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.GetHistoricalDataV2(tt.interval, tt.from, tt.to, "NIFTY", tt.exchange, tt.product, tt.expiry, tt.right, "19500")
+			if !errors.Is(err, ErrValidation) {
+				t.Errorf("error = %v, want ErrValidation", err)
+			}
+		})
+	}
+	if len(f.ranges) != 0 {
+		t.Errorf("invalid requests reached the API: %v", f.ranges)
+	}
+}
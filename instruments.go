@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ProductType returns "futures", "options" or "cash" from the instrument
+// type, which is FUT/OPT in StockScriptNew.csv and FUTIDX, OPTSTK and the
+// like in the ScripMaster files.
+func (i Instrument) ProductType() string {
+	switch {
+	case strings.HasPrefix(strings.ToUpper(i.InstrumentType), "FUT"):
+		return "futures"
+	case strings.HasPrefix(strings.ToUpper(i.InstrumentType), "OPT"):
+		return "options"
+	}
+	return "cash"
+}
+
+type contractKey struct {
+	stockCode string
+	product   string
+	expiry    string
+	strike    float64
+	right     string
+}
+
+// InstrumentRegistry resolves instruments per exchange in both directions:
+// stock code or contract to token for subscriptions, and token back to the
+// instrument for incoming ticks. It is safe for concurrent use and can be
+// reloaded while feeds are running.
+type InstrumentRegistry struct {
+	mu          sync.RWMutex
+	instruments []Instrument
+	byToken     map[string]map[string]int
+	byStockCode map[string]map[string]int
+	byContract  map[string]map[contractKey]int
+	byUnderlier map[string]map[string][]int
+}
+
+func NewInstrumentRegistry() *InstrumentRegistry {
+	return &InstrumentRegistry{
+		byToken:     map[string]map[string]int{},
+		byStockCode: map[string]map[string]int{},
+		byContract:  map[string]map[contractKey]int{},
+		byUnderlier: map[string]map[string][]int{},
+	}
+}
+
+// Add registers inst under its exchange; a later instrument with the same
+// exchange and token replaces the earlier one in lookups.
+func (r *InstrumentRegistry) Add(inst Instrument) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(inst)
+}
+
+func (r *InstrumentRegistry) add(inst Instrument) {
+	exchange := strings.ToUpper(inst.Exchange)
+	inst.Exchange = exchange
+	if r.byToken[exchange] == nil {
+		r.byToken[exchange] = map[string]int{}
+		r.byStockCode[exchange] = map[string]int{}
+		r.byContract[exchange] = map[contractKey]int{}
+		r.byUnderlier[exchange] = map[string][]int{}
+	}
+
+	i, ok := r.byToken[exchange][inst.Token]
+	if ok {
+		r.unindex(i)
+		r.instruments[i] = inst
+	} else {
+		i = len(r.instruments)
+		r.instruments = append(r.instruments, inst)
+	}
+	r.byToken[exchange][inst.Token] = i
+	code := strings.ToUpper(inst.ISECCode)
+	if inst.ProductType() == "cash" {
+		r.byStockCode[exchange][code] = i
+		return
+	}
+	r.byContract[exchange][newContractKey(code, inst.ProductType(), inst.ExpiryDate, inst.StrikePrice, inst.Right)] = i
+	r.byUnderlier[exchange][code] = append(r.byUnderlier[exchange][code], i)
+}
+
+// unindex drops the code and contract entries of the instrument in slot i
+// so the slot can be reused for the instrument replacing it.
+func (r *InstrumentRegistry) unindex(i int) {
+	old := r.instruments[i]
+	code := strings.ToUpper(old.ISECCode)
+	if old.ProductType() == "cash" {
+		if r.byStockCode[old.Exchange][code] == i {
+			delete(r.byStockCode[old.Exchange], code)
+		}
+		return
+	}
+	key := newContractKey(code, old.ProductType(), old.ExpiryDate, old.StrikePrice, old.Right)
+	if r.byContract[old.Exchange][key] == i {
+		delete(r.byContract[old.Exchange], key)
+	}
+	slots := r.byUnderlier[old.Exchange][code]
+	for k, slot := range slots {
+		if slot == i {
+			r.byUnderlier[old.Exchange][code] = append(slots[:k:k], slots[k+1:]...)
+			break
+		}
+	}
+}
+
+func newContractKey(stockCode, product, expiry string, strike float64, right string) contractKey {
+	key := contractKey{
+		stockCode: strings.ToUpper(stockCode),
+		product:   strings.ToLower(product),
+		expiry:    normaliseExpiry(expiry),
+	}
+	if key.product == "options" {
+		key.strike = strike
+		key.right = strings.ToLower(right)
+	}
+	return key
+}
+
+// normaliseExpiry lets "27-Jul-2023" and "2023-07-27T06:00:00.000Z" name
+// the same expiry.
+func normaliseExpiry(expiry string) string {
+	if t, err := parseBreezeTime(strings.TrimSpace(expiry)); err == nil {
+		return t.Format("2006-01-02")
+	}
+	return strings.ToLower(strings.TrimSpace(expiry))
+}
+
+// LoadStockScriptCSV adds the rows of StockScriptNew.csv. Cash rows carry
+// the stock code in column 3; F&O rows carry a contract detail such as
+// OPT-NIFTY-27-Jul-2023-19500-CE in column 7.
+func (r *InstrumentRegistry) LoadStockScriptCSV(in io.Reader) error {
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if inst, ok := parseStockScriptRow(row); ok {
+			r.add(inst)
+		}
+	}
+}
+
+func parseStockScriptRow(row []string) (Instrument, bool) {
+	if len(row) < 6 {
+		return Instrument{}, false
+	}
+	inst := Instrument{
+		Name:     row[1],
+		Exchange: row[2],
+		Token:    row[5],
+	}
+	switch row[2] {
+	case "BSE", "NSE":
+		inst.ISECCode = row[3]
+		return inst, true
+	case "NDX", "MCX", "NFO", "BFO":
+		if len(row) < 8 {
+			return Instrument{}, false
+		}
+		// PRODUCT-STOCK-DD-Mon-YYYY[-STRIKE-RIGHT]
+		parts := strings.Split(row[7], "-")
+		if len(parts) < 5 {
+			return Instrument{}, false
+		}
+		inst.InstrumentType = parts[0]
+		inst.ISECCode = parts[1]
+		inst.ExchangeCode = row[7]
+		inst.ExpiryDate = strings.Join(parts[2:5], "-")
+		if len(parts) > 6 {
+			inst.StrikePrice, _ = strconv.ParseFloat(parts[5], 64)
+			switch parts[6] {
+			case "CE":
+				inst.Right = "call"
+			case "PE":
+				inst.Right = "put"
+			}
+		}
+		return inst, true
+	}
+	return Instrument{}, false
+}
+
+// LoadSecurityMaster adds every instrument parsed from SecurityMaster.zip.
+func (r *InstrumentRegistry) LoadSecurityMaster(m *SecurityMaster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, inst := range m.Instruments {
+		r.add(inst)
+	}
+}
+
+func (r *InstrumentRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.instruments)
+}
+
+// Instruments returns a copy of every registered instrument.
+func (r *InstrumentRegistry) Instruments() []Instrument {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Instrument(nil), r.instruments...)
+}
+
+// ByToken returns the instrument trading under token on exchange.
+func (r *InstrumentRegistry) ByToken(exchange, token string) (Instrument, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i, ok := r.byToken[strings.ToUpper(exchange)][token]
+	if !ok {
+		return Instrument{}, false
+	}
+	return r.instruments[i], true
+}
+
+// Stock returns the cash instrument for stockCode on NSE or BSE.
+func (r *InstrumentRegistry) Stock(exchange, stockCode string) (Instrument, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i, ok := r.byStockCode[strings.ToUpper(exchange)][strings.ToUpper(stockCode)]
+	if !ok {
+		return Instrument{}, false
+	}
+	return r.instruments[i], true
+}
+
+// Contract returns the future or option identified by its underlying stock
+// code, expiry and, for options, strike and right ("call" or "put").
+func (r *InstrumentRegistry) Contract(exchange, stockCode, productType, expiry string, strike float64, right string) (Instrument, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i, ok := r.byContract[strings.ToUpper(exchange)][newContractKey(stockCode, productType, expiry, strike, right)]
+	if !ok {
+		return Instrument{}, false
+	}
+	return r.instruments[i], true
+}
+
+// Expiries lists the expiries of the F&O contracts on underlying in date
+// order.
+func (r *InstrumentRegistry) Expiries(exchange, underlying string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := map[string]string{}
+	for _, i := range r.byUnderlier[strings.ToUpper(exchange)][strings.ToUpper(underlying)] {
+		expiry := r.instruments[i].ExpiryDate
+		seen[normaliseExpiry(expiry)] = expiry
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	expiries := make([]string, len(keys))
+	for i, k := range keys {
+		expiries[i] = seen[k]
+	}
+	return expiries
+}
+
+// Strikes lists the option strikes on underlying for expiry in ascending
+// order.
+func (r *InstrumentRegistry) Strikes(exchange, underlying, expiry string) []float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	want := normaliseExpiry(expiry)
+	seen := map[float64]bool{}
+	strikes := []float64{}
+	for _, i := range r.byUnderlier[strings.ToUpper(exchange)][strings.ToUpper(underlying)] {
+		inst := r.instruments[i]
+		if inst.ProductType() != "options" || normaliseExpiry(inst.ExpiryDate) != want || seen[inst.StrikePrice] {
+			continue
+		}
+		seen[inst.StrikePrice] = true
+		strikes = append(strikes, inst.StrikePrice)
+	}
+	sort.Float64s(strikes)
+	return strikes
+}
+
+// Search finds cash instruments whose stock code, exchange symbol or name
+// matches query, best matches first: exact code, then prefix, then
+// substring, then the query's letters appearing in order. A limit of zero
+// returns every match.
+func (r *InstrumentRegistry) Search(query string, limit int) []Instrument {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	type hit struct {
+		rank int
+		i    int
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	hits := []hit{}
+	for _, codes := range r.byStockCode {
+		for _, i := range codes {
+			inst := r.instruments[i]
+			best := -1
+			for _, field := range []string{inst.ISECCode, inst.ExchangeCode, inst.Name} {
+				if rank := matchRank(query, strings.ToUpper(field)); rank >= 0 && (best < 0 || rank < best) {
+					best = rank
+				}
+			}
+			if best >= 0 {
+				hits = append(hits, hit{best, i})
+			}
+		}
+	}
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].rank != hits[b].rank {
+			return hits[a].rank < hits[b].rank
+		}
+		ia, ib := r.instruments[hits[a].i], r.instruments[hits[b].i]
+		if ia.ISECCode != ib.ISECCode {
+			return ia.ISECCode < ib.ISECCode
+		}
+		return ia.Exchange > ib.Exchange
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	out := make([]Instrument, len(hits))
+	for k, h := range hits {
+		out[k] = r.instruments[h.i]
+	}
+	return out
+}
+
+// matchRank scores how well query matches field; lower is better and -1 is
+// no match.
+func matchRank(query, field string) int {
+	switch {
+	case field == "":
+		return -1
+	case field == query:
+		return 0
+	case strings.HasPrefix(field, query):
+		return 1
+	case strings.Contains(field, query):
+		return 2
+	}
+	j := 0
+	for k := 0; k < len(field) && j < len(query); k++ {
+		if field[k] == query[j] {
+			j++
+		}
+	}
+	if j == len(query) {
+		return 3
+	}
+	return -1
+}
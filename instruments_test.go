@@ -0,0 +1,201 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const stockScriptCSV = `SC,Name,EX,STOCK,ISIN,TOKEN,SERIES,DETAIL
+1,ITC LTD,NSE,ITC,INE154A01025,1660,EQ,
+2,ITC LTD,BSE,ITC,INE154A01025,500875,A,
+3,INFOSYS LTD,NSE,INFTEC,INE009A01021,1594,EQ,
+4,NIFTY,NFO,,,35001,,FUT-NIFTY-27-Jul-2023
+5,NIFTY,NFO,,,43210,,OPT-NIFTY-27-Jul-2023-19500-CE
+6,NIFTY,NFO,,,43211,,OPT-NIFTY-27-Jul-2023-19500-PE
+7,NIFTY,NFO,,,43300,,OPT-NIFTY-03-Aug-2023-19400-CE
+8,NIFTY,NFO,,,43212,,OPT-NIFTY-27-Jul-2023-19450-CE
+9,SENSEX,BFO,,,867001,,OPT-BSXOPT-28-Jul-2023-66000-CE
+10,CRUDEOIL,MCX,,,250001,,FUT-CRUDEOIL-19-Jul-2023
+11,SHORT ROW,NSE
+12,BAD DETAIL,NFO,,,1,,OPT-NIFTY
+13,UNKNOWN,XYZ,ABC,,2,,
+`
+
+func loadTestRegistry(t *testing.T) *InstrumentRegistry {
+	t.Helper()
+	r := NewInstrumentRegistry()
+	if err := r.LoadStockScriptCSV(strings.NewReader(stockScriptCSV)); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestLoadStockScriptCSV(t *testing.T) {
+	r := loadTestRegistry(t)
+	if r.Len() != 10 {
+		t.Fatalf("Len = %d, want the 10 well-formed rows", r.Len())
+	}
+
+	inst, ok := r.ByToken("nfo", "43210")
+	want := Instrument{
+		Token:          "43210",
+		ISECCode:       "NIFTY",
+		ExchangeCode:   "OPT-NIFTY-27-Jul-2023-19500-CE",
+		Exchange:       "NFO",
+		Name:           "NIFTY",
+		InstrumentType: "OPT",
+		ExpiryDate:     "27-Jul-2023",
+		StrikePrice:    19500,
+		Right:          "call",
+	}
+	if !ok || inst != want {
+		t.Errorf("ByToken(nfo, 43210) = %+v, %v; want %+v", inst, ok, want)
+	}
+	if inst, ok := r.ByToken("NSE", "1594"); !ok || inst.ISECCode != "INFTEC" || inst.ProductType() != "cash" {
+		t.Errorf("ByToken(NSE, 1594) = %+v, %v", inst, ok)
+	}
+	if inst, ok := r.ByToken("MCX", "250001"); !ok || inst.ProductType() != "futures" || inst.StrikePrice != 0 || inst.Right != "" {
+		t.Errorf("ByToken(MCX, 250001) = %+v, %v", inst, ok)
+	}
+	if _, ok := r.ByToken("BSE", "1660"); ok {
+		t.Error("an NSE token resolved on BSE")
+	}
+}
+
+func TestInstrumentRegistryLookups(t *testing.T) {
+	r := loadTestRegistry(t)
+
+	if inst, ok := r.Stock("bse", "itc"); !ok || inst.Token != "500875" {
+		t.Errorf("Stock(bse, itc) = %+v, %v", inst, ok)
+	}
+	tests := []struct {
+		name                    string
+		exchange, code, product string
+		expiry                  string
+		strike                  float64
+		right                   string
+		token                   string
+	}{
+		{"future", "NFO", "NIFTY", "futures", "27-Jul-2023", 0, "", "35001"},
+		{"future ignores strike and right", "NFO", "nifty", "Futures", "2023-07-27T06:00:00.000Z", 19500, "call", "35001"},
+		{"call", "NFO", "NIFTY", "options", "27-Jul-2023", 19500, "Call", "43210"},
+		{"put", "NFO", "NIFTY", "options", "2023-07-27", 19500, "put", "43211"},
+		{"other exchange", "bfo", "BSXOPT", "options", "28-Jul-2023", 66000, "call", "867001"},
+		{"missing strike", "NFO", "NIFTY", "options", "27-Jul-2023", 19550, "call", ""},
+		{"missing expiry", "NFO", "NIFTY", "futures", "03-Aug-2023", 0, "", ""},
+	}
+	for _, tt := range tests {
+		inst, ok := r.Contract(tt.exchange, tt.code, tt.product, tt.expiry, tt.strike, tt.right)
+		if ok != (tt.token != "") || inst.Token != tt.token {
+			t.Errorf("%s: Contract = %q, %v; want %q", tt.name, inst.Token, ok, tt.token)
+		}
+	}
+
+	if got, want := r.Expiries("NFO", "NIFTY"), []string{"27-Jul-2023", "03-Aug-2023"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expiries = %v, want %v", got, want)
+	}
+	if got, want := r.Strikes("NFO", "NIFTY", "2023-07-27"), []float64{19450, 19500}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Strikes = %v, want %v", got, want)
+	}
+	if got := r.Strikes("NFO", "NIFTY", "31-Aug-2023"); len(got) != 0 {
+		t.Errorf("Strikes for an unlisted expiry = %v", got)
+	}
+}
+
+func TestInstrumentRegistryAddReplaces(t *testing.T) {
+	r := loadTestRegistry(t)
+	n := r.Len()
+
+	// The same token re-listed with a new strike moves out of its old
+	// contract slot instead of being registered twice.
+	r.Add(Instrument{Token: "43212", ISECCode: "NIFTY", Exchange: "nfo", InstrumentType: "OPT", ExpiryDate: "27-Jul-2023", StrikePrice: 19550, Right: "call"})
+	if r.Len() != n {
+		t.Errorf("Len = %d after replacing a token, want %d", r.Len(), n)
+	}
+	if _, ok := r.Contract("NFO", "NIFTY", "options", "27-Jul-2023", 19450, "call"); ok {
+		t.Error("the replaced contract still resolves")
+	}
+	if inst, ok := r.Contract("NFO", "NIFTY", "options", "27-Jul-2023", 19550, "call"); !ok || inst.Token != "43212" {
+		t.Errorf("replacement contract = %+v, %v", inst, ok)
+	}
+	if got, want := r.Strikes("NFO", "NIFTY", "27-Jul-2023"), []float64{19500, 19550}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Strikes = %v, want %v", got, want)
+	}
+
+	r.Add(Instrument{Token: "1660", ISECCode: "ITCNEW", Exchange: "NSE", Name: "ITC LIMITED"})
+	if _, ok := r.Stock("NSE", "ITC"); ok {
+		t.Error("the replaced stock code still resolves")
+	}
+	if inst, ok := r.Stock("NSE", "ITCNEW"); !ok || inst.Token != "1660" {
+		t.Errorf("Stock(NSE, ITCNEW) = %+v, %v", inst, ok)
+	}
+	if inst, _ := r.ByToken("NSE", "1660"); inst.Name != "ITC LIMITED" {
+		t.Errorf("ByToken after replace = %+v", inst)
+	}
+	count := 0
+	for _, inst := range r.Instruments() {
+		if inst.Exchange == "NSE" && inst.Token == "1660" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("NSE 1660 listed %d times, want once", count)
+	}
+}
+
+func TestInstrumentRegistrySearch(t *testing.T) {
+	r := loadTestRegistry(t)
+	r.Add(Instrument{Token: "11536", ISECCode: "TCS", Exchange: "NSE", Name: "TATA CONSULTANCY SERVICES"})
+	r.Add(Instrument{Token: "3456", ISECCode: "TATMOT", Exchange: "NSE", Name: "TATA MOTORS"})
+
+	tests := []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		{"itc", 0, []string{"NSE:ITC", "BSE:ITC", "NSE:INFTEC"}},
+		{"itc", 1, []string{"NSE:ITC"}},
+		{"TAT", 0, []string{"NSE:TATMOT", "NSE:TCS"}},
+		{"motors", 0, []string{"NSE:TATMOT"}},
+		{"INFY", 0, []string{"NSE:INFTEC"}},
+		{"XYZ", 0, nil},
+		{"IFTC", 0, []string{"NSE:INFTEC"}},
+		{"  ", 0, nil},
+		{"NIFTY", 0, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, inst := range r.Search(tt.query, tt.limit) {
+			got = append(got, inst.Exchange+":"+inst.ISECCode)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q, %d) = %v, want %v", tt.query, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestFeedInstrument(t *testing.T) {
+	b := NewBreezeInstance("app-key")
+	b.Instruments = loadTestRegistry(t)
+	tests := []struct {
+		symbol string
+		token  string
+	}{
+		{"4.1!1660", "1660"},
+		{"1.1!500875", "500875"},
+		{"4.1!43210", "43210"},
+		{"2.1!867001", "867001"},
+		{"8.1!867001", "867001"},
+		{"6.1!250001", "250001"},
+		{"9.1!1660", ""},
+		{"4.1!999", ""},
+		{"41660", ""},
+	}
+	for _, tt := range tests {
+		inst, ok := b.feedInstrument(tt.symbol)
+		if ok != (tt.token != "") || inst.Token != tt.token {
+			t.Errorf("feedInstrument(%q) = %q, %v; want %q", tt.symbol, inst.Token, ok, tt.token)
+		}
+	}
+}
@@ -9,8 +9,8 @@ func main() {
 	apiSecret := "your_api_secret"
 	sessionToken := "your_session_token"
 
-	bc := NewBreezeConnect(apiKey)
-	if err := bc.generateSession(apiSecret, sessionToken); err != nil {
+	bc := NewBreezeInstance(apiKey)
+	if err := bc.GenerateSession(apiSecret, sessionToken); err != nil {
 		fmt.Println("Error:", err)
 		return
 	}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MarginPosition is one leg of a basket sent to the margin calculator.
+type MarginPosition struct {
+	StrikePrice    string `json:"strike_price"`
+	Quantity       string `json:"quantity"`
+	Right          string `json:"right"`
+	Product        string `json:"product"`
+	Action         string `json:"action"`
+	Price          string `json:"price"`
+	ExpiryDate     string `json:"expiry_date"`
+	StockCode      string `json:"stock_code"`
+	CoverOrderFlow string `json:"cover_order_flow"`
+	FreshOrderType string `json:"fresh_order_type"`
+	CoverLimitRate string `json:"cover_limit_rate"`
+	CoverSLTPPrice string `json:"cover_sltp_price"`
+	FreshLimitRate string `json:"fresh_limit_rate"`
+	OpenQuantity   string `json:"open_quantity"`
+}
+
+type MarginLeg struct {
+	StrikePrice Float  `json:"strike_price"`
+	Quantity    Int    `json:"quantity"`
+	Right       string `json:"right"`
+	Product     string `json:"product"`
+	Action      string `json:"action"`
+	Price       Float  `json:"price"`
+	ExpiryDate  string `json:"expiry_date"`
+	StockCode   string `json:"stock_code"`
+}
+
+type MarginResult struct {
+	Legs                  []MarginLeg `json:"margin_calulation"`
+	NonSpanMarginRequired Float       `json:"non_span_margin_required"`
+	OrderValue            Float       `json:"order_value"`
+	OrderMargin           Float       `json:"order_margin"`
+	TradeMargin           Float       `json:"trade_margin"`
+	BlockTradeMargin      Float       `json:"block_trade_margin"`
+	SpanMarginRequired    Float       `json:"span_margin_required"`
+}
+
+// TotalRequired is the span plus exposure (non-span) margin of the basket.
+func (m *MarginResult) TotalRequired() float64 {
+	return float64(m.SpanMarginRequired) + float64(m.NonSpanMarginRequired)
+}
+
+type LimitCalculatorRequest struct {
+	StrikePrice       string `json:"strike_price"`
+	ProductType       string `json:"product_type"`
+	ExpiryDate        string `json:"expiry_date"`
+	Underlying        string `json:"underlying"`
+	ExchangeCode      string `json:"exchange_code"`
+	OrderFlow         string `json:"order_flow"`
+	StopLossTrigger   string `json:"stop_loss_trigger"`
+	OptionType        string `json:"option_type"`
+	SourceFlag        string `json:"source_flag"`
+	LimitRate         string `json:"limit_rate"`
+	OrderReference    string `json:"order_reference"`
+	AvailableQuantity string `json:"available_quantity"`
+	MarketType        string `json:"market_type"`
+	FreshOrderLimit   string `json:"fresh_order_limit"`
+}
+
+type LimitCalculation struct {
+	AvailableQuantity Int    `json:"available_quantity"`
+	ActionID          string `json:"action_id"`
+	OrderActionID     string `json:"order_action_id"`
+	LimitRate         Float  `json:"limit_rate"`
+	OrderLimitRate    Float  `json:"order_limit_rate"`
+}
+
+// OrderSize is the quantity SizeOrder fits into the available funds.
+type OrderSize struct {
+	Quantity     int
+	Lots         int
+	LimitPrice   float64
+	MarginPerLot float64
+	Available    float64
+}
+
+func (a *ApificationBreeze) GetMargin(positions []MarginPosition, exchangeCode string) (map[string]interface{}, error) {
+	if err := validateMarginBasket(positions, exchangeCode); err != nil {
+		return nil, err
+	}
+	return a.requestMap("POST", "/"+string(MARGIN_CALCULATOR), marginBody(positions, exchangeCode))
+}
+
+// GetMarginTyped returns the span and exposure margin the basket needs,
+// with offsets between its legs taken into account.
+func (a *ApificationBreeze) GetMarginTyped(positions []MarginPosition, exchangeCode string) (*MarginResult, error) {
+	if err := validateMarginBasket(positions, exchangeCode); err != nil {
+		return nil, err
+	}
+	return callAPI[*MarginResult](a, "POST", "/"+string(MARGIN_CALCULATOR), marginBody(positions, exchangeCode))
+}
+
+func validateMarginBasket(positions []MarginPosition, exchangeCode string) error {
+	if exchangeCode == "" {
+		return &ValidationError{Message: "Exchange code cannot be empty"}
+	}
+	if len(positions) == 0 {
+		return &ValidationError{Message: "List of positions cannot be empty"}
+	}
+	for _, p := range positions {
+		if p.StockCode == "" || p.Quantity == "" || p.Action == "" || p.Product == "" {
+			return &ValidationError{Message: "Stock code, quantity, action or product cannot be empty"}
+		}
+	}
+	return nil
+}
+
+func marginBody(positions []MarginPosition, exchangeCode string) map[string]interface{} {
+	return map[string]interface{}{
+		"list_of_positions": positions,
+		"exchange_code":     exchangeCode,
+	}
+}
+
+func (a *ApificationBreeze) GetLimitCalculator(req LimitCalculatorRequest) (map[string]interface{}, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	return a.requestMap("POST", "/"+string(LIMIT_CALCULATOR), req)
+}
+
+// GetLimitCalculatorTyped asks the limit calculator for the limit rate and
+// quantity an F&O order would be accepted at.
+func (a *ApificationBreeze) GetLimitCalculatorTyped(req LimitCalculatorRequest) (*LimitCalculation, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	return callAPI[*LimitCalculation](a, "POST", "/"+string(LIMIT_CALCULATOR), req)
+}
+
+func (r *LimitCalculatorRequest) validate() error {
+	if r.Underlying == "" || r.ExchangeCode == "" || r.ProductType == "" || r.ExpiryDate == "" || r.OrderFlow == "" {
+		return &ValidationError{Message: "Underlying, exchange code, product type, expiry date or order flow cannot be empty"}
+	}
+	r.ProductType = strings.ToLower(r.ProductType)
+	r.OrderFlow = strings.ToLower(r.OrderFlow)
+	r.OptionType = strings.ToLower(r.OptionType)
+	if r.ProductType == "options" && r.OptionType != "call" && r.OptionType != "put" {
+		return &ValidationError{Message: "Option type should be call or put for options"}
+	}
+	return nil
+}
+
+// SizeOrder prices the order with the limit calculator, asks the margin
+// calculator what one lot needs at that price and returns the largest
+// whole number of lots the segment's unblocked funds can carry.
+func (a *ApificationBreeze) SizeOrder(req LimitCalculatorRequest, lotSize int) (*OrderSize, error) {
+	if lotSize <= 0 {
+		return nil, NewValidationError("Lot size should be greater than zero")
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	limit, err := a.GetLimitCalculatorTyped(req)
+	if err != nil {
+		return nil, err
+	}
+	price := float64(limit.LimitRate)
+	if price <= 0 {
+		price = float64(limit.OrderLimitRate)
+	}
+
+	leg := MarginPosition{
+		StrikePrice:    req.StrikePrice,
+		Quantity:       strconv.Itoa(lotSize),
+		Right:          req.OptionType,
+		Product:        req.ProductType,
+		Action:         req.OrderFlow,
+		Price:          strconv.FormatFloat(price, 'f', -1, 64),
+		ExpiryDate:     req.ExpiryDate,
+		StockCode:      req.Underlying,
+		FreshOrderType: "limit",
+		FreshLimitRate: strconv.FormatFloat(price, 'f', -1, 64),
+	}
+	margin, err := a.GetMarginTyped([]MarginPosition{leg}, req.ExchangeCode)
+	if err != nil {
+		return nil, err
+	}
+	perLot := margin.TotalRequired()
+	if perLot <= 0 {
+		// Long options carry no span; the premium is what gets blocked.
+		perLot = price * float64(lotSize)
+	}
+
+	funds, err := a.GetFundsTyped()
+	if err != nil {
+		return nil, err
+	}
+	available := funds.availableFor(req.ExchangeCode)
+
+	size := &OrderSize{LimitPrice: price, MarginPerLot: perLot, Available: available}
+	if perLot > 0 && available > 0 {
+		size.Lots = int(math.Floor(available / perLot))
+		size.Quantity = size.Lots * lotSize
+	}
+	return size, nil
+}
+
+// availableFor returns the allocated, unblocked balance of the segment the
+// exchange trades in.
+func (f *Funds) availableFor(exchangeCode string) float64 {
+	switch strings.ToLower(exchangeCode) {
+	case "nfo", "bfo":
+		return float64(f.AllocatedFNO - f.BlockByTradeFNO)
+	case "mcx":
+		return float64(f.AllocatedCommodity - f.BlockByTradeCommodity)
+	case "ndx":
+		return float64(f.AllocatedCurrency - f.BlockByTradeCurrency)
+	default:
+		return float64(f.AllocatedEquity - f.BlockByTradeEquity)
+	}
+}
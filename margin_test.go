@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func niftyCallLimitRequest() LimitCalculatorRequest {
+	return LimitCalculatorRequest{
+		StrikePrice:  "19500",
+		ProductType:  "Options",
+		ExpiryDate:   "27-Jul-2023",
+		Underlying:   "NIFTY",
+		ExchangeCode: "NFO",
+		OrderFlow:    "Buy",
+		OptionType:   "Call",
+	}
+}
+
+func TestGetLimitCalculatorTyped(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	f.respond(http.StatusOK, `{"Success":{"available_quantity":"1800","action_id":"0","order_action_id":"0","limit_rate":"112.35","order_limit_rate":"112.3"},"Status":200,"Error":null}`)
+
+	limit, err := a.GetLimitCalculatorTyped(niftyCallLimitRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit.LimitRate != 112.35 || limit.OrderLimitRate != 112.3 || limit.AvailableQuantity != 1800 {
+		t.Errorf("limit = %+v", limit)
+	}
+	call := f.last(t)
+	if call.Method != "POST" || call.Path != "/fnolmtpriceandqtycal" {
+		t.Errorf("request = %s %s, want POST /fnolmtpriceandqtycal", call.Method, call.Path)
+	}
+	checkSigned(t, call)
+	checkBody(t, call, map[string]string{"product_type": "options", "order_flow": "buy", "option_type": "call", "underlying": "NIFTY"})
+}
+
+func TestLimitCalculatorValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(r *LimitCalculatorRequest)
+	}{
+		{"missing underlying", func(r *LimitCalculatorRequest) { r.Underlying = "" }},
+		{"missing exchange", func(r *LimitCalculatorRequest) { r.ExchangeCode = "" }},
+		{"missing expiry", func(r *LimitCalculatorRequest) { r.ExpiryDate = "" }},
+		{"missing order flow", func(r *LimitCalculatorRequest) { r.OrderFlow = "" }},
+		{"options without option type", func(r *LimitCalculatorRequest) { r.OptionType = "" }},
+		{"options with unknown option type", func(r *LimitCalculatorRequest) { r.OptionType = "others" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, a := newFakeBreezeAPI(t)
+			req := niftyCallLimitRequest()
+			tt.change(&req)
+			if _, err := a.GetLimitCalculatorTyped(req); !errors.Is(err, ErrValidation) {
+				t.Errorf("GetLimitCalculatorTyped error = %v, want ErrValidation", err)
+			}
+			if _, err := a.SizeOrder(req, 50); !errors.Is(err, ErrValidation) {
+				t.Errorf("SizeOrder error = %v, want ErrValidation", err)
+			}
+			if calls := f.received(); len(calls) != 0 {
+				t.Errorf("invalid request reached the API: %v", calls)
+			}
+		})
+	}
+}
+
+func TestSizeOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     string
+		margin    string
+		funds     string
+		lots      int
+		price     float64
+		perLot    float64
+		available float64
+	}{
+		{
+			"span margin",
+			`{"limit_rate":"112.35"}`,
+			`{"span_margin_required":"40000","non_span_margin_required":"10000.5"}`,
+			`{"allocated_fno":"160000","block_by_trade_fno":"9000","allocated_equity":"900000"}`,
+			3, 112.35, 50000.5, 151000,
+		},
+		{
+			"order limit rate when limit rate is missing",
+			`{"limit_rate":"0","order_limit_rate":"98.5"}`,
+			`{"span_margin_required":"0","non_span_margin_required":"0"}`,
+			`{"allocated_fno":"10000","block_by_trade_fno":"0"}`,
+			2, 98.5, 4925, 10000,
+		},
+		{
+			"long option pays the premium",
+			`{"limit_rate":"112.35"}`,
+			`{"span_margin_required":"0","non_span_margin_required":"0"}`,
+			`{"allocated_fno":"20000","block_by_trade_fno":"5000"}`,
+			2, 112.35, 5617.5, 15000,
+		},
+		{
+			"funds short of one lot",
+			`{"limit_rate":"112.35"}`,
+			`{"span_margin_required":"40000"}`,
+			`{"allocated_fno":"39999.99"}`,
+			0, 112.35, 40000, 39999.99,
+		},
+		{
+			"segment fully blocked",
+			`{"limit_rate":"112.35"}`,
+			`{"span_margin_required":"40000"}`,
+			`{"allocated_fno":"50000","block_by_trade_fno":"60000"}`,
+			0, 112.35, 40000, -10000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, a := newFakeBreezeAPI(t)
+			f.route("POST /fnolmtpriceandqtycal", http.StatusOK, `{"Success":`+tt.limit+`,"Status":200,"Error":null}`)
+			f.route("POST /margincalculator", http.StatusOK, `{"Success":`+tt.margin+`,"Status":200,"Error":null}`)
+			f.route("GET /funds", http.StatusOK, `{"Success":`+tt.funds+`,"Status":200,"Error":null}`)
+
+			size, err := a.SizeOrder(niftyCallLimitRequest(), 50)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size.Lots != tt.lots || size.Quantity != tt.lots*50 {
+				t.Errorf("lots, quantity = %d, %d; want %d, %d", size.Lots, size.Quantity, tt.lots, tt.lots*50)
+			}
+			if size.LimitPrice != tt.price || size.MarginPerLot != tt.perLot || size.Available != tt.available {
+				t.Errorf("price, per lot, available = %v, %v, %v; want %v, %v, %v",
+					size.LimitPrice, size.MarginPerLot, size.Available, tt.price, tt.perLot, tt.available)
+			}
+
+			calls := f.received()
+			if len(calls) != 3 {
+				t.Fatalf("%d requests, want limit, margin and funds", len(calls))
+			}
+			margin := calls[1]
+			legs, _ := margin.Body["list_of_positions"].([]interface{})
+			if margin.Body["exchange_code"] != "NFO" || len(legs) != 1 {
+				t.Fatalf("margin request body = %v", margin.Body)
+			}
+			leg, _ := legs[0].(map[string]interface{})
+			if leg["quantity"] != "50" || leg["right"] != "call" || leg["action"] != "buy" || leg["fresh_order_type"] != "limit" {
+				t.Errorf("margin leg = %v", leg)
+			}
+		})
+	}
+}
+
+func TestSizeOrderErrors(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	if _, err := a.SizeOrder(niftyCallLimitRequest(), 0); !errors.Is(err, ErrValidation) {
+		t.Errorf("SizeOrder with no lot size: error = %v, want ErrValidation", err)
+	}
+	if calls := f.received(); len(calls) != 0 {
+		t.Errorf("invalid request reached the API: %v", calls)
+	}
+
+	f.route("POST /fnolmtpriceandqtycal", http.StatusOK, `{"Success":{"limit_rate":"112.35"},"Status":200,"Error":null}`)
+	f.route("POST /margincalculator", http.StatusOK, `{"Success":null,"Status":500,"Error":"Margin not available"}`)
+	if _, err := a.SizeOrder(niftyCallLimitRequest(), 50); !errors.Is(err, ErrAPI) {
+		t.Errorf("SizeOrder with a failed margin call: error = %v, want ErrAPI", err)
+	}
+	if calls := f.received(); len(calls) != 2 {
+		t.Errorf("%d requests, want funds skipped after the margin failure", len(calls))
+	}
+}
+
+func TestFundsAvailableFor(t *testing.T) {
+	funds := &Funds{
+		AllocatedEquity:       100000,
+		AllocatedFNO:          50000,
+		AllocatedCommodity:    30000,
+		AllocatedCurrency:     20000,
+		BlockByTradeEquity:    1000,
+		BlockByTradeFNO:       2000,
+		BlockByTradeCommodity: 3000,
+		BlockByTradeCurrency:  4000,
+	}
+	tests := []struct {
+		exchange string
+		want     float64
+	}{
+		{"NSE", 99000},
+		{"BSE", 99000},
+		{"", 99000},
+		{"NFO", 48000},
+		{"bfo", 48000},
+		{"MCX", 27000},
+		{"NDX", 16000},
+	}
+	for _, tt := range tests {
+		if got := funds.availableFor(tt.exchange); got != tt.want {
+			t.Errorf("availableFor(%q) = %v, want %v", tt.exchange, got, tt.want)
+		}
+	}
+}
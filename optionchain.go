@@ -0,0 +1,287 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type OptionQuote struct {
+	ExchangeCode        string `json:"exchange_code"`
+	ProductType         string `json:"product_type"`
+	StockCode           string `json:"stock_code"`
+	ExpiryDate          string `json:"expiry_date"`
+	Right               string `json:"right"`
+	StrikePrice         Float  `json:"strike_price"`
+	LTP                 Float  `json:"ltp"`
+	LTT                 string `json:"ltt"`
+	BestBidPrice        Float  `json:"best_bid_price"`
+	BestBidQuantity     Int    `json:"best_bid_quantity"`
+	BestOfferPrice      Float  `json:"best_offer_price"`
+	BestOfferQuantity   Int    `json:"best_offer_quantity"`
+	Open                Float  `json:"open"`
+	High                Float  `json:"high"`
+	Low                 Float  `json:"low"`
+	PreviousClose       Float  `json:"previous_close"`
+	LTPPercentChange    Float  `json:"ltp_percent_change"`
+	UpperCircuit        Float  `json:"upper_circuit"`
+	LowerCircuit        Float  `json:"lower_circuit"`
+	TotalQuantityTraded Int    `json:"total_quantity_traded"`
+	SpotPrice           Float  `json:"spot_price"`
+	LTQ                 Int    `json:"ltq"`
+	OpenInterest        Float  `json:"open_interest"`
+	ChangeOI            Float  `json:"chnge_oi"`
+	TotalBuyQty         Int    `json:"total_buy_qty"`
+	TotalSellQty        Int    `json:"total_sell_qty"`
+
+	// Token is the quote stream token of the leg, set once it is resolved.
+	Token string `json:"-"`
+
+	// Greeks is filled when the chain has a GreeksCalculator.
+	Greeks OptionGreeks `json:"-"`
+}
+
+type optionKey struct {
+	expiry string
+	strike float64
+	right  string
+}
+
+// OptionChain holds the legs of an option chain indexed by expiry, strike
+// and right. After Subscribe, ticks for the legs keep LTP, OI and the best
+// bid/ask current.
+type OptionChain struct {
+	StockCode    string
+	ExchangeCode string
+
+	breeze  *BreezeInstance
+	mu      sync.RWMutex
+	legs    map[optionKey]*OptionQuote
+	byToken map[string]*OptionQuote
+	detach  func()
+	// subscribed holds the tokens Subscribe has requested, for Stop to
+	// release.
+	subscribed map[string]bool
+
+	// Greeks, when set, recomputes each leg's IV and Greeks as it ticks.
+	Greeks *GreeksCalculator
+
+	OnUpdate func(OptionQuote)
+}
+
+func (a *ApificationBreeze) GetOptionChain(stockCode, exchangeCode, expiry, right, strike string) (*OptionChain, error) {
+	right = strings.ToLower(right)
+	if stockCode == "" || exchangeCode == "" {
+		return nil, NewValidationError("Stock code or exchange code cannot be empty")
+	}
+	if !strings.EqualFold(exchangeCode, "nfo") && !strings.EqualFold(exchangeCode, "bfo") {
+		return nil, NewValidationError("Exchange code should be nfo or bfo for option chain")
+	}
+	if right != "" && right != "call" && right != "put" {
+		return nil, NewValidationError("Right should be call or put")
+	}
+	if expiry == "" && right == "" && strike == "" {
+		return nil, NewValidationError("At least one of expiry, right or strike is required")
+	}
+
+	body := map[string]string{
+		"stock_code":    stockCode,
+		"exchange_code": exchangeCode,
+		"expiry_date":   expiry,
+		"product_type":  "options",
+		"right":         right,
+		"strike_price":  strike,
+	}
+	quotes, err := callAPI[[]OptionQuote](a, "GET", "/"+string(OPT_CHAIN), body)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := &OptionChain{
+		StockCode:    stockCode,
+		ExchangeCode: exchangeCode,
+		breeze:       a.Breeze,
+		legs:         map[optionKey]*OptionQuote{},
+		byToken:      map[string]*OptionQuote{},
+	}
+	for i := range quotes {
+		q := quotes[i]
+		chain.legs[chain.key(q.ExpiryDate, float64(q.StrikePrice), q.Right)] = &q
+	}
+	return chain, nil
+}
+
+func (c *OptionChain) key(expiry string, strike float64, right string) optionKey {
+	return optionKey{expiry: strings.ToLower(expiry), strike: strike, right: strings.ToLower(right)}
+}
+
+// Get returns the leg for expiry, strike and right ("call" or "put").
+func (c *OptionChain) Get(expiry string, strike float64, right string) (OptionQuote, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	q, ok := c.legs[c.key(expiry, strike, right)]
+	if !ok {
+		return OptionQuote{}, false
+	}
+	return *q, true
+}
+
+// Legs returns every leg sorted by expiry, strike and right.
+func (c *OptionChain) Legs() []OptionQuote {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	legs := make([]OptionQuote, 0, len(c.legs))
+	for _, q := range c.legs {
+		legs = append(legs, *q)
+	}
+	sort.Slice(legs, func(i, j int) bool {
+		if ei, ej := normaliseExpiry(legs[i].ExpiryDate), normaliseExpiry(legs[j].ExpiryDate); ei != ej {
+			return ei < ej
+		}
+		if legs[i].StrikePrice != legs[j].StrikePrice {
+			return legs[i].StrikePrice < legs[j].StrikePrice
+		}
+		return legs[i].Right < legs[j].Right
+	})
+	return legs
+}
+
+// Expiries lists the chain's expiries, nearest first.
+func (c *OptionChain) Expiries() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	seen := map[string]bool{}
+	expiries := []string{}
+	for _, q := range c.legs {
+		if !seen[q.ExpiryDate] {
+			seen[q.ExpiryDate] = true
+			expiries = append(expiries, q.ExpiryDate)
+		}
+	}
+	sort.Slice(expiries, func(i, j int) bool {
+		return normaliseExpiry(expiries[i]) < normaliseExpiry(expiries[j])
+	})
+	return expiries
+}
+
+// Strikes lists the strikes available for expiry in ascending order.
+func (c *OptionChain) Strikes(expiry string) []float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	seen := map[float64]bool{}
+	strikes := []float64{}
+	for k := range c.legs {
+		if k.expiry == strings.ToLower(expiry) && !seen[k.strike] {
+			seen[k.strike] = true
+			strikes = append(strikes, k.strike)
+		}
+	}
+	sort.Float64s(strikes)
+	return strikes
+}
+
+// Subscribe requests the quote feed of every leg through SubscribeFeeds and
+// starts applying their ticks. Legs whose token cannot be resolved from the
+// scrip list are skipped and reported in the returned error. Legs already
+// subscribed are not requested again, and those subscribed before a
+// SubscribeFeeds failure stay recorded for Stop.
+func (c *OptionChain) Subscribe() error {
+	c.mu.Lock()
+	var firstErr error
+	tokens := []string{}
+	for _, q := range c.legs {
+		if q.Token == "" {
+			strike := strconv.FormatFloat(float64(q.StrikePrice), 'f', -1, 64)
+			token, _, err := c.breeze.getStockTokenValue(strings.ToUpper(c.ExchangeCode), c.StockCode, "options", q.ExpiryDate, strike, strings.ToLower(q.Right), true, false)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			q.Token = token
+			c.byToken[token] = q
+		}
+		if !c.subscribed[q.Token] {
+			tokens = append(tokens, q.Token)
+		}
+	}
+	if c.detach == nil {
+		c.detach = c.breeze.addTickHandler(c.apply)
+	}
+	c.mu.Unlock()
+
+	for _, token := range tokens {
+		if _, err := c.breeze.SubscribeFeeds(token, "", "", "", "", "", "", "", true, false, false); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		if c.subscribed == nil {
+			c.subscribed = map[string]bool{}
+		}
+		c.subscribed[token] = true
+		c.mu.Unlock()
+	}
+	return firstErr
+}
+
+// Stop detaches the chain from the tick stream and releases the feeds
+// Subscribe requested, so its legs stop updating. A later Subscribe
+// attaches and subscribes it again.
+func (c *OptionChain) Stop() {
+	c.mu.Lock()
+	detach := c.detach
+	tokens := c.subscribed
+	c.detach, c.subscribed = nil, nil
+	c.mu.Unlock()
+	if detach != nil {
+		detach()
+	}
+	for token := range tokens {
+		c.breeze.UnsubscribeFeeds(token, "", "", "", "", "", "", "", true, false, false)
+	}
+}
+
+func (c *OptionChain) apply(tick map[string]interface{}) {
+	symbol, ok := tick["symbol"].(string)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	q, ok := c.byToken[symbol]
+	if !ok {
+		c.mu.Unlock()
+		return
+	}
+	if v, ok := tickFloat(tick["last"]); ok {
+		q.LTP = Float(v)
+	}
+	if v, ok := tickFloat(tick["OI"]); ok {
+		q.OpenInterest = Float(v)
+	}
+	if v, ok := tickFloat(tick["CHNGOI"]); ok {
+		q.ChangeOI = Float(v)
+	}
+	if v, ok := tickFloat(tick["bPrice"]); ok {
+		q.BestBidPrice = Float(v)
+	}
+	if v, ok := tickFloat(tick["bQty"]); ok {
+		q.BestBidQuantity = Int(v)
+	}
+	if v, ok := tickFloat(tick["sPrice"]); ok {
+		q.BestOfferPrice = Float(v)
+	}
+	if v, ok := tickFloat(tick["sQty"]); ok {
+		q.BestOfferQuantity = Int(v)
+	}
+	if c.Greeks != nil {
+		c.Greeks.Quote(q)
+	}
+	updated := *q
+	c.mu.Unlock()
+
+	if c.OnUpdate != nil {
+		c.OnUpdate(updated)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrOrderFinished is returned by OrderBook.WaitFor when the order reaches
+// a final state other than the one waited for.
+var ErrOrderFinished = errors.New("order finished in another state")
+
+// OrderState is where an order stands in its lifecycle. Pending, Open and
+// PartiallyFilled are live; the rest are final.
+type OrderState int
+
+const (
+	OrderUnknown OrderState = iota
+	OrderPending
+	OrderOpen
+	OrderPartiallyFilled
+	OrderFilled
+	OrderCancelled
+	OrderRejected
+	OrderExpired
+)
+
+func (s OrderState) String() string {
+	switch s {
+	case OrderPending:
+		return "pending"
+	case OrderOpen:
+		return "open"
+	case OrderPartiallyFilled:
+		return "partially filled"
+	case OrderFilled:
+		return "filled"
+	case OrderCancelled:
+		return "cancelled"
+	case OrderRejected:
+		return "rejected"
+	case OrderExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+func (s OrderState) Final() bool {
+	return s >= OrderFilled
+}
+
+// rank orders the states along the lifecycle; every final state ranks the
+// same, since none follows another.
+func (s OrderState) rank() int {
+	if s.Final() {
+		return int(OrderFilled)
+	}
+	return int(s)
+}
+
+// State places the status on the order lifecycle. An order cancelled or
+// expired after a partial fill ends as cancelled or expired; its
+// ExecutedQuantity says how much traded.
+func (s OrderStatus) State() OrderState {
+	switch s {
+	case OrderStatusRequested, OrderStatusQueued, OrderStatusFreezed:
+		return OrderPending
+	case OrderStatusOrdered:
+		return OrderOpen
+	case OrderStatusPartiallyExecuted:
+		return OrderPartiallyFilled
+	case OrderStatusExecuted:
+		return OrderFilled
+	case OrderStatusCancelled, OrderStatusPartiallyExecutedAndCancelled:
+		return OrderCancelled
+	case OrderStatusRejected:
+		return OrderRejected
+	case OrderStatusExpired, OrderStatusPartiallyExecutedAndExpired:
+		return OrderExpired
+	}
+	return OrderUnknown
+}
+
+// BookOrder is the latest known state of one order.
+type BookOrder struct {
+	OrderID   string
+	State     OrderState
+	Sequence  int64
+	Update    OrderUpdate
+	UpdatedAt time.Time
+
+	seen    int
+	visited uint16
+}
+
+// reached reports whether the order has been in state. A live order has
+// passed every live state before its own, and an order that traded has
+// been open and partially filled even if those notifications were missed.
+// A final state is only reached by ending in it.
+func (o *BookOrder) reached(state OrderState) bool {
+	if o.visited&(1<<state) != 0 {
+		return true
+	}
+	switch {
+	case state.Final():
+		return false
+	case !o.State.Final():
+		return o.State.rank() >= state.rank()
+	}
+	return o.State == OrderFilled || o.Update.ExecutedQuantity > 0
+}
+
+// OrderBook tracks orders from the order notification stream. Each update
+// moves its order forward through Pending, Open and PartiallyFilled to a
+// final state; updates that arrive out of order, by messageSequence or by
+// going back in the lifecycle, are ignored.
+type OrderBook struct {
+	mu      sync.Mutex
+	orders  map[string]*BookOrder
+	changed chan struct{}
+	detach  func()
+}
+
+func NewOrderBook() *OrderBook {
+	return &OrderBook{
+		orders:  map[string]*BookOrder{},
+		changed: make(chan struct{}),
+	}
+}
+
+// EnableOrderBook hooks an OrderBook into the decoded ticks. Subscribe with
+// getOrderNotification set for the updates to flow.
+func (b *BreezeInstance) EnableOrderBook() *OrderBook {
+	book := NewOrderBook()
+	book.detach = b.addTypedTickHandler(func(t *Tick) {
+		if t.Kind == TickOrder {
+			book.Apply(&t.Order)
+		}
+	})
+	return book
+}
+
+// Stop detaches a book returned by EnableOrderBook from the tick stream.
+// The orders already tracked stay readable.
+func (ob *OrderBook) Stop() {
+	if ob.detach != nil {
+		ob.detach()
+	}
+}
+
+// Apply folds u into its order and reports whether it changed the book.
+func (ob *OrderBook) Apply(u *OrderUpdate) bool {
+	if u.OrderReference == "" {
+		return false
+	}
+	state := u.Status().State()
+
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	order, ok := ob.orders[u.OrderReference]
+	if ok {
+		if u.MessageSequence != 0 && order.Sequence != 0 && u.MessageSequence <= order.Sequence {
+			return false
+		}
+		if state == OrderUnknown {
+			state = order.State
+		}
+		if order.State.Final() || state.rank() < order.State.rank() {
+			return false
+		}
+	} else {
+		order = &BookOrder{OrderID: u.OrderReference, seen: len(ob.orders)}
+		ob.orders[u.OrderReference] = order
+	}
+	order.State = state
+	order.visited |= 1 << state
+	order.Sequence = u.MessageSequence
+	order.Update = *u
+	order.UpdatedAt = time.Now()
+
+	close(ob.changed)
+	ob.changed = make(chan struct{})
+	return true
+}
+
+func (ob *OrderBook) Get(orderID string) (BookOrder, bool) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	order, ok := ob.orders[orderID]
+	if !ok {
+		return BookOrder{}, false
+	}
+	return *order, true
+}
+
+// List returns every tracked order in the order it was first seen.
+func (ob *OrderBook) List() []BookOrder {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	out := make([]BookOrder, 0, len(ob.orders))
+	for _, order := range ob.orders {
+		out = append(out, *order)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].seen < out[j].seen })
+	return out
+}
+
+// WaitFor blocks until orderID reaches state and returns the order. A live
+// state is also satisfied by a later state the order can only have reached
+// through it, so waiting for OrderOpen returns once the order is filled,
+// but not when it is cancelled without having been open or traded. If the
+// order ends without reaching state WaitFor returns it with an error
+// matching ErrOrderFinished.
+func (ob *OrderBook) WaitFor(ctx context.Context, orderID string, state OrderState) (BookOrder, error) {
+	for {
+		ob.mu.Lock()
+		order, ok := ob.orders[orderID]
+		var current BookOrder
+		if ok {
+			current = *order
+		}
+		changed := ob.changed
+		ob.mu.Unlock()
+
+		if ok {
+			switch {
+			case current.reached(state):
+				return current, nil
+			case current.State.Final():
+				return current, fmt.Errorf("order %s %s: %w", orderID, current.State, ErrOrderFinished)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return current, ctx.Err()
+		case <-changed:
+		}
+	}
+}
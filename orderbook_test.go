@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func orderUpdate(id string, seq int64, status OrderStatus, executed int64) *OrderUpdate {
+	return &OrderUpdate{OrderReference: id, MessageSequence: seq, OrderStatus: string(status), ExecutedQuantity: executed}
+}
+
+func TestOrderStatusState(t *testing.T) {
+	tests := []struct {
+		status OrderStatus
+		state  OrderState
+		final  bool
+	}{
+		{OrderStatusRequested, OrderPending, false},
+		{OrderStatusQueued, OrderPending, false},
+		{OrderStatusFreezed, OrderPending, false},
+		{OrderStatusOrdered, OrderOpen, false},
+		{OrderStatusPartiallyExecuted, OrderPartiallyFilled, false},
+		{OrderStatusExecuted, OrderFilled, true},
+		{OrderStatusCancelled, OrderCancelled, true},
+		{OrderStatusPartiallyExecutedAndCancelled, OrderCancelled, true},
+		{OrderStatusRejected, OrderRejected, true},
+		{OrderStatusExpired, OrderExpired, true},
+		{OrderStatusPartiallyExecutedAndExpired, OrderExpired, true},
+		{OrderStatusAll, OrderUnknown, false},
+		{"Z", OrderUnknown, false},
+	}
+	for _, tt := range tests {
+		state := tt.status.State()
+		if state != tt.state || state.Final() != tt.final {
+			t.Errorf("%q.State() = %s (final %v), want %s (final %v)", tt.status, state, state.Final(), tt.state, tt.final)
+		}
+	}
+}
+
+func TestOrderBookApply(t *testing.T) {
+	type step struct {
+		update  *OrderUpdate
+		applied bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		state OrderState
+		seq   int64
+	}{
+		{"in order", []step{
+			{orderUpdate("1", 1, OrderStatusRequested, 0), true},
+			{orderUpdate("1", 2, OrderStatusOrdered, 0), true},
+			{orderUpdate("1", 3, OrderStatusPartiallyExecuted, 5), true},
+			{orderUpdate("1", 4, OrderStatusExecuted, 10), true},
+		}, OrderFilled, 4},
+		{"older sequence", []step{
+			{orderUpdate("1", 5, OrderStatusOrdered, 0), true},
+			{orderUpdate("1", 4, OrderStatusPartiallyExecuted, 5), false},
+		}, OrderOpen, 5},
+		{"repeated sequence", []step{
+			{orderUpdate("1", 5, OrderStatusOrdered, 0), true},
+			{orderUpdate("1", 5, OrderStatusExecuted, 10), false},
+		}, OrderOpen, 5},
+		{"unsequenced updates by rank", []step{
+			{orderUpdate("1", 0, OrderStatusOrdered, 0), true},
+			{orderUpdate("1", 0, OrderStatusRequested, 0), false},
+			{orderUpdate("1", 0, OrderStatusOrdered, 0), true},
+			{orderUpdate("1", 0, OrderStatusPartiallyExecuted, 5), true},
+		}, OrderPartiallyFilled, 0},
+		{"lower rank with a newer sequence", []step{
+			{orderUpdate("1", 1, OrderStatusPartiallyExecuted, 5), true},
+			{orderUpdate("1", 2, OrderStatusOrdered, 0), false},
+		}, OrderPartiallyFilled, 1},
+		{"fill overtakes open", []step{
+			{orderUpdate("1", 1, OrderStatusRequested, 0), true},
+			{orderUpdate("1", 3, OrderStatusExecuted, 10), true},
+			{orderUpdate("1", 2, OrderStatusOrdered, 0), false},
+		}, OrderFilled, 3},
+		{"final is final", []step{
+			{orderUpdate("1", 1, OrderStatusCancelled, 0), true},
+			{orderUpdate("1", 2, OrderStatusExecuted, 10), false},
+			{orderUpdate("1", 3, OrderStatusCancelled, 0), false},
+		}, OrderCancelled, 1},
+		{"unknown status keeps the state", []step{
+			{orderUpdate("1", 1, OrderStatusOrdered, 0), true},
+			{orderUpdate("1", 2, "Z", 0), true},
+		}, OrderOpen, 2},
+		{"no reference", []step{
+			{orderUpdate("", 1, OrderStatusOrdered, 0), false},
+			{orderUpdate("1", 1, OrderStatusRejected, 0), true},
+		}, OrderRejected, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ob := NewOrderBook()
+			for i, s := range tt.steps {
+				if got := ob.Apply(s.update); got != s.applied {
+					t.Errorf("step %d (%s seq %d): Apply = %v, want %v", i, s.update.OrderStatus, s.update.MessageSequence, got, s.applied)
+				}
+			}
+			order, ok := ob.Get("1")
+			if !ok || order.State != tt.state || order.Sequence != tt.seq {
+				t.Errorf("order = %s seq %d (%v), want %s seq %d", order.State, order.Sequence, ok, tt.state, tt.seq)
+			}
+			if len(ob.List()) != 1 {
+				t.Errorf("List = %v, want one order", ob.List())
+			}
+		})
+	}
+}
+
+func TestBookOrderReached(t *testing.T) {
+	tests := []struct {
+		name    string
+		updates []*OrderUpdate
+		reached []OrderState
+		missed  []OrderState
+	}{
+		{"live partial fill",
+			[]*OrderUpdate{orderUpdate("1", 1, OrderStatusPartiallyExecuted, 5)},
+			[]OrderState{OrderPending, OrderOpen, OrderPartiallyFilled},
+			[]OrderState{OrderFilled, OrderCancelled}},
+		{"filled without notifications on the way",
+			[]*OrderUpdate{orderUpdate("1", 1, OrderStatusExecuted, 10)},
+			[]OrderState{OrderPending, OrderOpen, OrderPartiallyFilled, OrderFilled},
+			[]OrderState{OrderCancelled, OrderExpired}},
+		{"cancelled while pending",
+			[]*OrderUpdate{orderUpdate("1", 1, OrderStatusRequested, 0), orderUpdate("1", 2, OrderStatusCancelled, 0)},
+			[]OrderState{OrderPending, OrderCancelled},
+			[]OrderState{OrderOpen, OrderPartiallyFilled, OrderFilled}},
+		{"cancelled after being open",
+			[]*OrderUpdate{orderUpdate("1", 1, OrderStatusOrdered, 0), orderUpdate("1", 2, OrderStatusCancelled, 0)},
+			[]OrderState{OrderOpen, OrderCancelled},
+			[]OrderState{OrderPartiallyFilled, OrderFilled}},
+		{"cancelled after a partial fill",
+			[]*OrderUpdate{orderUpdate("1", 1, OrderStatusPartiallyExecutedAndCancelled, 4)},
+			[]OrderState{OrderOpen, OrderPartiallyFilled, OrderCancelled},
+			[]OrderState{OrderFilled, OrderExpired}},
+		{"rejected outright",
+			[]*OrderUpdate{orderUpdate("1", 1, OrderStatusRejected, 0)},
+			[]OrderState{OrderRejected},
+			[]OrderState{OrderPending, OrderOpen, OrderFilled, OrderCancelled}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ob := NewOrderBook()
+			for _, u := range tt.updates {
+				ob.Apply(u)
+			}
+			order, _ := ob.Get("1")
+			for _, state := range tt.reached {
+				if !order.reached(state) {
+					t.Errorf("%s order has not reached %s", order.State, state)
+				}
+			}
+			for _, state := range tt.missed {
+				if order.reached(state) {
+					t.Errorf("%s order has reached %s", order.State, state)
+				}
+			}
+		})
+	}
+}
+
+func TestOrderBookWaitFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		wait    OrderState
+		updates []*OrderUpdate
+		state   OrderState
+		err     error
+	}{
+		{"open on a fill", OrderOpen,
+			[]*OrderUpdate{orderUpdate("1", 1, OrderStatusRequested, 0), orderUpdate("1", 2, OrderStatusExecuted, 10)},
+			OrderFilled, nil},
+		{"open on a cancel", OrderOpen,
+			[]*OrderUpdate{orderUpdate("1", 1, OrderStatusRequested, 0), orderUpdate("1", 2, OrderStatusCancelled, 0)},
+			OrderCancelled, ErrOrderFinished},
+		{"open once ordered", OrderOpen,
+			[]*OrderUpdate{orderUpdate("1", 1, OrderStatusRequested, 0), orderUpdate("1", 2, OrderStatusOrdered, 0)},
+			OrderOpen, nil},
+		{"filled on an expiry", OrderFilled,
+			[]*OrderUpdate{orderUpdate("1", 1, OrderStatusOrdered, 0), orderUpdate("1", 2, OrderStatusPartiallyExecutedAndExpired, 3)},
+			OrderExpired, ErrOrderFinished},
+		{"cancelled", OrderCancelled,
+			[]*OrderUpdate{orderUpdate("2", 1, OrderStatusOrdered, 0), orderUpdate("1", 1, OrderStatusOrdered, 0), orderUpdate("1", 2, OrderStatusCancelled, 0)},
+			OrderCancelled, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ob := NewOrderBook()
+			go func() {
+				for _, u := range tt.updates {
+					time.Sleep(5 * time.Millisecond)
+					ob.Apply(u)
+				}
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			order, err := ob.WaitFor(ctx, "1", tt.wait)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("WaitFor error = %v, want %v", err, tt.err)
+			}
+			if order.State != tt.state {
+				t.Errorf("WaitFor returned a %s order, want %s", order.State, tt.state)
+			}
+		})
+	}
+}
+
+func TestOrderBookWaitForContext(t *testing.T) {
+	ob := NewOrderBook()
+	ob.Apply(orderUpdate("1", 1, OrderStatusOrdered, 0))
+
+	// An order already past the state returns at once, even with a done
+	// context.
+	done, cancel := context.WithCancel(context.Background())
+	cancel()
+	if order, err := ob.WaitFor(done, "1", OrderPending); err != nil || order.State != OrderOpen {
+		t.Errorf("WaitFor(Pending) = %s, %v; want the open order", order.State, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if order, err := ob.WaitFor(ctx, "1", OrderFilled); !errors.Is(err, context.DeadlineExceeded) || order.State != OrderOpen {
+		t.Errorf("WaitFor(Filled) = %s, %v; want the open order and a deadline error", order.State, err)
+	}
+	if _, err := ob.WaitFor(ctx, "missing", OrderOpen); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitFor on an unknown order: error = %v, want a deadline error", err)
+	}
+}
+
+func TestEnableOrderBook(t *testing.T) {
+	b := NewBreezeInstance("key")
+	book := b.EnableOrderBook()
+	b.typedTickHandlers.dispatch(&Tick{Kind: TickOrder, Order: *orderUpdate("1", 1, OrderStatusOrdered, 0)})
+	b.typedTickHandlers.dispatch(quoteTick("4.1!2885", 2500))
+	b.typedTickHandlers.dispatch(&Tick{Kind: TickOrder, Order: *orderUpdate("2", 1, OrderStatusRequested, 0)})
+
+	orders := book.List()
+	if len(orders) != 2 || orders[0].OrderID != "1" || orders[1].OrderID != "2" {
+		t.Fatalf("List = %+v, want orders 1 and 2 in arrival order", orders)
+	}
+
+	book.Stop()
+	book.Stop()
+	b.typedTickHandlers.dispatch(&Tick{Kind: TickOrder, Order: *orderUpdate("1", 2, OrderStatusExecuted, 10)})
+	if order, _ := book.Get("1"); order.State != OrderOpen {
+		t.Errorf("stopped book applied an update: %s", order.State)
+	}
+	if b.typedTickHandlers.active() {
+		t.Error("Stop left the handler registered")
+	}
+}
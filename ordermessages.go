@@ -0,0 +1,213 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+)
+
+// orderField places one field of an order notification: the key parseData
+// emits, its position in the frame and, for coded fields, the Tux table
+// translating it.
+type orderField struct {
+	key   string
+	index int
+	tux   string
+}
+
+type orderSchema []orderField
+
+// orderSchemas holds the order notification layouts by frame length. The
+// 42-field frame is sent for equity orders and the 43-field frame for F&O
+// orders, which carries the contract (option type, exercise type, strike
+// and expiry) and the validity date after the product type and moves the
+// coded fields behind them. Position 39 of the equity frame is unused.
+// Every index is inside its frame, so a frame of a listed length can never
+// be read past its end.
+var orderSchemas = map[int]orderSchema{
+	42: {
+		{key: "sourceNumber", index: 0},
+		{key: "group", index: 1},
+		{key: "userId", index: 2},
+		{key: "key", index: 3},
+		{key: "messageLength", index: 4},
+		{key: "requestType", index: 5},
+		{key: "messageSequence", index: 6},
+		{key: "messageDate", index: 7},
+		{key: "messageTime", index: 8},
+		{key: "messageCategory", index: 9},
+		{key: "messagePriority", index: 10},
+		{key: "messageType", index: 11},
+		{key: "orderMatchAccount", index: 12},
+		{key: "orderExchangeCode", index: 13},
+		{key: "stockCode", index: 14},
+		{key: "orderFlow", index: 15, tux: "orderFlow"},
+		{key: "limitMarketFlag", index: 16, tux: "limitMarketFlag"},
+		{key: "orderType", index: 17, tux: "orderType"},
+		{key: "orderLimitRate", index: 18},
+		{key: "productType", index: 19, tux: "productType"},
+		{key: "orderStatus", index: 20, tux: "orderStatus"},
+		{key: "orderDate", index: 21},
+		{key: "orderTradeDate", index: 22},
+		{key: "orderReference", index: 23},
+		{key: "orderQuantity", index: 24},
+		{key: "openQuantity", index: 25},
+		{key: "orderExecutedQuantity", index: 26},
+		{key: "cancelledQuantity", index: 27},
+		{key: "expiredQuantity", index: 28},
+		{key: "orderDisclosedQuantity", index: 29},
+		{key: "orderStopLossTrigger", index: 30},
+		{key: "orderSquareFlag", index: 31},
+		{key: "orderAmountBlocked", index: 32},
+		{key: "orderPipeId", index: 33},
+		{key: "channel", index: 34},
+		{key: "exchangeSegmentCode", index: 35},
+		{key: "exchangeSegmentSettlement", index: 36},
+		{key: "segmentDescription", index: 37},
+		{key: "marginSquareOffMode", index: 38},
+		{key: "orderValidDate", index: 40},
+		{key: "orderMessageCharacter", index: 41},
+	},
+	43: {
+		{key: "sourceNumber", index: 0},
+		{key: "group", index: 1},
+		{key: "userId", index: 2},
+		{key: "key", index: 3},
+		{key: "messageLength", index: 4},
+		{key: "requestType", index: 5},
+		{key: "messageSequence", index: 6},
+		{key: "messageDate", index: 7},
+		{key: "messageTime", index: 8},
+		{key: "messageCategory", index: 9},
+		{key: "messagePriority", index: 10},
+		{key: "messageType", index: 11},
+		{key: "orderMatchAccount", index: 12},
+		{key: "orderExchangeCode", index: 13},
+		{key: "stockCode", index: 14},
+		{key: "productType", index: 15, tux: "productType"},
+		{key: "optionType", index: 16, tux: "optionType"},
+		{key: "exerciseType", index: 17},
+		{key: "strikePrice", index: 18},
+		{key: "expiryDate", index: 19},
+		{key: "orderValidDate", index: 20},
+		{key: "orderFlow", index: 21, tux: "orderFlow"},
+		{key: "limitMarketFlag", index: 22, tux: "limitMarketFlag"},
+		{key: "orderType", index: 23, tux: "orderType"},
+		{key: "orderLimitRate", index: 24},
+		{key: "orderStatus", index: 25, tux: "orderStatus"},
+		{key: "orderReference", index: 26},
+		{key: "orderTotalQuantity", index: 27},
+		{key: "executedQuantity", index: 28},
+		{key: "cancelledQuantity", index: 29},
+		{key: "expiredQuantity", index: 30},
+		{key: "stopLossTrigger", index: 31},
+		{key: "specialFlag", index: 32},
+		{key: "pipeId", index: 33},
+		{key: "channel", index: 34},
+		{key: "modificationOrCancelFlag", index: 35},
+		{key: "tradeDate", index: 36},
+		{key: "acknowledgeNumber", index: 37},
+		{key: "stopLossOrderReference", index: 37},
+		{key: "totalAmountBlocked", index: 38},
+		{key: "averageExecutedRate", index: 39},
+		{key: "cancelFlag", index: 40},
+		{key: "squareOffMarket", index: 41},
+		{key: "quickExitFlag", index: 42},
+	},
+}
+
+// orderFrameLengths lists the lengths of orderSchemas in ascending order.
+var orderFrameLengths = func() []int {
+	lengths := make([]int, 0, len(orderSchemas))
+	for n := range orderSchemas {
+		lengths = append(lengths, n)
+	}
+	sort.Ints(lengths)
+	return lengths
+}()
+
+// orderSchemaFor returns the schema for an order frame of n fields and the
+// frame length it was written for. A frame whose length matches no schema
+// is assumed to come from a newer feed version that appended fields, so it
+// is read through the longest schema shorter than it; exact reports whether
+// n matched. A frame shorter than every schema has none.
+func orderSchemaFor(n int) (schema orderSchema, length int, exact bool) {
+	if s, ok := orderSchemas[n]; ok {
+		return s, n, true
+	}
+	for i := len(orderFrameLengths) - 1; i >= 0; i-- {
+		if orderFrameLengths[i] < n {
+			length = orderFrameLengths[i]
+			return orderSchemas[length], length, false
+		}
+	}
+	return nil, 0, false
+}
+
+// unknownOrderLengths records the frame lengths seen that match no schema.
+var unknownOrderLengths sync.Map
+
+// noteUnknownOrderFrame logs the first frame of each unknown length, with
+// the number of leading fields decoded from it.
+func noteUnknownOrderFrame(n, decoded int) {
+	if _, seen := unknownOrderLengths.LoadOrStore(n, struct{}{}); seen {
+		return
+	}
+	if decoded > 0 {
+		log.Printf("order notification with %d fields matches no schema, decoding its first %d", n, decoded)
+	} else {
+		log.Printf("dropping notification with %d fields, it matches no schema", n)
+	}
+}
+
+// UnknownOrderFrameLengths returns, sorted, the lengths of the notification
+// frames seen that match no order schema. A non-empty result means the feed
+// format has changed and orderSchemas needs a new layout.
+func UnknownOrderFrameLengths() []int {
+	lengths := []int{}
+	unknownOrderLengths.Range(func(key, _ interface{}) bool {
+		lengths = append(lengths, key.(int))
+		return true
+	})
+	sort.Ints(lengths)
+	return lengths
+}
+
+// index returns the frame position of the first of keys the schema
+// carries, or -1.
+func (s orderSchema) index(keys ...string) int {
+	for _, key := range keys {
+		for _, f := range s {
+			if f.key == key {
+				return f.index
+			}
+		}
+	}
+	return -1
+}
+
+// parseOrderData decodes an order notification through its schema. A frame
+// longer than its schema, from a feed version this client predates, has its
+// known fields decoded and the rest kept under "extraFields"; a frame too
+// short for any schema returns nil. Coded fields the Tux tables lack are
+// passed through as received.
+func (b *BreezeInstance) parseOrderData(data []interface{}) map[string]interface{} {
+	schema, length, exact := orderSchemaFor(len(data))
+	if schema == nil {
+		noteUnknownOrderFrame(len(data), 0)
+		return nil
+	}
+	order := make(map[string]interface{}, len(schema)+1)
+	if !exact {
+		noteUnknownOrderFrame(len(data), length)
+		order["extraFields"] = data[length:]
+	}
+	for _, f := range schema {
+		if f.tux != "" {
+			order[f.key] = b.tuxValue(f.tux, data[f.index])
+		} else {
+			order[f.key] = data[f.index]
+		}
+	}
+	return order
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// checkGolden compares got, encoded as indented JSON, with the golden file
+// at path, rewriting the file instead when -update is set.
+func checkGolden(t *testing.T, path string, got interface{}) {
+	t.Helper()
+	encoded, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded = append(encoded, '\n')
+	if *update {
+		if err := os.WriteFile(path, encoded, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(encoded, want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", path, encoded, want)
+	}
+}
+
+// TestOrderFramesGolden decodes each sample frame in testdata/orders
+// through both parseData and TickDecoder and compares the results with
+// the .parsed.golden and .typed.golden files beside it.
+func TestOrderFramesGolden(t *testing.T) {
+	frames, err := filepath.Glob(filepath.Join("testdata", "orders", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) == 0 {
+		t.Fatal("no sample frames in testdata/orders")
+	}
+	for _, path := range frames {
+		name := strings.TrimSuffix(path, ".json")
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields []interface{}
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				t.Fatal(err)
+			}
+
+			parsed := NewBreezeInstance("").parseData(fields)
+			if parsed == nil {
+				t.Fatal("parseData returned nil")
+			}
+			checkGolden(t, name+".parsed.golden", parsed)
+
+			var tick Tick
+			kind, err := NewTickDecoder().Decode(raw, &tick)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kind != TickOrder {
+				t.Fatalf("Decode kind = %v, want %v", kind, TickOrder)
+			}
+			checkGolden(t, name+".typed.golden", tick.Order)
+		})
+	}
+}
+
+func TestOrderFrameUnknownLength(t *testing.T) {
+	frame := make([]interface{}, 30)
+	for i := range frame {
+		frame[i] = "x"
+	}
+	raw, err := json.Marshal(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed := NewBreezeInstance("").parseData(frame); parsed != nil {
+		t.Errorf("parseData = %v, want nil", parsed)
+	}
+	var tick Tick
+	kind, err := NewTickDecoder().Decode(raw, &tick)
+	if err != nil || kind != TickUnknown {
+		t.Errorf("Decode = %v, %v; want %v, nil", kind, err, TickUnknown)
+	}
+
+	known := map[int]bool{}
+	for _, n := range UnknownOrderFrameLengths() {
+		known[n] = true
+	}
+	if !known[30] {
+		t.Errorf("UnknownOrderFrameLengths() = %v, want it to include 30", UnknownOrderFrameLengths())
+	}
+}
+
+func TestOrderSchemasInsideFrame(t *testing.T) {
+	for n, schema := range orderSchemas {
+		if n > maxOrderFields {
+			t.Errorf("schema %d is longer than maxOrderFields", n)
+		}
+		for _, f := range schema {
+			if f.index < 0 || f.index >= n {
+				t.Errorf("schema %d: %s at %d is outside the frame", n, f.key, f.index)
+			}
+		}
+	}
+}
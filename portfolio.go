@@ -0,0 +1,280 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type PortfolioHolding struct {
+	StockCode          string `json:"stock_code"`
+	ExchangeCode       string `json:"exchange_code"`
+	Quantity           Int    `json:"quantity"`
+	AveragePrice       Float  `json:"average_price"`
+	BookedProfitLoss   Float  `json:"booked_profit_loss"`
+	CurrentMarketPrice Float  `json:"current_market_price"`
+	ChangePercentage   Float  `json:"change_percentage"`
+	AnswerFlag         string `json:"answer_flag"`
+	ProductType        string `json:"product_type"`
+	ExpiryDate         string `json:"expiry_date"`
+	StrikePrice        Float  `json:"strike_price"`
+	Right              string `json:"right"`
+	Action             string `json:"action"`
+	RealizedProfit     Float  `json:"realized_profit"`
+	UnrealizedProfit   Float  `json:"unrealized_profit"`
+	OpenPositionValue  Float  `json:"open_position_value"`
+	PortfolioCharges   Float  `json:"portfolio_charges"`
+
+	// Computed from the fields above by computePnL.
+	UnrealisedPnL float64 `json:"-"`
+	RealisedPnL   float64 `json:"-"`
+	DayChange     float64 `json:"-"`
+}
+
+// computePnL derives P&L from the market price. The previous close is
+// recovered from change_percentage, which Breeze reports against it.
+func (h *PortfolioHolding) computePnL() {
+	qty := float64(h.Quantity)
+	cmp := float64(h.CurrentMarketPrice)
+	h.UnrealisedPnL = (cmp - float64(h.AveragePrice)) * qty
+	h.RealisedPnL = float64(h.BookedProfitLoss)
+	h.DayChange = 0
+	if pct := float64(h.ChangePercentage); pct != -100 {
+		prevClose := cmp / (1 + pct/100)
+		h.DayChange = (cmp - prevClose) * qty
+	}
+}
+
+type PortfolioPosition struct {
+	Segment                    string `json:"segment"`
+	ProductType                string `json:"product_type"`
+	ExchangeCode               string `json:"exchange_code"`
+	StockCode                  string `json:"stock_code"`
+	ExpiryDate                 string `json:"expiry_date"`
+	StrikePrice                string `json:"strike_price"`
+	Right                      string `json:"right"`
+	Action                     string `json:"action"`
+	Quantity                   Int    `json:"quantity"`
+	AveragePrice               Float  `json:"average_price"`
+	SettlementID               string `json:"settlement_id"`
+	MarginAmount               Float  `json:"margin_amount"`
+	LTP                        Float  `json:"ltp"`
+	Price                      Float  `json:"price"`
+	StockIndexIndicator        string `json:"stock_index_indicator"`
+	CoverQuantity              Int    `json:"cover_quantity"`
+	StoplossTrigger            Float  `json:"stoploss_trigger"`
+	Stoploss                   Float  `json:"stoploss"`
+	TakeProfit                 Float  `json:"take_profit"`
+	AvailableMargin            Float  `json:"available_margin"`
+	SquareoffMode              string `json:"squareoff_mode"`
+	OrderID                    string `json:"order_id"`
+	CoverOrderFlow             string `json:"cover_order_flow"`
+	CoverOrderExecutedQuantity Int    `json:"cover_order_executed_quantity"`
+	PledgeStatus               string `json:"pledge_status"`
+	PnL                        Float  `json:"pnl"`
+	BookedProfitLoss           Float  `json:"booked_profit_loss"`
+	Underlying                 string `json:"underlying"`
+	OrderSegmentCode           string `json:"order_segment_code"`
+
+	// PreviousClose is filled from live ticks; the REST payload has none.
+	PreviousClose float64 `json:"-"`
+
+	// Computed from the fields above by computePnL.
+	UnrealisedPnL float64 `json:"-"`
+	RealisedPnL   float64 `json:"-"`
+	DayChange     float64 `json:"-"`
+}
+
+// NetQuantity is the open quantity, negative for a short position.
+func (p *PortfolioPosition) NetQuantity() float64 {
+	if strings.EqualFold(p.Action, "sell") {
+		return -float64(p.Quantity)
+	}
+	return float64(p.Quantity)
+}
+
+func (p *PortfolioPosition) computePnL() {
+	qty := p.NetQuantity()
+	ltp := float64(p.LTP)
+	p.UnrealisedPnL = (ltp - float64(p.AveragePrice)) * qty
+	p.RealisedPnL = float64(p.BookedProfitLoss)
+	p.DayChange = 0
+	if p.PreviousClose != 0 {
+		p.DayChange = (ltp - p.PreviousClose) * qty
+	}
+}
+
+func (a *ApificationBreeze) GetPortfolioHoldings(exchangeCode, fromDate, toDate, stockCode, portfolioType string) ([]PortfolioHolding, error) {
+	if exchangeCode == "" {
+		return nil, NewValidationError("Exchange code cannot be empty")
+	}
+
+	body := map[string]string{
+		"exchange_code":  exchangeCode,
+		"from_date":      fromDate,
+		"to_date":        toDate,
+		"stock_code":     stockCode,
+		"portfolio_type": portfolioType,
+	}
+	holdings, err := callAPI[[]PortfolioHolding](a, "GET", "/"+string(PORTFOLIO_HOLDING), body)
+	if err != nil {
+		return nil, err
+	}
+	for i := range holdings {
+		holdings[i].computePnL()
+	}
+	return holdings, nil
+}
+
+func (a *ApificationBreeze) GetPortfolioPositions() ([]PortfolioPosition, error) {
+	positions, err := callAPI[[]PortfolioPosition](a, "GET", "/"+string(PORTFOLIO_POSITION), map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range positions {
+		positions[i].computePnL()
+	}
+	return positions, nil
+}
+
+// LivePositions keeps the open positions' LTP and P&L current from the
+// ticks delivered to BreezeInstance.OnTicks.
+type LivePositions struct {
+	breeze     *BreezeInstance
+	mu         sync.RWMutex
+	positions  []PortfolioPosition
+	tokens     []string
+	subscribed []string
+	byToken    map[string][]int
+	detach     func()
+	OnUpdate   func(PortfolioPosition)
+}
+
+// TrackPositions loads the open positions and hooks OnTicks so their P&L
+// follows the live LTP. Any OnTicks callback already set keeps receiving
+// every tick. Call Subscribe to request the feeds for the positions.
+func (b *BreezeInstance) TrackPositions() (*LivePositions, error) {
+	if b.APIHandler == nil {
+		return nil, &NotConnectedError{Message: "API session has not been generated"}
+	}
+	positions, err := b.APIHandler.GetPortfolioPositions()
+	if err != nil {
+		return nil, err
+	}
+
+	lp := &LivePositions{
+		breeze:    b,
+		positions: positions,
+		byToken:   map[string][]int{},
+	}
+	for i := range positions {
+		token, _, err := b.positionToken(&positions[i])
+		if err != nil {
+			continue
+		}
+		if _, ok := lp.byToken[token]; !ok {
+			lp.tokens = append(lp.tokens, token)
+		}
+		lp.byToken[token] = append(lp.byToken[token], i)
+	}
+
+	lp.detach = b.addTickHandler(lp.apply)
+	return lp, nil
+}
+
+func (b *BreezeInstance) positionToken(p *PortfolioPosition) (string, string, error) {
+	return b.getStockTokenValue(strings.ToUpper(p.ExchangeCode), p.StockCode, strings.ToLower(p.ProductType), p.ExpiryDate, p.StrikePrice, strings.ToLower(p.Right), true, false)
+}
+
+// Subscribe requests the quote feed of every tracked position.
+func (lp *LivePositions) Subscribe() error {
+	for _, token := range lp.tokens {
+		if _, err := lp.breeze.SubscribeFeeds(token, "", "", "", "", "", "", "", true, false, false); err != nil {
+			return err
+		}
+		lp.mu.Lock()
+		lp.subscribed = append(lp.subscribed, token)
+		lp.mu.Unlock()
+	}
+	return nil
+}
+
+// Stop detaches the positions from the tick stream and releases the feeds
+// Subscribe requested. The last P&L stays readable.
+func (lp *LivePositions) Stop() {
+	lp.detach()
+	lp.mu.Lock()
+	tokens := lp.subscribed
+	lp.subscribed = nil
+	lp.mu.Unlock()
+	for _, token := range tokens {
+		lp.breeze.UnsubscribeFeeds(token, "", "", "", "", "", "", "", true, false, false)
+	}
+}
+
+func (lp *LivePositions) apply(tick map[string]interface{}) {
+	symbol, ok := tick["symbol"].(string)
+	if !ok {
+		return
+	}
+	ltp, ok := tickFloat(tick["last"])
+	if !ok {
+		return
+	}
+	prevClose, hasClose := tickFloat(tick["close"])
+
+	lp.mu.Lock()
+	indices := lp.byToken[symbol]
+	updated := make([]PortfolioPosition, 0, len(indices))
+	for _, i := range indices {
+		p := &lp.positions[i]
+		p.LTP = Float(ltp)
+		if hasClose {
+			p.PreviousClose = prevClose
+		}
+		p.computePnL()
+		updated = append(updated, *p)
+	}
+	lp.mu.Unlock()
+
+	if lp.OnUpdate != nil {
+		for _, p := range updated {
+			lp.OnUpdate(p)
+		}
+	}
+}
+
+// Positions returns a snapshot of the tracked positions.
+func (lp *LivePositions) Positions() []PortfolioPosition {
+	lp.mu.RLock()
+	defer lp.mu.RUnlock()
+	return append([]PortfolioPosition(nil), lp.positions...)
+}
+
+// TotalPnL sums realised and unrealised P&L over all tracked positions.
+func (lp *LivePositions) TotalPnL() (realised, unrealised float64) {
+	lp.mu.RLock()
+	defer lp.mu.RUnlock()
+	for _, p := range lp.positions {
+		realised += p.RealisedPnL
+		unrealised += p.UnrealisedPnL
+	}
+	return realised, unrealised
+}
+
+// tickFloat reads a tick field that may arrive as a JSON number or a
+// numeric string.
+func tickFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
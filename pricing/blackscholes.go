@@ -0,0 +1,96 @@
+// Package pricing implements Black-Scholes-Merton valuation, Greeks and
+// implied volatility for European options.
+//
+// Inputs use annualised units: t is the time to expiry in years, r the
+// continuously compounded risk-free rate, q the continuous dividend yield
+// and sigma the volatility. Theta is per year, vega per 1.00 of volatility
+// and rho per 1.00 of rate; divide by 365 or 100 for per-day or per-point
+// figures.
+package pricing
+
+import (
+	"math"
+)
+
+type Right int
+
+const (
+	Call Right = iota
+	Put
+)
+
+func (r Right) String() string {
+	if r == Put {
+		return "put"
+	}
+	return "call"
+}
+
+type Greeks struct {
+	Delta float64
+	Gamma float64
+	Theta float64
+	Vega  float64
+	Rho   float64
+}
+
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func normPDF(x float64) float64 {
+	return math.Exp(-0.5*x*x) / math.Sqrt(2*math.Pi)
+}
+
+func d1d2(spot, strike, t, r, q, sigma float64) (float64, float64) {
+	sqrtT := math.Sqrt(t)
+	d1 := (math.Log(spot/strike) + (r-q+0.5*sigma*sigma)*t) / (sigma * sqrtT)
+	return d1, d1 - sigma*sqrtT
+}
+
+// Price returns the Black-Scholes-Merton value of the option. At or past
+// expiry, or with zero volatility, it returns the discounted intrinsic value.
+func Price(right Right, spot, strike, t, r, q, sigma float64) float64 {
+	if t <= 0 || sigma <= 0 {
+		forward := spot * math.Exp((r-q)*math.Max(t, 0))
+		discount := math.Exp(-r * math.Max(t, 0))
+		if right == Put {
+			return discount * math.Max(strike-forward, 0)
+		}
+		return discount * math.Max(forward-strike, 0)
+	}
+	d1, d2 := d1d2(spot, strike, t, r, q, sigma)
+	if right == Put {
+		return strike*math.Exp(-r*t)*normCDF(-d2) - spot*math.Exp(-q*t)*normCDF(-d1)
+	}
+	return spot*math.Exp(-q*t)*normCDF(d1) - strike*math.Exp(-r*t)*normCDF(d2)
+}
+
+// ComputeGreeks returns the first-order Greeks and gamma. It returns the
+// zero value when t or sigma is not positive.
+func ComputeGreeks(right Right, spot, strike, t, r, q, sigma float64) Greeks {
+	if t <= 0 || sigma <= 0 || spot <= 0 || strike <= 0 {
+		return Greeks{}
+	}
+	sqrtT := math.Sqrt(t)
+	d1, d2 := d1d2(spot, strike, t, r, q, sigma)
+	dq := math.Exp(-q * t)
+	dr := math.Exp(-r * t)
+	pdf := normPDF(d1)
+
+	g := Greeks{
+		Gamma: dq * pdf / (spot * sigma * sqrtT),
+		Vega:  spot * dq * pdf * sqrtT,
+	}
+	decay := -spot * dq * pdf * sigma / (2 * sqrtT)
+	if right == Put {
+		g.Delta = dq * (normCDF(d1) - 1)
+		g.Theta = decay + r*strike*dr*normCDF(-d2) - q*spot*dq*normCDF(-d1)
+		g.Rho = -strike * t * dr * normCDF(-d2)
+	} else {
+		g.Delta = dq * normCDF(d1)
+		g.Theta = decay - r*strike*dr*normCDF(d2) + q*spot*dq*normCDF(d1)
+		g.Rho = strike * t * dr * normCDF(d2)
+	}
+	return g
+}
@@ -0,0 +1,71 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+)
+
+// Reference values are the worked examples in Hull, Options, Futures and
+// Other Derivatives, quoted to the precision the book prints them.
+func TestPrice(t *testing.T) {
+	tests := []struct {
+		name                  string
+		right                 Right
+		spot, strike, t, r, q float64
+		sigma                 float64
+		want, tol             float64
+	}{
+		{"hull 15.6 call", Call, 42, 40, 0.5, 0.10, 0, 0.2, 4.76, 0.005},
+		{"hull 15.6 put", Put, 42, 40, 0.5, 0.10, 0, 0.2, 0.81, 0.005},
+		{"hull 17.1 index call", Call, 930, 900, 2.0 / 12, 0.08, 0.03, 0.2, 51.83, 0.005},
+		{"hull 19.1 call", Call, 49, 50, 20.0 / 52, 0.05, 0, 0.2, 2.40, 0.005},
+		{"expired call", Call, 110, 100, 0, 0.05, 0, 0.2, 10, 1e-12},
+		{"expired put", Put, 110, 100, 0, 0.05, 0, 0.2, 0, 1e-12},
+	}
+	for _, tt := range tests {
+		got := Price(tt.right, tt.spot, tt.strike, tt.t, tt.r, tt.q, tt.sigma)
+		if math.Abs(got-tt.want) > tt.tol {
+			t.Errorf("%s: Price = %.6f, want %.4f ± %g", tt.name, got, tt.want, tt.tol)
+		}
+	}
+}
+
+func TestPutCallParity(t *testing.T) {
+	spot, strike, tt, r, q, sigma := 930.0, 900.0, 2.0/12, 0.08, 0.03, 0.2
+	call := Price(Call, spot, strike, tt, r, q, sigma)
+	put := Price(Put, spot, strike, tt, r, q, sigma)
+	want := spot*math.Exp(-q*tt) - strike*math.Exp(-r*tt)
+	if math.Abs(call-put-want) > 1e-9 {
+		t.Errorf("call - put = %.10f, want %.10f", call-put, want)
+	}
+}
+
+// TestComputeGreeks checks Hull's example 19.1: a call on a non-dividend
+// stock with S=49, K=50, r=5%, sigma=20% and 20 weeks to expiry.
+func TestComputeGreeks(t *testing.T) {
+	call := ComputeGreeks(Call, 49, 50, 20.0/52, 0.05, 0, 0.2)
+	put := ComputeGreeks(Put, 49, 50, 20.0/52, 0.05, 0, 0.2)
+	tests := []struct {
+		name      string
+		got, want float64
+		tol       float64
+	}{
+		{"call delta", call.Delta, 0.522, 0.0005},
+		{"put delta", put.Delta, -0.478, 0.0005},
+		{"gamma", call.Gamma, 0.066, 0.0005},
+		{"put gamma", put.Gamma, 0.066, 0.0005},
+		{"call theta", call.Theta, -4.31, 0.005},
+		{"vega", call.Vega, 12.1, 0.05},
+		{"put vega", put.Vega, 12.1, 0.05},
+		{"call rho", call.Rho, 8.91, 0.005},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > tt.tol {
+			t.Errorf("%s = %.5f, want %.3f ± %g", tt.name, tt.got, tt.want, tt.tol)
+		}
+	}
+
+	if g := ComputeGreeks(Call, 49, 50, 0, 0.05, 0, 0.2); g != (Greeks{}) {
+		t.Errorf("greeks at expiry = %+v, want zero", g)
+	}
+}
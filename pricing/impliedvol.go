@@ -0,0 +1,117 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	ErrPriceOutOfBounds = errors.New("pricing: option price outside no-arbitrage bounds")
+	ErrNoConvergence    = errors.New("pricing: implied volatility did not converge")
+)
+
+const (
+	minVol       = 1e-6
+	maxVol       = 5.0
+	ivTolerance  = 1e-8
+	maxIteration = 100
+)
+
+// ImpliedVolatility solves for the volatility that reproduces price. It
+// starts with Newton-Raphson on vega and falls back to Brent's method on
+// [1e-6, 5] when Newton leaves the bracket or vega vanishes.
+func ImpliedVolatility(right Right, price, spot, strike, t, r, q float64) (float64, error) {
+	if t <= 0 || spot <= 0 || strike <= 0 || price <= 0 {
+		return 0, ErrPriceOutOfBounds
+	}
+	lower := Price(right, spot, strike, t, r, q, minVol)
+	upper := Price(right, spot, strike, t, r, q, maxVol)
+	if price < lower-ivTolerance || price > upper+ivTolerance {
+		return 0, ErrPriceOutOfBounds
+	}
+
+	objective := func(sigma float64) float64 {
+		return Price(right, spot, strike, t, r, q, sigma) - price
+	}
+
+	// Brenner-Subrahmanyam seed, which is close for near-the-money options.
+	sigma := math.Sqrt(2*math.Pi/t) * price / spot
+	if sigma < 0.05 || sigma > 3 || math.IsNaN(sigma) {
+		sigma = 0.3
+	}
+	for i := 0; i < maxIteration; i++ {
+		diff := objective(sigma)
+		if math.Abs(diff) < ivTolerance {
+			return sigma, nil
+		}
+		vega := ComputeGreeks(right, spot, strike, t, r, q, sigma).Vega
+		if vega < 1e-10 {
+			break
+		}
+		next := sigma - diff/vega
+		if next <= minVol || next >= maxVol || math.IsNaN(next) {
+			break
+		}
+		if math.Abs(next-sigma) < 1e-12 {
+			return next, nil
+		}
+		sigma = next
+	}
+	return brent(objective, minVol, maxVol)
+}
+
+// brent finds a root of f in [a, b], which must bracket a sign change.
+func brent(f func(float64) float64, a, b float64) (float64, error) {
+	fa, fb := f(a), f(b)
+	if fa == 0 {
+		return a, nil
+	}
+	if fb == 0 {
+		return b, nil
+	}
+	if fa*fb > 0 {
+		return 0, ErrPriceOutOfBounds
+	}
+	if math.Abs(fa) < math.Abs(fb) {
+		a, b, fa, fb = b, a, fb, fa
+	}
+	c, fc := a, fa
+	d := b - a
+	bisected := true
+	for i := 0; i < maxIteration; i++ {
+		if math.Abs(fb) < ivTolerance || math.Abs(b-a) < ivTolerance {
+			return b, nil
+		}
+		var s float64
+		if fa != fc && fb != fc {
+			s = a*fb*fc/((fa-fb)*(fa-fc)) + b*fa*fc/((fb-fa)*(fb-fc)) + c*fa*fb/((fc-fa)*(fc-fb))
+		} else {
+			s = b - fb*(b-a)/(fb-fa)
+		}
+		lo, hi := (3*a+b)/4, b
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if s < lo || s > hi ||
+			(bisected && math.Abs(s-b) >= math.Abs(b-c)/2) ||
+			(!bisected && math.Abs(s-b) >= math.Abs(c-d)/2) ||
+			(bisected && math.Abs(b-c) < ivTolerance) ||
+			(!bisected && math.Abs(c-d) < ivTolerance) {
+			s = (a + b) / 2
+			bisected = true
+		} else {
+			bisected = false
+		}
+		fs := f(s)
+		d, c, fc = c, b, fb
+		if fa*fs < 0 {
+			b, fb = s, fs
+		} else {
+			a, fa = s, fs
+		}
+		if math.Abs(fa) < math.Abs(fb) {
+			a, b, fa, fb = b, a, fb, fa
+		}
+	}
+	return b, ErrNoConvergence
+}
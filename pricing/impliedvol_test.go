@@ -0,0 +1,69 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestImpliedVolatilityRoundTrip(t *testing.T) {
+	tests := []struct {
+		name                  string
+		right                 Right
+		spot, strike, t, r, q float64
+		sigma                 float64
+	}{
+		{"atm call", Call, 100, 100, 0.25, 0.07, 0, 0.18},
+		{"itm put", Put, 42, 45, 0.5, 0.10, 0, 0.2},
+		{"index call with yield", Call, 930, 900, 2.0 / 12, 0.08, 0.03, 0.2},
+		{"weekly otm call", Call, 19500, 19900, 3.0 / 365, 0.07, 0, 0.12},
+		{"high vol put", Put, 100, 80, 1, 0.05, 0, 1.5},
+		{"deep otm call", Call, 100, 200, 0.1, 0.05, 0, 0.9},
+	}
+	for _, tt := range tests {
+		price := Price(tt.right, tt.spot, tt.strike, tt.t, tt.r, tt.q, tt.sigma)
+		objective := func(sigma float64) float64 {
+			return Price(tt.right, tt.spot, tt.strike, tt.t, tt.r, tt.q, sigma) - price
+		}
+
+		iv, err := ImpliedVolatility(tt.right, price, tt.spot, tt.strike, tt.t, tt.r, tt.q)
+		if err != nil || math.Abs(iv-tt.sigma) > 1e-5 {
+			t.Errorf("%s: ImpliedVolatility = %.8f, %v; want %.4f", tt.name, iv, err, tt.sigma)
+		}
+		// The Brent fallback must reach the same root on its own.
+		iv, err = brent(objective, minVol, maxVol)
+		if err != nil || math.Abs(iv-tt.sigma) > 1e-5 {
+			t.Errorf("%s: brent = %.8f, %v; want %.4f", tt.name, iv, err, tt.sigma)
+		}
+	}
+}
+
+func TestImpliedVolatilityOutOfBounds(t *testing.T) {
+	tests := []struct {
+		name                string
+		right               Right
+		price, spot, strike float64
+		t, r, q             float64
+	}{
+		{"below intrinsic", Call, 5, 110, 100, 0.5, 0.05, 0},
+		{"above spot", Call, 120, 110, 100, 0.5, 0.05, 0},
+		{"zero price", Put, 0, 100, 100, 0.5, 0.05, 0},
+		{"expired", Put, 2, 100, 100, 0, 0.05, 0},
+	}
+	for _, tt := range tests {
+		_, err := ImpliedVolatility(tt.right, tt.price, tt.spot, tt.strike, tt.t, tt.r, tt.q)
+		if !errors.Is(err, ErrPriceOutOfBounds) {
+			t.Errorf("%s: error = %v, want ErrPriceOutOfBounds", tt.name, err)
+		}
+	}
+}
+
+func TestBrentNeedsBracket(t *testing.T) {
+	if _, err := brent(func(x float64) float64 { return x*x + 1 }, -1, 1); !errors.Is(err, ErrPriceOutOfBounds) {
+		t.Errorf("brent without a sign change = %v, want ErrPriceOutOfBounds", err)
+	}
+	root, err := brent(func(x float64) float64 { return x*x - 2 }, 0, 2)
+	if err != nil || math.Abs(root-math.Sqrt2) > 1e-8 {
+		t.Errorf("brent(x²-2) = %v, %v; want √2", root, err)
+	}
+}
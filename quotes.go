@@ -0,0 +1,95 @@
+package main
+
+import "strings"
+
+// InstrumentSpec identifies a contract by exchange, stock code and, for
+// derivatives, product, expiry, strike and right. GetQuotes and
+// SubscribeInstrument both take it, so a REST snapshot and a live feed are
+// requested for the same contract the same way.
+type InstrumentSpec struct {
+	ExchangeCode string
+	StockCode    string
+	ProductType  string
+	ExpiryDate   string
+	StrikePrice  string
+	Right        string
+}
+
+func (s InstrumentSpec) validate() error {
+	if s.ExchangeCode == "" || s.StockCode == "" {
+		return &ValidationError{Message: "Exchange code or stock code cannot be empty"}
+	}
+	switch strings.ToLower(s.ExchangeCode) {
+	case "nfo", "bfo", "ndx", "mcx":
+		if s.ExpiryDate == "" {
+			return &ValidationError{Message: "Expiry date cannot be empty for " + s.ExchangeCode}
+		}
+		if strings.EqualFold(s.ProductType, "options") {
+			right := strings.ToLower(s.Right)
+			if right != "call" && right != "put" {
+				return &ValidationError{Message: "Right should be call or put for options"}
+			}
+			if s.StrikePrice == "" {
+				return &ValidationError{Message: "Strike price cannot be empty for options"}
+			}
+		}
+	}
+	return nil
+}
+
+type MarketQuote struct {
+	ExchangeCode        string `json:"exchange_code"`
+	ProductType         string `json:"product_type"`
+	StockCode           string `json:"stock_code"`
+	ExpiryDate          string `json:"expiry_date"`
+	Right               string `json:"right"`
+	StrikePrice         Float  `json:"strike_price"`
+	LTP                 Float  `json:"ltp"`
+	LTT                 string `json:"ltt"`
+	BestBidPrice        Float  `json:"best_bid_price"`
+	BestBidQuantity     Int    `json:"best_bid_quantity"`
+	BestOfferPrice      Float  `json:"best_offer_price"`
+	BestOfferQuantity   Int    `json:"best_offer_quantity"`
+	Open                Float  `json:"open"`
+	High                Float  `json:"high"`
+	Low                 Float  `json:"low"`
+	PreviousClose       Float  `json:"previous_close"`
+	LTPPercentChange    Float  `json:"ltp_percent_change"`
+	UpperCircuit        Float  `json:"upper_circuit"`
+	LowerCircuit        Float  `json:"lower_circuit"`
+	TotalQuantityTraded Int    `json:"total_quantity_traded"`
+	SpotPrice           Float  `json:"spot_price"`
+}
+
+// GetQuotes returns the REST quote snapshot of an instrument. An NFO
+// request yields one quote per matching contract.
+func (a *ApificationBreeze) GetQuotes(spec InstrumentSpec) ([]MarketQuote, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	body := map[string]string{
+		"stock_code":    spec.StockCode,
+		"exchange_code": strings.ToUpper(spec.ExchangeCode),
+		"expiry_date":   spec.ExpiryDate,
+		"product_type":  strings.ToLower(spec.ProductType),
+		"right":         strings.ToLower(spec.Right),
+		"strike_price":  spec.StrikePrice,
+	}
+	return callAPI[[]MarketQuote](a, "GET", "/"+string(QUOTE), body)
+}
+
+// SubscribeInstrument subscribes the live feed of the instrument GetQuotes
+// would snapshot.
+func (b *BreezeInstance) SubscribeInstrument(spec InstrumentSpec, getExchangeQuotes, getMarketDepth bool) (map[string]string, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return b.SubscribeFeeds("", strings.ToUpper(spec.ExchangeCode), spec.StockCode, strings.ToLower(spec.ProductType), spec.ExpiryDate, spec.StrikePrice, strings.ToLower(spec.Right), "", getExchangeQuotes, getMarketDepth, false)
+}
+
+func (b *BreezeInstance) UnsubscribeInstrument(spec InstrumentSpec, getExchangeQuotes, getMarketDepth bool) (map[string]string, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return b.UnsubscribeFeeds("", strings.ToUpper(spec.ExchangeCode), spec.StockCode, strings.ToLower(spec.ProductType), spec.ExpiryDate, spec.StrikePrice, strings.ToLower(spec.Right), "", getExchangeQuotes, getMarketDepth, false)
+}
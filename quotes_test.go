@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestGetQuotes(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	f.respond(http.StatusOK, `{"Success":[
+		{"exchange_code":"NFO","product_type":"Options","stock_code":"NIFTY","expiry_date":"27-Jul-2023","right":"Call","strike_price":"19500","ltp":"112.35","best_bid_price":"112.3","best_bid_quantity":"650","total_quantity_traded":"1234500"}
+	],"Status":200,"Error":null}`)
+
+	quotes, err := a.GetQuotes(InstrumentSpec{ExchangeCode: "nfo", StockCode: "NIFTY", ProductType: "Options", ExpiryDate: "27-Jul-2023", StrikePrice: "19500", Right: "Call"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 1 || quotes[0].LTP != 112.35 || quotes[0].StrikePrice != 19500 || quotes[0].BestBidQuantity != 650 || quotes[0].TotalQuantityTraded != 1234500 {
+		t.Errorf("quotes = %+v", quotes)
+	}
+	call := f.last(t)
+	if call.Method != "GET" || call.Path != "/quotes" {
+		t.Errorf("request = %s %s, want GET /quotes", call.Method, call.Path)
+	}
+	checkSigned(t, call)
+	checkBody(t, call, map[string]string{
+		"exchange_code": "NFO",
+		"stock_code":    "NIFTY",
+		"product_type":  "options",
+		"right":         "call",
+		"expiry_date":   "27-Jul-2023",
+		"strike_price":  "19500",
+	})
+}
+
+func TestGetQuotesValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		spec InstrumentSpec
+	}{
+		{"missing exchange", InstrumentSpec{StockCode: "ITC"}},
+		{"missing stock code", InstrumentSpec{ExchangeCode: "NSE"}},
+		{"derivative without expiry", InstrumentSpec{ExchangeCode: "NFO", StockCode: "NIFTY", ProductType: "futures"}},
+		{"option without right", InstrumentSpec{ExchangeCode: "bfo", StockCode: "SENSEX", ProductType: "options", ExpiryDate: "28-Jul-2023", StrikePrice: "66000"}},
+		{"option without strike", InstrumentSpec{ExchangeCode: "NFO", StockCode: "NIFTY", ProductType: "Options", ExpiryDate: "27-Jul-2023", Right: "put"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, a := newFakeBreezeAPI(t)
+			if _, err := a.GetQuotes(tt.spec); !errors.Is(err, ErrValidation) {
+				t.Errorf("GetQuotes error = %v, want ErrValidation", err)
+			}
+			if calls := f.received(); len(calls) != 0 {
+				t.Errorf("invalid request reached the API: %v", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/gob"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultScripCacheMaxAge = 12 * time.Hour
+
+// scripCache is the on-disk form of the instrument list. The validators of
+// the download it came from are kept for a conditional refresh.
+type scripCache struct {
+	SourceURL    string
+	FetchedAt    time.Time
+	ETag         string
+	LastModified string
+	Instruments  []Instrument
+}
+
+func loadScripCache(path string) (*scripCache, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cache scripCache
+	if err := gob.NewDecoder(f).Decode(&cache); err != nil {
+		return nil, err
+	}
+	return &cache, nil
+}
+
+// save writes the cache through a temporary file so a crash mid-write
+// never leaves a truncated cache behind.
+func (c *scripCache) save(path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".scripcache-*")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(tmp).Encode(c); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (c *scripCache) registry() *InstrumentRegistry {
+	registry := NewInstrumentRegistry()
+	registry.mu.Lock()
+	for _, inst := range c.Instruments {
+		registry.add(inst)
+	}
+	registry.mu.Unlock()
+	return registry
+}
+
+// loadScripFile builds the registry from a local StockScriptNew.csv, a
+// SecurityMaster.zip or a cache file written by a previous run.
+func loadScripFile(path string) (*InstrumentRegistry, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip":
+		master, err := OpenSecurityMaster(path)
+		if err != nil {
+			return nil, err
+		}
+		registry := NewInstrumentRegistry()
+		registry.LoadSecurityMaster(master)
+		return registry, nil
+	case ".gob":
+		cache, err := loadScripCache(path)
+		if err != nil {
+			return nil, err
+		}
+		return cache.registry(), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	registry := NewInstrumentRegistry()
+	if err := registry.LoadStockScriptCSV(f); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// loadInstruments picks the cheapest source for the instrument list:
+// ScripFile when set, then a fresh ScripCachePath, then a conditional
+// download that falls back to a stale cache when the network fails.
+func (b *BreezeInstance) loadInstruments() (*InstrumentRegistry, error) {
+	if b.ScripFile != "" {
+		return loadScripFile(b.ScripFile)
+	}
+	if b.ScripCachePath == "" {
+		return b.downloadInstruments(nil)
+	}
+
+	cache, err := loadScripCache(b.ScripCachePath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("ignoring unreadable scrip cache:", err)
+	}
+	if err != nil || cache.SourceURL != b.StockScriptCSVURL {
+		cache = nil
+	}
+	maxAge := b.ScripCacheMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultScripCacheMaxAge
+	}
+	if cache != nil && time.Since(cache.FetchedAt) < maxAge {
+		return cache.registry(), nil
+	}
+
+	registry, err := b.downloadInstruments(cache)
+	if err != nil {
+		if cache != nil {
+			log.Println("scrip list refresh failed, using cache from", cache.FetchedAt.Format(time.RFC3339)+":", err)
+			return cache.registry(), nil
+		}
+		return nil, err
+	}
+	return registry, nil
+}
+
+// downloadInstruments fetches StockScriptCSVURL, sending the validators of
+// cache so an unchanged list costs a 304. The cache file is rewritten after
+// every successful fetch, including a 304, to restart its clock.
+func (b *BreezeInstance) downloadInstruments(cache *scripCache) (*InstrumentRegistry, error) {
+	req, err := http.NewRequest("GET", b.StockScriptCSVURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		if cache.ETag != "" {
+			req.Header.Set("If-None-Match", cache.ETag)
+		}
+		if cache.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cache.LastModified)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cache != nil:
+		cache.FetchedAt = time.Now()
+	case resp.StatusCode == http.StatusOK:
+		registry := NewInstrumentRegistry()
+		if err := registry.LoadStockScriptCSV(resp.Body); err != nil {
+			return nil, err
+		}
+		if b.ScripCachePath != "" {
+			fresh := &scripCache{
+				SourceURL:    b.StockScriptCSVURL,
+				FetchedAt:    time.Now(),
+				ETag:         resp.Header.Get("ETag"),
+				LastModified: resp.Header.Get("Last-Modified"),
+				Instruments:  registry.Instruments(),
+			}
+			if err := fresh.save(b.ScripCachePath); err != nil {
+				log.Println("unable to write scrip cache:", err)
+			}
+		}
+		return registry, nil
+	default:
+		raw, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: "unable to download stock script list", Body: raw}
+	}
+
+	if err := cache.save(b.ScripCachePath); err != nil {
+		log.Println("unable to write scrip cache:", err)
+	}
+	return cache.registry(), nil
+}
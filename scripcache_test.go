@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	scripETag         = `"v1"`
+	scripLastModified = "Thu, 20 Jul 2023 02:00:00 GMT"
+)
+
+// fakeScripServer serves stockScriptCSV with validators and answers a
+// matching conditional request with 304. Status overrides the reply.
+type fakeScripServer struct {
+	mu       sync.Mutex
+	Status   int
+	requests []http.Header
+	srv      *httptest.Server
+}
+
+func newFakeScripServer(t *testing.T) *fakeScripServer {
+	t.Helper()
+	f := &fakeScripServer{}
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests = append(f.requests, r.Header.Clone())
+		status := f.Status
+		f.mu.Unlock()
+		switch {
+		case status != 0:
+			w.WriteHeader(status)
+		case r.Header.Get("If-None-Match") == scripETag:
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("ETag", scripETag)
+			w.Header().Set("Last-Modified", scripLastModified)
+			w.Write([]byte(stockScriptCSV))
+		}
+	}))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeScripServer) received() []http.Header {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]http.Header(nil), f.requests...)
+}
+
+func newScripInstance(f *fakeScripServer, cachePath string) *BreezeInstance {
+	b := NewBreezeInstance("app-key")
+	b.StockScriptCSVURL = f.srv.URL + "/StockScriptNew.csv"
+	b.ScripCachePath = cachePath
+	return b
+}
+
+// writeScripCache leaves a cache of one instrument, fetched age ago, for
+// the instance's list.
+func writeScripCache(t *testing.T, b *BreezeInstance, age time.Duration) {
+	t.Helper()
+	cache := &scripCache{
+		SourceURL:    b.StockScriptCSVURL,
+		FetchedAt:    time.Now().Add(-age),
+		ETag:         scripETag,
+		LastModified: scripLastModified,
+		Instruments:  []Instrument{{Token: "1660", ISECCode: "ITC", Exchange: "NSE"}},
+	}
+	if err := cache.save(b.ScripCachePath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadInstrumentsScripFile(t *testing.T) {
+	f := newFakeScripServer(t)
+	path := filepath.Join(t.TempDir(), "StockScriptNew.csv")
+	if err := os.WriteFile(path, []byte(stockScriptCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b := newScripInstance(f, filepath.Join(t.TempDir(), "scrips.gob"))
+	b.ScripFile = path
+
+	registry, err := b.loadInstruments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registry.Len() != 10 {
+		t.Errorf("Len = %d, want the file's 10 instruments", registry.Len())
+	}
+	if n := len(f.received()); n != 0 {
+		t.Errorf("%d downloads with a ScripFile set", n)
+	}
+}
+
+func TestLoadInstrumentsDownloadsAndCaches(t *testing.T) {
+	f := newFakeScripServer(t)
+	dir := t.TempDir()
+	b := newScripInstance(f, filepath.Join(dir, "cache", "scrips.gob"))
+
+	registry, err := b.loadInstruments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registry.Len() != 10 {
+		t.Errorf("Len = %d, want 10", registry.Len())
+	}
+	requests := f.received()
+	if len(requests) != 1 || requests[0].Get("If-None-Match") != "" || requests[0].Get("If-Modified-Since") != "" {
+		t.Fatalf("requests = %v, want one unconditional download", requests)
+	}
+
+	cache, err := loadScripCache(b.ScripCachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.SourceURL != b.StockScriptCSVURL || cache.ETag != scripETag || cache.LastModified != scripLastModified || len(cache.Instruments) != 10 {
+		t.Errorf("cache = %s %s %q, %d instruments", cache.SourceURL, cache.ETag, cache.LastModified, len(cache.Instruments))
+	}
+	if time.Since(cache.FetchedAt) > time.Minute {
+		t.Errorf("cache fetched at %v", cache.FetchedAt)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "cache", ".scripcache-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+
+	// A second load within the max age reads the cache without a request.
+	if registry, err := b.loadInstruments(); err != nil || registry.Len() != 10 {
+		t.Fatalf("cached load = %v, %v", registry, err)
+	}
+	if n := len(f.received()); n != 1 {
+		t.Errorf("%d requests after a fresh-cache load, want 1", n)
+	}
+}
+
+func TestLoadInstrumentsNotModified(t *testing.T) {
+	f := newFakeScripServer(t)
+	b := newScripInstance(f, filepath.Join(t.TempDir(), "scrips.gob"))
+	b.ScripCacheMaxAge = time.Hour
+	writeScripCache(t, b, 2*time.Hour)
+
+	registry, err := b.loadInstruments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registry.ByToken("NSE", "1660"); !ok || registry.Len() != 1 {
+		t.Errorf("registry has %d instruments, want the cached one", registry.Len())
+	}
+	requests := f.received()
+	if len(requests) != 1 {
+		t.Fatalf("%d requests, want one conditional download", len(requests))
+	}
+	if got := requests[0].Get("If-None-Match"); got != scripETag {
+		t.Errorf("If-None-Match = %q, want %q", got, scripETag)
+	}
+	if got := requests[0].Get("If-Modified-Since"); got != scripLastModified {
+		t.Errorf("If-Modified-Since = %q, want %q", got, scripLastModified)
+	}
+
+	// The 304 restarts the cache's clock.
+	cache, err := loadScripCache(b.ScripCachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(cache.FetchedAt) > time.Minute || len(cache.Instruments) != 1 {
+		t.Errorf("cache after 304: fetched at %v with %d instruments", cache.FetchedAt, len(cache.Instruments))
+	}
+}
+
+func TestLoadInstrumentsStaleFallback(t *testing.T) {
+	f := newFakeScripServer(t)
+	f.Status = http.StatusServiceUnavailable
+	b := newScripInstance(f, filepath.Join(t.TempDir(), "scrips.gob"))
+	writeScripCache(t, b, 48*time.Hour)
+
+	registry, err := b.loadInstruments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registry.ByToken("NSE", "1660"); !ok {
+		t.Error("stale cache not used when the download failed")
+	}
+	cache, _ := loadScripCache(b.ScripCachePath)
+	if cache == nil || time.Since(cache.FetchedAt) < 47*time.Hour {
+		t.Error("a failed refresh restarted the cache's clock")
+	}
+
+	// A download that never connects falls back the same way, and without
+	// a usable cache the failure is returned.
+	f.srv.Close()
+	if registry, err := b.loadInstruments(); err != nil || registry.Len() != 1 {
+		t.Errorf("load with the list unreachable = %v, %v; want the stale cache", registry, err)
+	}
+	for _, setup := range []func(){
+		func() { os.Remove(b.ScripCachePath) },
+		func() { os.WriteFile(b.ScripCachePath, []byte("not a gob"), 0o644) },
+	} {
+		setup()
+		if _, err := b.loadInstruments(); err == nil {
+			t.Error("loadInstruments succeeded with no list and no cache")
+		}
+	}
+}
+
+func TestLoadInstrumentsIgnoresOtherSource(t *testing.T) {
+	f := newFakeScripServer(t)
+	b := newScripInstance(f, filepath.Join(t.TempDir(), "scrips.gob"))
+	writeScripCache(t, b, 0)
+	b.StockScriptCSVURL = f.srv.URL + "/other.csv"
+
+	registry, err := b.loadInstruments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registry.Len() != 10 {
+		t.Errorf("Len = %d, want the downloaded list", registry.Len())
+	}
+	requests := f.received()
+	if len(requests) != 1 || requests[0].Get("If-None-Match") != "" {
+		t.Errorf("requests = %v, want one unconditional download", requests)
+	}
+}
+
+func TestLoadInstrumentsDownloadError(t *testing.T) {
+	f := newFakeScripServer(t)
+	f.Status = http.StatusNotFound
+	b := newScripInstance(f, "")
+	_, err := b.loadInstruments()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("error = %v, want a 404 APIError", err)
+	}
+}
+
+func TestScripCacheSaveReplaces(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scrips.gob")
+	for _, etag := range []string{`"a"`, `"b"`} {
+		cache := &scripCache{SourceURL: "u", ETag: etag, Instruments: []Instrument{{Token: "1"}}}
+		if err := cache.save(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache, err := loadScripCache(path)
+	if err != nil || cache.ETag != `"b"` {
+		t.Fatalf("loadScripCache = %+v, %v; want the second save", cache, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the cache", len(entries))
+	}
+
+	// A save that cannot complete leaves the previous cache intact.
+	if err := os.Mkdir(filepath.Join(dir, "blocked"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&scripCache{}).save(filepath.Join(dir, "blocked")); err == nil {
+		t.Error("save over a directory succeeded")
+	}
+	if cache, err := loadScripCache(path); err != nil || cache.ETag != `"b"` {
+		t.Errorf("cache after a failed save = %+v, %v", cache, err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".scripcache-*")); len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
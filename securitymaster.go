@@ -0,0 +1,288 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Instrument is one contract from the ScripMaster files. ISECCode is the
+// stock code the Breeze APIs take; ExchangeCode is the exchange's own symbol.
+type Instrument struct {
+	Token          string
+	ISECCode       string
+	ExchangeCode   string
+	Exchange       string
+	Name           string
+	Series         string
+	InstrumentType string
+	LotSize        int
+	TickSize       float64
+	ExpiryDate     string
+	StrikePrice    float64
+	Right          string
+}
+
+// securityMasterExchange maps the ISEC_NSE_CODE_MAP_FILE keys to the
+// exchange their file lists.
+var securityMasterExchange = map[string]string{
+	"nse":   "NSE",
+	"bse":   "BSE",
+	"fonse": "NFO",
+	"cdnse": "NDX",
+}
+
+// SecurityMaster indexes the instruments of SecurityMaster.zip by token,
+// ISEC code and exchange symbol.
+type SecurityMaster struct {
+	Instruments []Instrument
+
+	byToken        map[string][]int
+	byISECCode     map[string][]int
+	byExchangeCode map[string][]int
+}
+
+// DownloadSecurityMaster fetches SecurityMaster.zip from url, or
+// SECURITY_MASTER_URL when url is empty, and parses it.
+func DownloadSecurityMaster(url string) (*SecurityMaster, error) {
+	if url == "" {
+		url = SECURITY_MASTER_URL
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: "unable to download security master", Body: raw}
+	}
+	return ParseSecurityMaster(bytes.NewReader(raw), int64(len(raw)))
+}
+
+// OpenSecurityMaster parses a SecurityMaster.zip already on disk.
+func OpenSecurityMaster(path string) (*SecurityMaster, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return ParseSecurityMaster(f, info.Size())
+}
+
+// ParseSecurityMaster reads every ScripMaster file named in
+// ISEC_NSE_CODE_MAP_FILE from the zip; files missing from the archive are
+// skipped.
+func ParseSecurityMaster(r io.ReaderAt, size int64) (*SecurityMaster, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[strings.ToLower(f.Name[strings.LastIndex(f.Name, "/")+1:])] = f
+	}
+
+	keys := make([]string, 0, len(ISEC_NSE_CODE_MAP_FILE))
+	for key := range ISEC_NSE_CODE_MAP_FILE {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	m := newSecurityMaster()
+	for _, key := range keys {
+		name := ISEC_NSE_CODE_MAP_FILE[key]
+		f, ok := files[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		instruments, err := parseScripMaster(rc, securityMasterExchange[key])
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, inst := range instruments {
+			m.add(inst)
+		}
+	}
+	return m, nil
+}
+
+func newSecurityMaster() *SecurityMaster {
+	return &SecurityMaster{
+		byToken:        map[string][]int{},
+		byISECCode:     map[string][]int{},
+		byExchangeCode: map[string][]int{},
+	}
+}
+
+func (m *SecurityMaster) add(inst Instrument) {
+	i := len(m.Instruments)
+	m.Instruments = append(m.Instruments, inst)
+	m.byToken[inst.Token] = append(m.byToken[inst.Token], i)
+	m.byISECCode[strings.ToUpper(inst.ISECCode)] = append(m.byISECCode[strings.ToUpper(inst.ISECCode)], i)
+	m.byExchangeCode[strings.ToUpper(inst.ExchangeCode)] = append(m.byExchangeCode[strings.ToUpper(inst.ExchangeCode)], i)
+}
+
+// parseScripMaster reads one ScripMaster file. Columns are located by their
+// header, since the NSE, BSE and F&O files order and name them differently.
+func parseScripMaster(r io.Reader, exchange string) ([]Instrument, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+		br.Discard(3)
+	}
+	reader := csv.NewReader(br)
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.ToLower(strings.Join(strings.Fields(name), ""))] = i
+	}
+	if _, ok := col["token"]; !ok {
+		return nil, fmt.Errorf("no Token column in header")
+	}
+	field := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	instruments := []Instrument{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return instruments, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		inst := Instrument{
+			Token:          field(row, "token"),
+			ISECCode:       field(row, "shortname"),
+			ExchangeCode:   field(row, "exchangecode"),
+			Exchange:       exchange,
+			Name:           field(row, "companyname"),
+			Series:         field(row, "series"),
+			InstrumentType: field(row, "instrumentname"),
+			ExpiryDate:     field(row, "expirydate"),
+		}
+		if inst.Token == "" {
+			continue
+		}
+		inst.LotSize, _ = strconv.Atoi(field(row, "lotsize"))
+		// Tick sizes are listed in paise.
+		if tick, err := strconv.ParseFloat(field(row, "ticksize"), 64); err == nil {
+			inst.TickSize = tick / 100
+		}
+		inst.StrikePrice, _ = strconv.ParseFloat(field(row, "strikeprice"), 64)
+		switch strings.ToUpper(field(row, "optiontype")) {
+		case "CE":
+			inst.Right = "call"
+		case "PE":
+			inst.Right = "put"
+		}
+		instruments = append(instruments, inst)
+	}
+}
+
+// ByToken returns the instrument with the given exchange token.
+func (m *SecurityMaster) ByToken(exchange, token string) (Instrument, bool) {
+	for _, i := range m.byToken[token] {
+		if strings.EqualFold(m.Instruments[i].Exchange, exchange) {
+			return m.Instruments[i], true
+		}
+	}
+	return Instrument{}, false
+}
+
+func (m *SecurityMaster) ByISECCode(code string) []Instrument {
+	return m.collect(m.byISECCode[strings.ToUpper(code)])
+}
+
+func (m *SecurityMaster) ByExchangeCode(code string) []Instrument {
+	return m.collect(m.byExchangeCode[strings.ToUpper(code)])
+}
+
+func (m *SecurityMaster) collect(indices []int) []Instrument {
+	out := make([]Instrument, 0, len(indices))
+	for _, i := range indices {
+		out = append(out, m.Instruments[i])
+	}
+	return out
+}
+
+// InstrumentQuery selects instruments in SecurityMaster.Find. Zero-valued
+// fields match anything; strings compare case-insensitively.
+type InstrumentQuery struct {
+	Token        string
+	ISECCode     string
+	ExchangeCode string
+	Exchange     string
+	LotSize      int
+	TickSize     float64
+	ExpiryDate   string
+	StrikePrice  float64
+	Right        string
+}
+
+// Find returns every instrument matching all the fields set in q.
+func (m *SecurityMaster) Find(q InstrumentQuery) []Instrument {
+	// Start from the narrowest index the query allows.
+	var candidates []int
+	switch {
+	case q.Token != "":
+		candidates = m.byToken[q.Token]
+	case q.ISECCode != "":
+		candidates = m.byISECCode[strings.ToUpper(q.ISECCode)]
+	case q.ExchangeCode != "":
+		candidates = m.byExchangeCode[strings.ToUpper(q.ExchangeCode)]
+	default:
+		candidates = make([]int, len(m.Instruments))
+		for i := range candidates {
+			candidates[i] = i
+		}
+	}
+
+	match := func(want, got string) bool {
+		return want == "" || strings.EqualFold(want, got)
+	}
+	out := []Instrument{}
+	for _, i := range candidates {
+		inst := m.Instruments[i]
+		if match(q.Token, inst.Token) && match(q.ISECCode, inst.ISECCode) &&
+			match(q.ExchangeCode, inst.ExchangeCode) && match(q.Exchange, inst.Exchange) &&
+			match(q.ExpiryDate, inst.ExpiryDate) && match(q.Right, inst.Right) &&
+			(q.LotSize == 0 || q.LotSize == inst.LotSize) &&
+			(q.TickSize == 0 || q.TickSize == inst.TickSize) &&
+			(q.StrikePrice == 0 || q.StrikePrice == inst.StrikePrice) {
+			out = append(out, inst)
+		}
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testdata/securitymaster/SecurityMaster.zip holds trimmed NSE, BSE and F&O
+// ScripMaster files. Each orders and spells its header differently: the
+// NSE file has a BOM, the BSE file sits in a folder and has spaced
+// headers, and there is no CDNSE file.
+var securityMasterFixture = filepath.Join("testdata", "securitymaster", "SecurityMaster.zip")
+
+func TestOpenSecurityMaster(t *testing.T) {
+	m, err := OpenSecurityMaster(securityMasterFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		exchange, token string
+		want            Instrument
+	}{
+		{"NSE", "2885", Instrument{
+			Token: "2885", ISECCode: "RELIND", ExchangeCode: "RELIANCE", Exchange: "NSE",
+			Name: "RELIANCE INDUSTRIES LTD", Series: "EQ", LotSize: 1, TickSize: 0.05,
+		}},
+		{"BSE", "500325", Instrument{
+			Token: "500325", ISECCode: "RELIND", ExchangeCode: "RELIANCE", Exchange: "BSE",
+			Name: "RELIANCE INDUSTRIES LTD.", Series: "A", LotSize: 1, TickSize: 0.05,
+		}},
+		{"NFO", "43650", Instrument{
+			Token: "43650", ISECCode: "NIFTY", ExchangeCode: "NIFTY", Exchange: "NFO",
+			Name: "NIFTY 50", Series: "OPTION", InstrumentType: "OPTIDX", LotSize: 50, TickSize: 0.05,
+			ExpiryDate: "25-Jan-2024", StrikePrice: 21500, Right: "call",
+		}},
+		{"NFO", "43651", Instrument{
+			Token: "43651", ISECCode: "NIFTY", ExchangeCode: "NIFTY", Exchange: "NFO",
+			Name: "NIFTY 50", Series: "OPTION", InstrumentType: "OPTIDX", LotSize: 50, TickSize: 0.05,
+			ExpiryDate: "25-Jan-2024", StrikePrice: 21500, Right: "put",
+		}},
+		{"NFO", "35012", Instrument{
+			Token: "35012", ISECCode: "RELIND", ExchangeCode: "RELIANCE", Exchange: "NFO",
+			Name: "RELIANCE INDUSTRIES LTD", Series: "FUTURE", InstrumentType: "FUTSTK", LotSize: 250, TickSize: 0.1,
+			ExpiryDate: "25-Jan-2024",
+		}},
+	}
+	for _, tt := range tests {
+		got, ok := m.ByToken(tt.exchange, tt.token)
+		if !ok {
+			t.Errorf("ByToken(%s, %s) found nothing", tt.exchange, tt.token)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ByToken(%s, %s) =\n%+v\nwant\n%+v", tt.exchange, tt.token, got, tt.want)
+		}
+	}
+
+	// The row without a token is dropped.
+	if len(m.Instruments) != 6 {
+		t.Errorf("%d instruments, want 6", len(m.Instruments))
+	}
+	if got := m.ByISECCode("relind"); len(got) != 3 {
+		t.Errorf("ByISECCode(relind) = %d instruments, want 3", len(got))
+	}
+	if got := m.Find(InstrumentQuery{ExchangeCode: "nifty", Right: "Put"}); len(got) != 1 || got[0].Token != "43651" {
+		t.Errorf("Find(NIFTY puts) = %+v", got)
+	}
+	if got := m.Find(InstrumentQuery{TickSize: 0.1}); len(got) != 1 || got[0].Token != "35012" {
+		t.Errorf("Find(tick 0.1) = %+v", got)
+	}
+}
+
+func TestDownloadSecurityMaster(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.Dir(filepath.Dir(securityMasterFixture))))
+	defer srv.Close()
+
+	m, err := DownloadSecurityMaster(srv.URL + "/SecurityMaster.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.ByToken("NSE", "1594"); !ok {
+		t.Error("downloaded master is missing INFY")
+	}
+	if _, err := DownloadSecurityMaster(srv.URL + "/missing.zip"); err == nil {
+		t.Error("DownloadSecurityMaster succeeded on a 404")
+	}
+}
+
+func TestParseScripMasterNeedsToken(t *testing.T) {
+	_, err := parseScripMaster(strings.NewReader("\"ShortName\",\"Series\"\n\"RELIND\",\"EQ\"\n"), "NSE")
+	if err == nil {
+		t.Error("parseScripMaster accepted a file without a Token column")
+	}
+}
@@ -3,22 +3,67 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
+	"math/rand"
+	"net/http"
+	"sort"
 	"sync"
+	"time"
+)
+
+// ReconnectPolicy controls how a dropped socket is re-established. Delays
+// grow exponentially from BaseDelay up to MaxDelay with random jitter;
+// MaxAttempts of zero retries until the handler is disconnected.
+type ReconnectPolicy struct {
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	MaxAttempts int
+}
 
-	"nhooyr.io/websocket"
-	"nhooyr.io/websocket/wsjson"
+const (
+	defaultMaxFeedTokens        = 2000
+	defaultSubscribeBatchWindow = 20 * time.Millisecond
 )
 
+var DefaultReconnectPolicy = ReconnectPolicy{
+	BaseDelay: 500 * time.Millisecond,
+	MaxDelay:  30 * time.Second,
+}
+
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	delay := p.MaxDelay
+	if attempt < 32 {
+		if d := p.BaseDelay << uint(attempt-1); d > 0 && d < p.MaxDelay {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// Keep at least half the delay so a burst of clients dropped together
+	// does not collapse back onto the server at once.
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
 type SocketEventBreeze struct {
 	namespace      string
 	breeze         *BreezeInstance
-	conn           *websocket.Conn
-	tokenlist      map[string]bool
-	ohlcstate      map[string]bool
+	conn           *socketIOClient
+	connMu         sync.RWMutex
+	hostname       string
+	isOHLCStream   bool
+	strategyFlag   bool
+	orderNotify    bool
+	tokenlist      refCounts
+	ohlcstate      refCounts
+	pending        map[string]bool
+	flushTimer     *time.Timer
 	authentication bool
+	decoder        *TickDecoder
+	tick           Tick
 	mu             sync.Mutex
+	sendMu         sync.Mutex
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
@@ -28,83 +73,222 @@ func NewSocketEventBreeze(namespace string, breeze *BreezeInstance) *SocketEvent
 	return &SocketEventBreeze{
 		namespace:      namespace,
 		breeze:         breeze,
-		tokenlist:      make(map[string]bool),
-		ohlcstate:      make(map[string]bool),
+		tokenlist:      refCounts{},
+		ohlcstate:      refCounts{},
+		pending:        map[string]bool{},
 		authentication: true,
+		decoder:        NewTickDecoder(),
 		ctx:            ctx,
 		cancel:         cancel,
 	}
 }
 
 func (seb *SocketEventBreeze) Connect(hostname string, isOHLCStream bool, strategyFlag bool) error {
-	var err error
-	seb.conn, _, err = websocket.Dial(seb.ctx, hostname, nil)
-	if err != nil {
+	seb.hostname = hostname
+	seb.isOHLCStream = isOHLCStream
+	seb.strategyFlag = strategyFlag
+	if err := seb.dial(); err != nil {
 		return err
 	}
 
+	go seb.run()
+	return nil
+}
+
+func (seb *SocketEventBreeze) dial() error {
+	path := "/socket.io/"
+	if seb.isOHLCStream {
+		path = "/ohlcvstream/"
+	}
 	auth := map[string]string{
 		"user":  seb.breeze.UserID,
 		"token": seb.breeze.SessionKey,
 	}
-
-	if err := wsjson.Write(seb.ctx, seb.conn, auth); err != nil {
+	conn, resp, err := dialSocketIO(seb.ctx, seb.hostname, path, seb.namespace, auth)
+	if err != nil {
+		if errors.Is(err, ErrAuth) || (resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden)) {
+			seb.authentication = false
+			return &AuthError{Message: seb.breeze.ExceptMessage["AUTHENICATION_EXCEPTION"], Err: err}
+		}
 		return err
 	}
 
-	go seb.readMessages()
+	seb.connMu.Lock()
+	seb.conn = conn
+	seb.connMu.Unlock()
 	return nil
 }
 
-func (seb *SocketEventBreeze) readMessages() {
+// currentConn returns the live connection; reconnect swaps it underneath
+// concurrent writers.
+func (seb *SocketEventBreeze) currentConn() *socketIOClient {
+	seb.connMu.RLock()
+	defer seb.connMu.RUnlock()
+	return seb.conn
+}
+
+func (seb *SocketEventBreeze) emit(event string, data interface{}) error {
+	conn := seb.currentConn()
+	if conn == nil {
+		return &NotConnectedError{Message: "SOCKET_CONNECTION_DISCONNECTED"}
+	}
+	return conn.Emit(seb.ctx, event, data)
+}
+
+// run reads until the connection drops, then reconnects and replays the
+// subscriptions. It returns once OnDisconnect is called or the reconnect
+// policy gives up.
+func (seb *SocketEventBreeze) run() {
+	seb.notifyConnected()
 	for {
-		var msg map[string]interface{}
-		if err := wsjson.Read(seb.ctx, seb.conn, &msg); err != nil {
-			if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
-				return
+		err := seb.readMessages()
+		if seb.ctx.Err() != nil {
+			seb.notifyDisconnected(nil)
+			return
+		}
+		log.Println("readMessages error:", err)
+		if err := seb.reconnect(err); err != nil {
+			if seb.ctx.Err() != nil {
+				err = nil
+			}
+			seb.notifyDisconnected(err)
+			return
+		}
+	}
+}
+
+func (seb *SocketEventBreeze) reconnect(cause error) error {
+	policy := seb.breeze.Reconnect
+	for attempt := 1; policy.MaxAttempts == 0 || attempt <= policy.MaxAttempts; attempt++ {
+		delay := policy.backoff(attempt)
+		if seb.breeze.OnReconnecting != nil {
+			seb.breeze.OnReconnecting(seb.hostname, attempt, delay)
+		}
+		select {
+		case <-seb.ctx.Done():
+			return seb.ctx.Err()
+		case <-time.After(delay):
+		}
+		if err := seb.dial(); err != nil {
+			cause = err
+			if errors.Is(err, ErrAuth) || seb.ctx.Err() != nil {
+				return err
 			}
-			log.Println("readMessages error:", err)
 			continue
 		}
+		seb.notifyConnected()
+		seb.Rewatch()
+		seb.RewatchOHLC()
+		return nil
+	}
+	return cause
+}
 
-		seb.handleMessage(msg)
+func (seb *SocketEventBreeze) notifyConnected() {
+	if seb.breeze.OnConnected != nil {
+		seb.breeze.OnConnected(seb.hostname)
 	}
 }
 
-func (seb *SocketEventBreeze) handleMessage(msg map[string]interface{}) {
-	if eventType, ok := msg["event"]; ok {
-		switch eventType {
-		case "order":
-			data, err := json.Marshal(msg["data"])
-			if err == nil {
-				seb.onMessage(data)
-			}
-		case "ohlc":
-			data, err := json.Marshal(msg["data"])
-			if err == nil {
-				seb.onOHLCStream(data)
-			}
+func (seb *SocketEventBreeze) notifyDisconnected(err error) {
+	if seb.breeze.OnDisconnected != nil {
+		seb.breeze.OnDisconnected(seb.hostname, err)
+	}
+}
+
+func (seb *SocketEventBreeze) readMessages() error {
+	conn := seb.currentConn()
+	for {
+		event, args, err := conn.ReadEvent(seb.ctx)
+		if err != nil {
+			return err
+		}
+		seb.handleMessage(event, args)
+	}
+}
+
+func (seb *SocketEventBreeze) handleMessage(event string, args []json.RawMessage) {
+	if len(args) == 0 {
+		return
+	}
+	switch event {
+	case "stock":
+		seb.onMessage(args[0])
+	case "order":
+		seb.mu.Lock()
+		notify := seb.orderNotify
+		seb.mu.Unlock()
+		if notify || seb.strategyFlag {
+			seb.onMessage(args[0])
+		}
+	case "ohlc":
+		seb.onOHLCStream(args[0])
+	default:
+		if _, ok := FeedIntervalMap[event]; ok {
+			seb.onOHLCStream(args[0])
 		}
 	}
 }
 
 func (seb *SocketEventBreeze) OnDisconnect() {
 	seb.cancel()
-	seb.conn.Close(websocket.StatusNormalClosure, "transport close")
+	seb.mu.Lock()
+	if seb.flushTimer != nil {
+		seb.flushTimer.Stop()
+		seb.flushTimer = nil
+	}
+	seb.mu.Unlock()
+	if conn := seb.currentConn(); conn != nil {
+		conn.Close()
+	}
 }
 
+// Notify starts delivering "order" events from this connection to OnTicks.
 func (seb *SocketEventBreeze) Notify() {
-	seb.conn.Write(seb.ctx, websocket.MessageText, []byte(`{"type": "notify"}`))
+	seb.mu.Lock()
+	seb.orderNotify = true
+	seb.mu.Unlock()
 }
 
+// onMessage decodes a tick into a Tick reused for every message and hands
+// it to the typed internal handlers, OnTypedTick and the Subscribe
+// channels, then parses it again for the map-based internal handlers,
+// OnTicks and OnTicks2.
 func (seb *SocketEventBreeze) onMessage(data []byte) {
-	parsedData := seb.breeze.ParseData(data)
-	if symbol, ok := parsedData["symbol"]; ok && symbol != nil {
-		stockData := seb.breeze.GetDataFromStockTokenValue(symbol.(string))
-		for k, v := range stockData {
-			parsedData[k] = v
+	if seb.breeze.OnTypedTick != nil || seb.breeze.typedTickHandlers.active() || seb.breeze.ticks.active() {
+		if kind, err := seb.decoder.Decode(data, &seb.tick); err != nil {
+			log.Println("onMessage decode error:", err)
+		} else if kind != TickUnknown {
+			seb.breeze.typedTickHandlers.dispatch(&seb.tick)
+			if seb.breeze.OnTypedTick != nil {
+				seb.breeze.OnTypedTick(&seb.tick)
+			}
+			if seb.breeze.ticks != nil {
+				seb.breeze.ticks.dispatch(&seb.tick)
+			}
 		}
 	}
+	if seb.breeze.OnTicks == nil && seb.breeze.OnTicks2 == nil && !seb.breeze.tickHandlers.active() {
+		return
+	}
+
+	var fields []interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		log.Println("onMessage error:", err)
+		return
+	}
+	parsedData := seb.breeze.parseData(fields)
+	if parsedData == nil {
+		return
+	}
+	if symbol, ok := parsedData["symbol"].(string); ok {
+		if stockData, err := seb.breeze.GetDataFromStockTokenValue(symbol); err == nil {
+			for k, v := range stockData {
+				parsedData[k] = v
+			}
+		}
+	}
+	seb.breeze.tickHandlers.dispatch(parsedData)
 	if seb.breeze.OnTicks != nil {
 		seb.breeze.OnTicks(parsedData)
 	}
@@ -114,77 +298,261 @@ func (seb *SocketEventBreeze) onMessage(data []byte) {
 }
 
 func (seb *SocketEventBreeze) onOHLCStream(data []byte) {
-	parsedData := seb.breeze.ParseOHLCData(data)
-	seb.breeze.OnTicks(parsedData)
+	var candle string
+	if err := json.Unmarshal(data, &candle); err != nil {
+		log.Println("onOHLCStream error:", err)
+		return
+	}
+	parsedData := seb.breeze.parseOhlcData(candle)
+	seb.breeze.tickHandlers.dispatch(parsedData)
+	if seb.breeze.OnTicks != nil {
+		seb.breeze.OnTicks(parsedData)
+	}
+}
+
+// refCounts counts the holders of each feed token or OHLC room, so the
+// socket only joins on the first reference and leaves on the last.
+type refCounts map[string]int
+
+// acquire adds a reference to each key and returns those that had none.
+func (r refCounts) acquire(keys []string) []string {
+	joined := []string{}
+	for _, key := range keys {
+		r[key]++
+		if r[key] == 1 {
+			joined = append(joined, key)
+		}
+	}
+	return joined
+}
+
+// release drops a reference from each key and returns those left with
+// none. Keys without a reference are ignored.
+func (r refCounts) release(keys []string) []string {
+	left := []string{}
+	for _, key := range keys {
+		if r[key] == 0 {
+			continue
+		}
+		r[key]--
+		if r[key] == 0 {
+			delete(r, key)
+			left = append(left, key)
+		}
+	}
+	return left
+}
+
+func (r refCounts) copy() map[string]int {
+	copied := make(map[string]int, len(r))
+	for k, v := range r {
+		copied[k] = v
+	}
+	return copied
+}
+
+func watchKeys(data interface{}) []string {
+	switch v := data.(type) {
+	case []string:
+		return v
+	case string:
+		return []string{v}
+	}
+	return nil
+}
+
+// socketFrame is a join or leave built under seb.mu and emitted after it
+// is released.
+type socketFrame struct {
+	event string
+	data  interface{}
+}
+
+// emitUnlock releases seb.mu, which the caller must hold, and emits frames
+// in order. sendMu is taken before seb.mu is released, so frames built by
+// concurrent callers still go out in the order they were built, while a
+// slow write blocks neither Watch nor the read loop.
+func (seb *SocketEventBreeze) emitUnlock(frames []socketFrame) error {
+	seb.sendMu.Lock()
+	seb.mu.Unlock()
+	defer seb.sendMu.Unlock()
+	var err error
+	for _, f := range frames {
+		if emitErr := seb.emit(f.event, f.data); err == nil {
+			err = emitErr
+		}
+	}
+	return err
 }
 
 func (seb *SocketEventBreeze) RewatchOHLC() {
 	seb.mu.Lock()
-	defer seb.mu.Unlock()
+	rooms := make([]string, 0, len(seb.ohlcstate))
 	for room := range seb.ohlcstate {
-		seb.conn.Write(seb.ctx, websocket.MessageText, []byte(`{"type": "join", "room": "`+room+`"}`))
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	frames := make([]socketFrame, len(rooms))
+	for i, room := range rooms {
+		frames[i] = socketFrame{"join", room}
 	}
+	seb.emitUnlock(frames)
 }
 
+// WatchStreamData joins the OHLC room data unless it is already held.
 func (seb *SocketEventBreeze) WatchStreamData(data, channel string) error {
-	if seb.conn != nil {
-		if !seb.ohlcstate[data] {
-			seb.ohlcstate[data] = true
-		}
-		seb.conn.Write(seb.ctx, websocket.MessageText, []byte(`{"type": "join", "data": "`+data+`"}`))
+	if seb.currentConn() == nil {
+		return &NotConnectedError{Message: "OHLC_SOCKET_CONNECTION_DISCONNECTED"}
+	}
+	seb.mu.Lock()
+	if len(seb.ohlcstate.acquire([]string{data})) == 0 {
+		seb.mu.Unlock()
 		return nil
 	}
-	return fmt.Errorf("OHLC_SOCKET_CONNECTION_DISCONNECTED")
+	return seb.emitUnlock([]socketFrame{{"join", data}})
+}
+
+// UnwatchStreamData drops a reference to the OHLC room data and leaves it
+// once no reference remains.
+func (seb *SocketEventBreeze) UnwatchStreamData(data string) {
+	seb.mu.Lock()
+	if len(seb.ohlcstate.release([]string{data})) == 0 {
+		seb.mu.Unlock()
+		return
+	}
+	seb.emitUnlock([]socketFrame{{"leave", data}})
 }
 
+// Rewatch joins every held token in one frame after a reconnect. Queued
+// changes are already reflected in tokenlist, so they are dropped.
 func (seb *SocketEventBreeze) Rewatch() {
-	seb.Notify()
 	seb.mu.Lock()
-	defer seb.mu.Unlock()
+	seb.pending = map[string]bool{}
+	var frames []socketFrame
 	if len(seb.tokenlist) > 0 {
 		tokens := make([]string, 0, len(seb.tokenlist))
 		for token := range seb.tokenlist {
 			tokens = append(tokens, token)
 		}
-		seb.conn.Write(seb.ctx, websocket.MessageText, []byte(`{"type": "join", "tokens": "`+fmt.Sprintf("%v", tokens)+`"}`))
+		sort.Strings(tokens)
+		frames = append(frames, socketFrame{"join", tokens})
 	}
+	seb.emitUnlock(frames)
 }
 
+// Watch takes a reference to each token in data, a string or []string.
+// Tokens nobody held before are queued and joined in one frame with those
+// of other calls inside SubscribeBatchWindow. A call that would take the
+// connection past MaxFeedTokens fails with a FeedLimitError.
 func (seb *SocketEventBreeze) Watch(data interface{}) error {
-	if seb.conn != nil {
-		switch v := data.(type) {
-		case []string:
-			for _, entry := range v {
-				seb.tokenlist[entry] = true
-			}
-		case string:
-			seb.tokenlist[v] = true
-		}
-		seb.conn.Write(seb.ctx, websocket.MessageText, []byte(`{"type": "join", "data": "`+fmt.Sprintf("%v", data)+`"}`))
-		return nil
+	if seb.currentConn() == nil {
+		return &NotConnectedError{Message: "LIVESTREAM_SOCKET_CONNECTION_DISCONNECTED"}
 	}
-	return fmt.Errorf("LIVESTREAM_SOCKET_CONNECTION_DISCONNECTED")
+	seb.mu.Lock()
+	keys := watchKeys(data)
+	if err := seb.checkFeedLimit(keys); err != nil {
+		seb.mu.Unlock()
+		return err
+	}
+	return seb.emitUnlock(seb.queue(seb.tokenlist.acquire(keys), nil))
 }
 
+// Unwatch drops a reference to each token in data and queues a leave for
+// those no other caller still holds.
 func (seb *SocketEventBreeze) Unwatch(data interface{}) {
-	switch v := data.(type) {
-	case []string:
-		for _, entry := range v {
-			delete(seb.tokenlist, entry)
+	seb.mu.Lock()
+	seb.emitUnlock(seb.queue(nil, seb.tokenlist.release(watchKeys(data))))
+}
+
+func (seb *SocketEventBreeze) checkFeedLimit(keys []string) error {
+	limit := seb.breeze.MaxFeedTokens
+	if limit == 0 {
+		limit = defaultMaxFeedTokens
+	}
+	if limit < 0 {
+		return nil
+	}
+	added := map[string]bool{}
+	for _, key := range keys {
+		if seb.tokenlist[key] == 0 {
+			added[key] = true
+		}
+	}
+	if len(seb.tokenlist)+len(added) > limit {
+		return &FeedLimitError{Limit: limit, Held: len(seb.tokenlist), Requested: len(added)}
+	}
+	return nil
+}
+
+// queue records joins and leaves for the next flush. A join and a leave of
+// the same token inside one window cancel out. Without a batch window the
+// frames to emit now are returned. The caller must hold seb.mu.
+func (seb *SocketEventBreeze) queue(joined, left []string) []socketFrame {
+	for _, token := range joined {
+		if join, ok := seb.pending[token]; ok && !join {
+			delete(seb.pending, token)
+		} else {
+			seb.pending[token] = true
+		}
+	}
+	for _, token := range left {
+		if join, ok := seb.pending[token]; ok && join {
+			delete(seb.pending, token)
+		} else {
+			seb.pending[token] = false
 		}
-	case string:
-		delete(seb.tokenlist, v)
 	}
+	window := seb.breeze.SubscribeBatchWindow
+	if window == 0 {
+		window = defaultSubscribeBatchWindow
+	}
+	if window < 0 {
+		return seb.flushLocked()
+	}
+	if len(seb.pending) > 0 && seb.flushTimer == nil {
+		seb.flushTimer = time.AfterFunc(window, seb.flush)
+	}
+	return nil
+}
+
+func (seb *SocketEventBreeze) flush() {
 	seb.mu.Lock()
-	defer seb.mu.Unlock()
-	toBeRemoved := []string{}
-	for room := range seb.ohlcstate {
-		if room == data {
-			toBeRemoved = append(toBeRemoved, room)
+	if err := seb.emitUnlock(seb.flushLocked()); err != nil {
+		log.Println("subscription flush error:", err)
+	}
+}
+
+// flushLocked takes the queued leaves and then the queued joins, each as a
+// single frame, so the server frees tokens before taking new ones. The
+// caller must hold seb.mu and emit the frames.
+func (seb *SocketEventBreeze) flushLocked() []socketFrame {
+	seb.flushTimer = nil
+	var joins, leaves []string
+	for token, join := range seb.pending {
+		if join {
+			joins = append(joins, token)
+		} else {
+			leaves = append(leaves, token)
 		}
 	}
-	for _, room := range toBeRemoved {
-		delete(seb.ohlcstate, room)
+	seb.pending = map[string]bool{}
+	sort.Strings(joins)
+	sort.Strings(leaves)
+
+	var frames []socketFrame
+	if len(leaves) > 0 {
+		frames = append(frames, socketFrame{"leave", leaves})
+	}
+	if len(joins) > 0 {
+		frames = append(frames, socketFrame{"join", joins})
 	}
-	seb.conn.Write(seb.ctx, websocket.MessageText, []byte(`{"type": "leave", "data": "`+fmt.Sprintf("%v", data)+`"}`))
+	return frames
+}
+
+// Subscriptions returns the reference count of every token and OHLC room
+// this connection holds.
+func (seb *SocketEventBreeze) Subscriptions() (tokens, rooms map[string]int) {
+	seb.mu.Lock()
+	defer seb.mu.Unlock()
+	return seb.tokenlist.copy(), seb.ohlcstate.copy()
 }
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+// fakeSocketServer is a local Socket.IO server speaking Engine.IO v4 over
+// websockets. It completes the handshake for the root namespace, records
+// the auth payload and every frame clients send afterwards, and can push
+// frames to or drop its clients.
+type fakeSocketServer struct {
+	// PingInterval and PingTimeout go into the open packet; they default
+	// to 25s and 20s.
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+	// Refuse, when set, answers the namespace connect with a
+	// connect_error carrying this message.
+	Refuse string
+
+	mu       sync.Mutex
+	reject   int
+	conns    []*websocket.Conn
+	frames   []string
+	auths    []string
+	connects chan struct{}
+	srv      *httptest.Server
+}
+
+func newFakeSocketServer(t *testing.T) *fakeSocketServer {
+	t.Helper()
+	f := &fakeSocketServer{
+		PingInterval: 25 * time.Second,
+		PingTimeout:  20 * time.Second,
+		connects:     make(chan struct{}, 16),
+	}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(func() {
+		f.drop()
+		f.srv.Close()
+	})
+	return f
+}
+
+func (f *fakeSocketServer) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	if f.reject > 0 {
+		f.reject--
+		f.mu.Unlock()
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	f.mu.Unlock()
+
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	open := fmt.Sprintf(`0{"sid":"eio","upgrades":[],"pingInterval":%d,"pingTimeout":%d,"maxPayload":1000000}`,
+		f.PingInterval.Milliseconds(), f.PingTimeout.Milliseconds())
+	if c.Write(ctx, websocket.MessageText, []byte(open)) != nil {
+		return
+	}
+	_, connect, err := c.Read(ctx)
+	if err != nil || !strings.HasPrefix(string(connect), "40") {
+		c.Close(websocket.StatusProtocolError, "expected namespace connect")
+		return
+	}
+	f.mu.Lock()
+	f.auths = append(f.auths, strings.TrimPrefix(string(connect), "40"))
+	refuse := f.Refuse
+	f.mu.Unlock()
+	if refuse != "" {
+		c.Write(ctx, websocket.MessageText, []byte(`44{"message":"`+refuse+`"}`))
+		c.Close(websocket.StatusNormalClosure, "")
+		return
+	}
+	if c.Write(ctx, websocket.MessageText, []byte(`40{"sid":"nsp"}`)) != nil {
+		return
+	}
+
+	f.mu.Lock()
+	f.conns = append(f.conns, c)
+	f.mu.Unlock()
+	f.connects <- struct{}{}
+	for {
+		_, data, err := c.Read(ctx)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.frames = append(f.frames, string(data))
+		f.mu.Unlock()
+	}
+}
+
+// rejectNext makes the next n connection attempts fail with 503.
+func (f *fakeSocketServer) rejectNext(n int) {
+	f.mu.Lock()
+	f.reject = n
+	f.mu.Unlock()
+}
+
+// waitConnect waits for a client to complete the namespace connect.
+func (f *fakeSocketServer) waitConnect(t *testing.T) {
+	t.Helper()
+	select {
+	case <-f.connects:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no client connected")
+	}
+}
+
+// send writes a raw Engine.IO packet to every connected client.
+func (f *fakeSocketServer) send(packet string) {
+	f.mu.Lock()
+	conns := append([]*websocket.Conn(nil), f.conns...)
+	f.mu.Unlock()
+	for _, c := range conns {
+		c.Write(context.Background(), websocket.MessageText, []byte(packet))
+	}
+}
+
+// drop closes every client connection.
+func (f *fakeSocketServer) drop() {
+	f.mu.Lock()
+	conns := f.conns
+	f.conns = nil
+	f.mu.Unlock()
+	for _, c := range conns {
+		c.Close(websocket.StatusGoingAway, "server restart")
+	}
+}
+
+// waitFrame waits for a frame satisfying match and returns it.
+func (f *fakeSocketServer) waitFrame(t *testing.T, match func(string) bool) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		for i, frame := range f.frames {
+			if match(frame) {
+				f.frames = append(f.frames[:i:i], f.frames[i+1:]...)
+				f.mu.Unlock()
+				return frame
+			}
+		}
+		f.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("expected frame never arrived")
+	return ""
+}
+
+// clear forgets the frames received so far.
+func (f *fakeSocketServer) clear() {
+	f.mu.Lock()
+	f.frames = nil
+	f.mu.Unlock()
+}
+
+type reconnectAttempt struct {
+	attempt int
+	delay   time.Duration
+}
+
+func TestReconnectPolicyBackoff(t *testing.T) {
+	p := ReconnectPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		full    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{40, time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			d := p.backoff(tt.attempt)
+			if d < tt.full/2 || d > tt.full {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", tt.attempt, d, tt.full/2, tt.full)
+			}
+		}
+	}
+}
+
+func TestSocketReconnectsAndResubscribes(t *testing.T) {
+	server := newFakeSocketServer(t)
+	b := NewBreezeInstance("key")
+	b.LiveStreamURL = server.srv.URL
+	b.SubscribeBatchWindow = -1
+	b.Reconnect = ReconnectPolicy{BaseDelay: 20 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	attempts := make(chan reconnectAttempt, 16)
+	b.OnReconnecting = func(hostname string, attempt int, delay time.Duration) {
+		attempts <- reconnectAttempt{attempt, delay}
+	}
+	ticks := make(chan map[string]interface{}, 4)
+	b.OnTicks = func(tick map[string]interface{}) { ticks <- tick }
+
+	if err := b.WSConnect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.WSDisconnect()
+	server.waitConnect(t)
+	for _, token := range []string{"4.1!2885", "4.1!1594"} {
+		if _, err := b.SubscribeFeeds(token, "", "", "", "", "", "", "", true, false, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.waitFrame(t, func(s string) bool { return strings.Contains(s, `"4.1!1594"`) })
+	server.clear()
+
+	// Fail the first two redials so the backoff is exercised.
+	server.rejectNext(2)
+	server.drop()
+	server.waitConnect(t)
+
+	rejoin := server.waitFrame(t, func(s string) bool { return strings.HasPrefix(s, `42["join"`) })
+	if rejoin != `42["join",["4.1!1594","4.1!2885"]]` {
+		t.Errorf("resubscription = %s, want both tokens in one join", rejoin)
+	}
+
+	bounds := []struct{ lo, hi time.Duration }{
+		{10 * time.Millisecond, 20 * time.Millisecond},
+		{20 * time.Millisecond, 40 * time.Millisecond},
+		{25 * time.Millisecond, 50 * time.Millisecond},
+	}
+	for i, want := range bounds {
+		select {
+		case got := <-attempts:
+			if got.attempt != i+1 || got.delay < want.lo || got.delay > want.hi {
+				t.Errorf("attempt %d: got attempt %d after %v, want delay within [%v, %v]", i+1, got.attempt, got.delay, want.lo, want.hi)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("reconnect attempt %d not reported", i+1)
+		}
+	}
+	select {
+	case got := <-attempts:
+		t.Errorf("unexpected reconnect attempt %d", got.attempt)
+	default:
+	}
+
+	// The replacement connection delivers ticks.
+	server.send(`42["stock",["4.1!2885",1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,1700000000,20]]`)
+	select {
+	case tick := <-ticks:
+		if tick["symbol"] != "4.1!2885" {
+			t.Errorf("tick symbol = %v", tick["symbol"])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no tick after reconnecting")
+	}
+}
+
+func TestSocketReconnectGivesUp(t *testing.T) {
+	server := newFakeSocketServer(t)
+	b := NewBreezeInstance("key")
+	b.LiveStreamURL = server.srv.URL
+	b.Reconnect = ReconnectPolicy{BaseDelay: 5 * time.Millisecond, MaxDelay: 10 * time.Millisecond, MaxAttempts: 3}
+	var mu sync.Mutex
+	tried := 0
+	b.OnReconnecting = func(string, int, time.Duration) {
+		mu.Lock()
+		tried++
+		mu.Unlock()
+	}
+	disconnected := make(chan error, 1)
+	b.OnDisconnected = func(hostname string, err error) { disconnected <- err }
+
+	if err := b.WSConnect(); err != nil {
+		t.Fatal(err)
+	}
+	server.waitConnect(t)
+	server.rejectNext(100)
+	server.drop()
+
+	select {
+	case err := <-disconnected:
+		if err == nil {
+			t.Error("OnDisconnected reported no error after giving up")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the socket never gave up")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if tried != 3 {
+		t.Errorf("%d reconnect attempts, want 3", tried)
+	}
+}
+
+func TestSocketDisconnectDoesNotReconnect(t *testing.T) {
+	server := newFakeSocketServer(t)
+	b := NewBreezeInstance("key")
+	b.LiveStreamURL = server.srv.URL
+	b.OnReconnecting = func(string, int, time.Duration) { t.Error("reconnecting after WSDisconnect") }
+	disconnected := make(chan error, 1)
+	b.OnDisconnected = func(hostname string, err error) { disconnected <- err }
+
+	if err := b.WSConnect(); err != nil {
+		t.Fatal(err)
+	}
+	server.waitConnect(t)
+	b.WSDisconnect()
+
+	select {
+	case err := <-disconnected:
+		if err != nil {
+			t.Errorf("OnDisconnected error = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnDisconnected not called")
+	}
+}
+
+func TestRefCounts(t *testing.T) {
+	r := refCounts{}
+	if got := r.acquire([]string{"a", "b"}); strings.Join(got, ",") != "a,b" {
+		t.Errorf("first acquire joined %v, want a,b", got)
+	}
+	if got := r.acquire([]string{"b", "c"}); strings.Join(got, ",") != "c" {
+		t.Errorf("second acquire joined %v, want c", got)
+	}
+	if got := r.release([]string{"b", "x"}); len(got) != 0 {
+		t.Errorf("releasing one of two holders left %v", got)
+	}
+	if got := r.release([]string{"a", "b", "b"}); strings.Join(got, ",") != "a,b" {
+		t.Errorf("releasing the last holders left %v, want a,b", got)
+	}
+	if got := r.copy(); len(got) != 1 || got["c"] != 1 {
+		t.Errorf("counts = %v, want only c", got)
+	}
+}
+
+func TestSharedTokenLeavesOnLastHolder(t *testing.T) {
+	server, b := connectTestFeed(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := b.SubscribeFeeds("4.1!2885", "", "", "", "", "", "", "", true, false, false); err != nil {
+		t.Fatal(err)
+	}
+	ch, err := b.Subscribe(ctx, "4.1!2885")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.waitFrame(t, func(s string) bool { return s == `42["join",["4.1!2885"]]` })
+	if tokens, _ := b.SIORateRefreshHandler.Subscriptions(); tokens["4.1!2885"] != 2 {
+		t.Fatalf("holders = %d, want 2", tokens["4.1!2885"])
+	}
+
+	// The channel going away leaves the callback subscription joined. The
+	// channel closes before its tokens are released, so wait for the count.
+	cancel()
+	for range ch {
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		tokens, _ := b.SIORateRefreshHandler.Subscriptions()
+		if tokens["4.1!2885"] == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("holders after cancel = %d, want 1", tokens["4.1!2885"])
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := b.UnsubscribeFeeds("4.1!2885", "", "", "", "", "", "", "", true, false, false); err != nil {
+		t.Fatal(err)
+	}
+	server.waitFrame(t, func(s string) bool { return strings.HasPrefix(s, `42["leave"`) })
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, frame := range server.frames {
+		if strings.Contains(frame, "leave") {
+			t.Errorf("second leave frame %s", frame)
+		}
+	}
+}
+
+func TestSubscribeRollsBackOnFailure(t *testing.T) {
+	server, b := connectTestFeed(t)
+	b.MaxFeedTokens = 2
+	if _, err := b.SubscribeFeeds("4.1!2885", "", "", "", "", "", "", "", true, false, false); err != nil {
+		t.Fatal(err)
+	}
+	server.waitFrame(t, func(s string) bool { return s == `42["join",["4.1!2885"]]` })
+
+	ch, err := b.Subscribe(context.Background(), "4.1!1594", "4.1!2885", "4.1!1660")
+	if !errors.Is(err, ErrFeedLimit) || ch != nil {
+		t.Fatalf("Subscribe past the limit = %v, %v; want ErrFeedLimit", ch, err)
+	}
+	server.waitFrame(t, func(s string) bool { return s == `42["join",["4.1!1594"]]` })
+	server.waitFrame(t, func(s string) bool { return s == `42["leave",["4.1!1594"]]` })
+
+	// Only the tokens the failed call joined are released; the earlier
+	// holder of 2885 keeps it.
+	tokens, _ := b.SIORateRefreshHandler.Subscriptions()
+	if len(tokens) != 1 || tokens["4.1!2885"] != 1 {
+		t.Errorf("tokens after rollback = %v, want 2885 held once", tokens)
+	}
+	if b.ticks.active() {
+		t.Error("failed Subscribe left a subscriber behind")
+	}
+}
+
+// framesAfter waits d and returns the join and leave frames received so
+// far, in order.
+func (f *fakeSocketServer) framesAfter(d time.Duration) []string {
+	time.Sleep(d)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, frame := range f.frames {
+		if strings.HasPrefix(frame, `42["join"`) || strings.HasPrefix(frame, `42["leave"`) {
+			out = append(out, frame)
+		}
+	}
+	return out
+}
+
+func TestWatchBatchWindow(t *testing.T) {
+	server, b := connectTestFeed(t)
+	b.SubscribeBatchWindow = 40 * time.Millisecond
+	seb := b.SIORateRefreshHandler
+
+	for _, token := range []string{"4.1!2885", "4.1!1594", "4.1!1660"} {
+		if err := seb.Watch(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seb.Unwatch("4.1!1660")
+	if got := server.framesAfter(10 * time.Millisecond); len(got) != 0 {
+		t.Fatalf("frames sent inside the window: %v", got)
+	}
+	got := server.framesAfter(100 * time.Millisecond)
+	want := []string{`42["join",["4.1!1594","4.1!2885"]]`}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("frames = %v, want %v", got, want)
+	}
+}
+
+func TestWatchLeavesBeforeJoins(t *testing.T) {
+	server, b := connectTestFeed(t)
+	seb := b.SIORateRefreshHandler
+	if err := seb.Watch([]string{"4.1!2885", "4.1!1594"}); err != nil {
+		t.Fatal(err)
+	}
+	server.waitFrame(t, func(s string) bool { return strings.HasPrefix(s, `42["join"`) })
+
+	b.SubscribeBatchWindow = 40 * time.Millisecond
+	if err := seb.Watch("4.1!1660"); err != nil {
+		t.Fatal(err)
+	}
+	seb.Unwatch([]string{"4.1!2885", "4.1!1594"})
+	// Re-taking a token left inside the same window cancels the leave.
+	if err := seb.Watch("4.1!1594"); err != nil {
+		t.Fatal(err)
+	}
+	got := server.framesAfter(100 * time.Millisecond)
+	want := []string{`42["leave",["4.1!2885"]]`, `42["join",["4.1!1660"]]`}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("frames = %v, want %v", got, want)
+	}
+}
+
+func TestWatchFeedLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		held      int
+		request   []string
+		wantErr   bool
+		requested int
+	}{
+		{"within the limit", 3, 2, []string{"c"}, false, 0},
+		{"past the limit", 3, 2, []string{"c", "d"}, true, 2},
+		{"held tokens are free", 2, 2, []string{"t0", "t1"}, false, 0},
+		{"duplicates count once", 3, 2, []string{"c", "c"}, false, 0},
+		{"default limit", 0, 2000, []string{"c"}, true, 1},
+		{"no limit", -1, 2000, []string{"c"}, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreezeInstance("key")
+			b.MaxFeedTokens = tt.limit
+			seb := NewSocketEventBreeze("", b)
+			for i := 0; i < tt.held; i++ {
+				seb.tokenlist[fmt.Sprintf("t%d", i)] = 1
+			}
+			err := seb.checkFeedLimit(tt.request)
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("checkFeedLimit = %v, want nil", err)
+				}
+				return
+			}
+			var limitErr *FeedLimitError
+			if !errors.As(err, &limitErr) || !errors.Is(err, ErrFeedLimit) {
+				t.Fatalf("checkFeedLimit = %v, want a FeedLimitError", err)
+			}
+			limit := tt.limit
+			if limit == 0 {
+				limit = defaultMaxFeedTokens
+			}
+			if limitErr.Limit != limit || limitErr.Held != tt.held || limitErr.Requested != tt.requested {
+				t.Errorf("FeedLimitError = %+v, want limit %d, held %d, requested %d", limitErr, limit, tt.held, tt.requested)
+			}
+		})
+	}
+}
+
+func TestWatchFeedLimitTakesNothing(t *testing.T) {
+	server, b := connectTestFeed(t)
+	b.MaxFeedTokens = 2
+	seb := b.SIORateRefreshHandler
+	if err := seb.Watch("4.1!2885"); err != nil {
+		t.Fatal(err)
+	}
+	if err := seb.Watch([]string{"4.1!1594", "4.1!1660"}); !errors.Is(err, ErrFeedLimit) {
+		t.Fatalf("Watch past the limit: error = %v, want ErrFeedLimit", err)
+	}
+	if tokens, _ := seb.Subscriptions(); len(tokens) != 1 {
+		t.Errorf("tokens after a refused Watch = %v", tokens)
+	}
+	if got := server.framesAfter(20 * time.Millisecond); len(got) != 1 {
+		t.Errorf("frames = %v, want only the first join", got)
+	}
+}
+
+func TestRewatchAfterReconnect(t *testing.T) {
+	server, b := connectTestFeed(t)
+	seb := b.SIORateRefreshHandler
+	if err := seb.Watch([]string{"4.1!2885", "4.1!1594"}); err != nil {
+		t.Fatal(err)
+	}
+	server.waitFrame(t, func(s string) bool { return strings.HasPrefix(s, `42["join"`) })
+	b.SubscribeBatchWindow = time.Hour
+	seb.Unwatch("4.1!2885")
+
+	// The pending leave is already reflected in tokenlist, so Rewatch
+	// joins only what is still held and drops the queued frame.
+	seb.Rewatch()
+	if got := server.framesAfter(20 * time.Millisecond); strings.Join(got, " ") != `42["join",["4.1!1594"]]` {
+		t.Errorf("frames = %v", got)
+	}
+	seb.mu.Lock()
+	defer seb.mu.Unlock()
+	if len(seb.pending) != 0 {
+		t.Errorf("pending after Rewatch = %v", seb.pending)
+	}
+}
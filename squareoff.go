@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SquareOffRequest struct {
+	SourceFlag           string `json:"source_flag"`
+	StockCode            string `json:"stock_code"`
+	ExchangeCode         string `json:"exchange_code"`
+	Quantity             string `json:"quantity"`
+	Price                string `json:"price"`
+	Action               string `json:"action"`
+	OrderType            string `json:"order_type"`
+	Validity             string `json:"validity"`
+	Stoploss             string `json:"stoploss_price"`
+	DisclosedQuantity    string `json:"disclosed_quantity"`
+	ProtectionPercentage string `json:"protection_percentage"`
+	SettlementID         string `json:"settlement_id"`
+	MarginAmount         string `json:"margin_amount"`
+	OpenQuantity         string `json:"open_quantity"`
+	CoverQuantity        string `json:"cover_quantity"`
+	ProductType          string `json:"product_type"`
+	ExpiryDate           string `json:"expiry_date"`
+	Right                string `json:"right"`
+	StrikePrice          string `json:"strike_price"`
+	ValidityDate         string `json:"validity_date"`
+	TradePassword        string `json:"trade_password"`
+	AliasName            string `json:"alias_name"`
+}
+
+func (r *SquareOffRequest) validate() error {
+	if r.StockCode == "" || r.ExchangeCode == "" || r.Quantity == "" || r.Action == "" || r.OrderType == "" {
+		return &ValidationError{Message: "Stock code, exchange code, quantity, action or order type cannot be empty"}
+	}
+	r.Action = strings.ToLower(r.Action)
+	r.OrderType = strings.ToLower(r.OrderType)
+	r.ProductType = strings.ToLower(r.ProductType)
+	r.Right = strings.ToLower(r.Right)
+	if !contains(ACTION_TYPES, r.Action) {
+		return &ValidationError{Message: "Action should be one of " + strings.Join(ACTION_TYPES, ", ")}
+	}
+	if r.OrderType != "market" && r.OrderType != "limit" {
+		return &ValidationError{Message: "Order type should be market or limit for square off"}
+	}
+	if r.OrderType == "limit" && r.Price == "" {
+		return &ValidationError{Message: "Price cannot be empty for a limit order"}
+	}
+	return nil
+}
+
+// SquareOff closes an open position with an order in the opposite direction.
+func (a *ApificationBreeze) SquareOff(req SquareOffRequest) (*OrderAck, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	return callAPI[*OrderAck](a, "POST", "/"+string(SQUARE_OFF), req)
+}
+
+const (
+	defaultFlattenConcurrency  = 4
+	defaultFlattenPollInterval = time.Second
+)
+
+// FlattenOptions controls FlattenAll. With OrderType "limit" each exit is
+// priced Slippage (a fraction, 0.01 for 1%) through the LTP so it crosses
+// the spread; a position with no LTP to price from is not sent and is
+// reported as Open with status "skipped". An exit the API refused is
+// Rejected; one lost to a transport error may or may not have reached the
+// exchange, so it is reported as Open with status "unknown". WaitTimeout
+// bounds how long FlattenAll polls the order book for fills; zero reports
+// the orders as sent.
+type FlattenOptions struct {
+	OrderType    string
+	Slippage     float64
+	TickSize     float64
+	Concurrency  int
+	WaitTimeout  time.Duration
+	PollInterval time.Duration
+}
+
+type FlattenResult struct {
+	Position PortfolioPosition
+	OrderID  string
+	Status   string
+	Err      error
+}
+
+type FlattenReport struct {
+	Filled   []FlattenResult
+	Rejected []FlattenResult
+	Open     []FlattenResult
+}
+
+// FlattenAll squares off every open position. Orders go out at most
+// Concurrency at a time; the report sorts them by their final status.
+func (a *ApificationBreeze) FlattenAll(opts FlattenOptions) (*FlattenReport, error) {
+	opts.OrderType = strings.ToLower(opts.OrderType)
+	if opts.OrderType == "" {
+		opts.OrderType = "market"
+	}
+	if opts.OrderType != "market" && opts.OrderType != "limit" {
+		return nil, NewValidationError("Order type should be market or limit")
+	}
+	if opts.Slippage < 0 {
+		return nil, NewValidationError("Slippage cannot be negative")
+	}
+	if opts.TickSize <= 0 {
+		opts.TickSize = 0.05
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultFlattenConcurrency
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultFlattenPollInterval
+	}
+
+	positions, err := a.GetPortfolioPositions()
+	if err != nil {
+		return nil, err
+	}
+	open := []PortfolioPosition{}
+	for _, p := range positions {
+		if p.Quantity > 0 {
+			open = append(open, p)
+		}
+	}
+
+	results := make([]FlattenResult, len(open))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, opts.Concurrency)
+	for i, p := range open {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, p PortfolioPosition) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = a.flattenPosition(p, opts)
+		}(i, p)
+	}
+	wg.Wait()
+
+	report := &FlattenReport{}
+	for _, r := range results {
+		switch r.Status {
+		case "executed":
+			report.Filled = append(report.Filled, r)
+		case "rejected", "cancelled", "expired":
+			report.Rejected = append(report.Rejected, r)
+		default:
+			report.Open = append(report.Open, r)
+		}
+	}
+	return report, nil
+}
+
+func (a *ApificationBreeze) flattenPosition(p PortfolioPosition, opts FlattenOptions) FlattenResult {
+	result := FlattenResult{Position: p}
+	action := "sell"
+	if p.NetQuantity() < 0 {
+		action = "buy"
+	}
+	req := SquareOffRequest{
+		SourceFlag:    "P",
+		StockCode:     p.StockCode,
+		ExchangeCode:  p.ExchangeCode,
+		Quantity:      strconv.FormatInt(int64(p.Quantity), 10),
+		Action:        action,
+		OrderType:     opts.OrderType,
+		Validity:      "day",
+		SettlementID:  p.SettlementID,
+		MarginAmount:  strconv.FormatFloat(float64(p.MarginAmount), 'f', -1, 64),
+		OpenQuantity:  strconv.FormatInt(int64(p.Quantity), 10),
+		CoverQuantity: strconv.FormatInt(int64(p.CoverQuantity), 10),
+		ProductType:   p.ProductType,
+		ExpiryDate:    p.ExpiryDate,
+		Right:         p.Right,
+		StrikePrice:   p.StrikePrice,
+	}
+	if opts.OrderType == "limit" {
+		price, err := slippagePrice(float64(p.LTP), action, opts.Slippage, opts.TickSize)
+		if err != nil {
+			result.Status = "skipped"
+			result.Err = err
+			return result
+		}
+		req.Price = strconv.FormatFloat(price, 'f', -1, 64)
+	}
+
+	ack, err := a.SquareOff(req)
+	if err != nil {
+		var apiErr *APIError
+		switch {
+		case errors.As(err, &apiErr):
+			result.Status = "rejected"
+		case errors.Is(err, ErrValidation):
+			result.Status = "skipped"
+		default:
+			result.Status = "unknown"
+		}
+		result.Err = err
+		return result
+	}
+	result.OrderID = ack.OrderID
+	result.Status = "ordered"
+	if opts.WaitTimeout <= 0 {
+		return result
+	}
+
+	deadline := time.Now().Add(opts.WaitTimeout)
+	for {
+		details, err := a.GetOrderDetailTyped(p.ExchangeCode, ack.OrderID)
+		if err == nil && len(details) > 0 {
+			result.Status = strings.ToLower(details[0].Status)
+			switch result.Status {
+			case "executed", "rejected", "cancelled", "expired":
+				return result
+			}
+		}
+		if !time.Now().Add(opts.PollInterval).Before(deadline) {
+			result.Err = err
+			return result
+		}
+		time.Sleep(opts.PollInterval)
+	}
+}
+
+// slippagePrice moves ltp against the order by slippage and rounds it to a
+// tick in the same direction, so a sell is never priced above ltp(1-s).
+// Without a positive ltp there is nothing to price from.
+func slippagePrice(ltp float64, action string, slippage, tick float64) (float64, error) {
+	if ltp <= 0 {
+		return 0, &ValidationError{Message: "No last traded price to price a limit exit from"}
+	}
+	if action == "buy" {
+		ticks := math.Ceil(ltp*(1+slippage)/tick - 1e-9)
+		return roundToTick(ticks, tick), nil
+	}
+	ticks := math.Max(math.Floor(ltp*(1-slippage)/tick+1e-9), 1)
+	return roundToTick(ticks, tick), nil
+}
+
+// roundToTick returns ticks*tick with the float error trimmed at the
+// tick's own precision, so a 0.0025 tick keeps its fourth decimal.
+func roundToTick(ticks, tick float64) float64 {
+	decimals := 0
+	if s := strconv.FormatFloat(tick, 'f', -1, 64); strings.Contains(s, ".") {
+		decimals = len(s) - strings.Index(s, ".") - 1
+	}
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(ticks*tick*scale) / scale
+}
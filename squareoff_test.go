@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSlippagePrice(t *testing.T) {
+	tests := []struct {
+		ltp      float64
+		action   string
+		slippage float64
+		tick     float64
+		want     float64
+	}{
+		{100, "sell", 0.01, 0.05, 99},
+		{100, "buy", 0.01, 0.05, 101},
+		{101.23, "sell", 0.005, 0.05, 100.7},
+		{101.23, "buy", 0.005, 0.05, 101.75},
+		{0.1, "sell", 0.9, 0.05, 0.05},
+		{250, "sell", 0, 0.05, 250},
+		{82.3456, "sell", 0.001, 0.0025, 82.2625},
+		{82.3456, "buy", 0.0005, 0.0025, 82.3875},
+		{0.001, "sell", 0.5, 0.0025, 0.0025},
+		{1234.56, "buy", 0.01, 1, 1247},
+	}
+	for _, tt := range tests {
+		got, err := slippagePrice(tt.ltp, tt.action, tt.slippage, tt.tick)
+		if err != nil || got != tt.want {
+			t.Errorf("slippagePrice(%v, %s, %v, %v) = %v, %v; want %v", tt.ltp, tt.action, tt.slippage, tt.tick, got, err, tt.want)
+		}
+	}
+	for _, ltp := range []float64{0, -1} {
+		if _, err := slippagePrice(ltp, "sell", 0.01, 0.05); !errors.Is(err, ErrValidation) {
+			t.Errorf("slippagePrice with ltp %v: error = %v, want ErrValidation", ltp, err)
+		}
+	}
+}
+
+func TestFlattenAllSkipsUnpricedLimitExit(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	f.respond(http.StatusOK, `{"Success":[{"stock_code":"NIFTY","exchange_code":"NFO","product_type":"options","action":"Buy","quantity":"50","ltp":"0"}],"Status":200,"Error":null}`)
+
+	report, err := a.FlattenAll(FlattenOptions{OrderType: "limit", Slippage: 0.01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Open) != 1 || len(report.Filled) != 0 || len(report.Rejected) != 0 {
+		t.Fatalf("report = %+v, want the one position open", report)
+	}
+	if r := report.Open[0]; r.Status != "skipped" || !errors.Is(r.Err, ErrValidation) || r.OrderID != "" {
+		t.Errorf("result = %s, %v, %q; want skipped with a validation error and no order", r.Status, r.Err, r.OrderID)
+	}
+	if calls := f.received(); len(calls) != 1 {
+		t.Errorf("%d requests, want only the positions lookup", len(calls))
+	}
+}
+
+func TestFlattenAllStatus(t *testing.T) {
+	const position = `{"Success":[{"stock_code":"ITC","exchange_code":"NSE","product_type":"margin","action":"Buy","quantity":"10","ltp":"420.5"}],"Status":200,"Error":null}`
+	const ordered = `{"Success":{"order_id":"7","message":"Successfully Placed the order"},"Status":200,"Error":null}`
+	detail := func(status string) string {
+		return `{"Success":[{"order_id":"7","status":"` + status + `"}],"Status":200,"Error":null}`
+	}
+	tests := []struct {
+		name      string
+		squareOff apiReply
+		detail    apiReply
+		status    string
+		bucket    string
+		wantErr   error
+	}{
+		{"filled", apiReply{http.StatusOK, ordered}, apiReply{http.StatusOK, detail("Executed")}, "executed", "filled", nil},
+		{"cancelled", apiReply{http.StatusOK, ordered}, apiReply{http.StatusOK, detail("Cancelled")}, "cancelled", "rejected", nil},
+		{"expired", apiReply{http.StatusOK, ordered}, apiReply{http.StatusOK, detail("Expired")}, "expired", "rejected", nil},
+		{"still open", apiReply{http.StatusOK, ordered}, apiReply{http.StatusOK, detail("Ordered")}, "ordered", "open", nil},
+		{"refused by the API", apiReply{http.StatusOK, `{"Success":null,"Status":500,"Error":"Insufficient quantity"}`}, apiReply{}, "rejected", "rejected", ErrAPI},
+		{"connection lost", apiReply{}, apiReply{}, "unknown", "open", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, a := newFakeBreezeAPI(t)
+			f.route("GET /portfoliopositions", http.StatusOK, position)
+			f.route("POST /squareoff", tt.squareOff.Status, tt.squareOff.Body)
+			f.route("GET /order", tt.detail.Status, tt.detail.Body)
+
+			report, err := a.FlattenAll(FlattenOptions{WaitTimeout: 30 * time.Millisecond, PollInterval: 10 * time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+			buckets := map[string][]FlattenResult{"filled": report.Filled, "rejected": report.Rejected, "open": report.Open}
+			for name, results := range buckets {
+				want := 0
+				if name == tt.bucket {
+					want = 1
+				}
+				if len(results) != want {
+					t.Fatalf("%s = %+v, want %d result", name, results, want)
+				}
+			}
+			r := buckets[tt.bucket][0]
+			if r.Status != tt.status {
+				t.Errorf("status = %q, want %q", r.Status, tt.status)
+			}
+			if tt.wantErr != nil && !errors.Is(r.Err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", r.Err, tt.wantErr)
+			}
+			if tt.status == "unknown" && r.Err == nil {
+				t.Error("unknown status without the transport error")
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Strategy is a multi-leg one-click F&O strategy, assembled from the legs
+// that share its portfolio ID. Legs are ordered by leg number.
+type Strategy struct {
+	PortfolioID   string
+	PortfolioName string
+	Underlying    string
+	ExchangeCode  string
+	Status        string
+	Legs          []StrategyLeg
+	UpdatedAt     time.Time
+}
+
+// StrategyFeed delivers the strategy streams in STRATEGY_SUBSCRIPTION as
+// typed values. OnRecommendation receives each i_click_2_gain call;
+// OnStrategy receives the whole strategy every time one of its legs
+// arrives or changes.
+type StrategyFeed struct {
+	OnRecommendation func(Recommendation)
+	OnStrategy       func(Strategy)
+
+	breeze     *BreezeInstance
+	mu         sync.Mutex
+	strategies map[string]*Strategy
+	detach     func()
+}
+
+// EnableStrategyFeed hooks a StrategyFeed into the decoded ticks. Call
+// Subscribe to start the streams.
+func (b *BreezeInstance) EnableStrategyFeed() *StrategyFeed {
+	f := &StrategyFeed{breeze: b, strategies: map[string]*Strategy{}}
+	f.detach = b.addTypedTickHandler(f.apply)
+	return f
+}
+
+// Stop detaches the feed from the tick stream. It does not leave the
+// streams; call Unsubscribe for that.
+func (f *StrategyFeed) Stop() {
+	if f.detach != nil {
+		f.detach()
+	}
+}
+
+// Subscribe joins the given strategy streams, all of STRATEGY_SUBSCRIPTION
+// when none are named. If a stream cannot be joined, the ones this call
+// already joined are left again.
+func (f *StrategyFeed) Subscribe(streams ...string) error {
+	if len(streams) == 0 {
+		streams = STRATEGY_SUBSCRIPTION
+	}
+	for _, stream := range streams {
+		if !contains(STRATEGY_SUBSCRIPTION, stream) {
+			return &ValidationError{Message: "Strategy stream should be one of " + strings.Join(STRATEGY_SUBSCRIPTION, ", ")}
+		}
+	}
+	if f.breeze.SIORateRefreshHandler == nil {
+		return &NotConnectedError{Message: "LIVESTREAM_SOCKET_CONNECTION_DISCONNECTED"}
+	}
+	for i, stream := range streams {
+		if _, err := f.breeze.SubscribeFeeds(stream, "", "", "", "", "", "", "", false, false, false); err != nil {
+			if i > 0 {
+				f.Unsubscribe(streams[:i]...)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *StrategyFeed) Unsubscribe(streams ...string) error {
+	if len(streams) == 0 {
+		streams = STRATEGY_SUBSCRIPTION
+	}
+	for _, stream := range streams {
+		if _, err := f.breeze.UnsubscribeFeeds(stream, "", "", "", "", "", "", "", false, false, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *StrategyFeed) apply(t *Tick) {
+	switch t.Kind {
+	case TickRecommendation:
+		if f.OnRecommendation != nil {
+			f.OnRecommendation(t.Recommendation)
+		}
+	case TickStrategy:
+		if s, ok := f.addLeg(t.Strategy); ok && f.OnStrategy != nil {
+			f.OnStrategy(s)
+		}
+	}
+}
+
+// addLeg files leg under its portfolio, replacing an earlier version of
+// the same leg, and returns a copy of the updated strategy.
+func (f *StrategyFeed) addLeg(leg StrategyLeg) (Strategy, bool) {
+	if leg.PortfolioID == "" {
+		return Strategy{}, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.strategies[leg.PortfolioID]
+	if !ok {
+		s = &Strategy{PortfolioID: leg.PortfolioID}
+		f.strategies[leg.PortfolioID] = s
+	}
+	s.PortfolioName = leg.PortfolioName
+	s.Underlying = leg.Underlying
+	s.ExchangeCode = leg.ExchangeCode
+	s.Status = leg.Status
+	s.UpdatedAt = time.Now()
+
+	replaced := false
+	for i := range s.Legs {
+		if s.Legs[i].LegNo == leg.LegNo {
+			s.Legs[i] = leg
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		s.Legs = append(s.Legs, leg)
+		sort.SliceStable(s.Legs, func(i, j int) bool { return legNumber(s.Legs[i].LegNo) < legNumber(s.Legs[j].LegNo) })
+	}
+	return s.copy(), true
+}
+
+func legNumber(legNo string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(legNo))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func (s *Strategy) copy() Strategy {
+	copied := *s
+	copied.Legs = append([]StrategyLeg(nil), s.Legs...)
+	return copied
+}
+
+func (f *StrategyFeed) Strategy(portfolioID string) (Strategy, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.strategies[portfolioID]
+	if !ok {
+		return Strategy{}, false
+	}
+	return s.copy(), true
+}
+
+// Strategies returns every strategy seen, most recently updated first.
+func (f *StrategyFeed) Strategies() []Strategy {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]Strategy, 0, len(f.strategies))
+	for _, s := range f.strategies {
+		out = append(out, s.copy())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out
+}
+
+// Basket turns the strategy into one limit order per leg for lots lots of
+// each. Buys are priced at the top of the recommended range and sells at
+// the bottom. The orders are validated but not placed.
+func (s Strategy) Basket(lots int) ([]OrderRequest, error) {
+	if lots <= 0 {
+		return nil, &ValidationError{Message: "Lots should be a positive number"}
+	}
+	if len(s.Legs) == 0 {
+		return nil, &ValidationError{Message: "Strategy " + s.PortfolioID + " has no legs"}
+	}
+	basket := make([]OrderRequest, 0, len(s.Legs))
+	for _, leg := range s.Legs {
+		req := OrderRequest{
+			StockCode:    leg.Underlying,
+			ExchangeCode: leg.ExchangeCode,
+			Product:      basketProduct(leg.ProductType, leg.ExpiryDate, leg.OptionType),
+			Action:       strings.ToLower(leg.Action),
+			OrderType:    "limit",
+			Quantity:     strconv.FormatInt(int64(lots)*leg.MinimumLotQuantity, 10),
+			Price:        basketPrice(leg.Action, leg.RecommendedPriceFrom, leg.RecommendedPriceTo),
+			Validity:     "day",
+			ExpiryDate:   leg.ExpiryDate,
+			Right:        basketRight(leg.OptionType),
+		}
+		if req.Product == "options" {
+			req.StrikePrice = strconv.FormatFloat(leg.StrikePrice, 'f', -1, 64)
+		}
+		if err := req.validate(); err != nil {
+			return nil, err
+		}
+		basket = append(basket, req)
+	}
+	return basket, nil
+}
+
+// Basket turns the recommendation into a limit order for quantity on
+// exchangeCode, priced like Strategy.Basket. Target and stop loss are left
+// to the caller.
+func (r Recommendation) Basket(exchangeCode string, quantity int) ([]OrderRequest, error) {
+	if quantity <= 0 {
+		return nil, &ValidationError{Message: "Quantity should be a positive number"}
+	}
+	req := OrderRequest{
+		StockCode:    r.StockCode,
+		ExchangeCode: exchangeCode,
+		Product:      basketProduct("", r.ExpiryDate, r.OptionType),
+		Action:       strings.ToLower(r.Action),
+		OrderType:    "limit",
+		Quantity:     strconv.Itoa(quantity),
+		Price:        basketPrice(r.Action, r.RecommendedPriceFrom, r.RecommendedPriceTo),
+		Validity:     "day",
+		ExpiryDate:   r.ExpiryDate,
+		Right:        basketRight(r.OptionType),
+	}
+	if req.Product == "options" {
+		req.StrikePrice = strconv.FormatFloat(r.StrikePrice, 'f', -1, 64)
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	return []OrderRequest{req}, nil
+}
+
+// basketProduct maps a stream's product label onto the order endpoint's,
+// falling back to the contract fields when the label is missing.
+func basketProduct(productType, expiryDate, optionType string) string {
+	switch p := strings.ToLower(productType); {
+	case strings.HasPrefix(p, "opt"):
+		return "options"
+	case strings.HasPrefix(p, "fut"):
+		return "futures"
+	case p != "":
+		return p
+	}
+	switch {
+	case basketRight(optionType) != "":
+		return "options"
+	case expiryDate != "":
+		return "futures"
+	}
+	return "cash"
+}
+
+func basketRight(optionType string) string {
+	switch strings.ToUpper(strings.TrimSpace(optionType)) {
+	case "CE", "C", "CALL":
+		return "call"
+	case "PE", "P", "PUT":
+		return "put"
+	}
+	return ""
+}
+
+func basketPrice(action string, from, to float64) string {
+	price := math.Min(from, to)
+	if strings.EqualFold(action, "buy") {
+		price = math.Max(from, to)
+	}
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
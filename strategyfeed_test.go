@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func strategyTick(portfolioID, legNo, action string, strike float64) *Tick {
+	return &Tick{Kind: TickStrategy, Strategy: StrategyLeg{
+		PortfolioID:          portfolioID,
+		PortfolioName:        "Bull Call Spread",
+		ExchangeCode:         "NFO",
+		ProductType:          "Options",
+		Underlying:           "NIFTY",
+		ExpiryDate:           "27-Jul-2023",
+		OptionType:           "CE",
+		StrikePrice:          strike,
+		Action:               action,
+		RecommendedPriceFrom: 110,
+		RecommendedPriceTo:   112.5,
+		MinimumLotQuantity:   50,
+		LegNo:                legNo,
+		Status:               "Open",
+	}}
+}
+
+// legSummary lists a strategy's legs as "legNo:action:strike".
+func legSummary(s Strategy) []string {
+	var out []string
+	for _, leg := range s.Legs {
+		out = append(out, fmt.Sprintf("%s:%s:%v", leg.LegNo, leg.Action, leg.StrikePrice))
+	}
+	return out
+}
+
+func TestStrategyFeedLegs(t *testing.T) {
+	b := NewBreezeInstance("key")
+	f := b.EnableStrategyFeed()
+	var updates []Strategy
+	var calls []Recommendation
+	f.OnStrategy = func(s Strategy) { updates = append(updates, s) }
+	f.OnRecommendation = func(r Recommendation) { calls = append(calls, r) }
+
+	dispatch := b.typedTickHandlers.dispatch
+	dispatch(strategyTick("P1", "2", "Sell", 19600))
+	dispatch(strategyTick("P1", "10", "Buy", 19700))
+	dispatch(strategyTick("P1", "1", "Buy", 19500))
+	dispatch(strategyTick("", "1", "Buy", 19500))
+	time.Sleep(2 * time.Millisecond)
+	dispatch(strategyTick("P2", "1", "Buy", 19400))
+	dispatch(&Tick{Kind: TickRecommendation, Recommendation: Recommendation{StockCode: "ITC", Action: "Buy"}})
+	dispatch(quoteTick("4.1!2885", 2500))
+
+	if len(updates) != 4 {
+		t.Fatalf("OnStrategy called %d times, want once per filed leg", len(updates))
+	}
+	if got, want := legSummary(updates[2]), []string{"1:Buy:19500", "2:Sell:19600", "10:Buy:19700"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("P1 legs = %v, want %v", got, want)
+	}
+	if len(calls) != 1 || calls[0].StockCode != "ITC" {
+		t.Errorf("OnRecommendation got %+v", calls)
+	}
+
+	// A leg arriving again replaces its earlier version in place, and the
+	// strategies handed out earlier are not touched.
+	leg := strategyTick("P1", "2", "Sell", 19650)
+	leg.Strategy.Status = "Closed"
+	dispatch(leg)
+	s, ok := f.Strategy("P1")
+	if !ok || s.Status != "Closed" {
+		t.Fatalf("Strategy(P1) = %+v, %v", s, ok)
+	}
+	if got, want := legSummary(s), []string{"1:Buy:19500", "2:Sell:19650", "10:Buy:19700"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("P1 legs after replace = %v, want %v", got, want)
+	}
+	if got := legSummary(updates[2])[1]; got != "2:Sell:19600" {
+		t.Errorf("an earlier update changed to %s", got)
+	}
+
+	strategies := f.Strategies()
+	if len(strategies) != 2 || strategies[0].PortfolioID != "P1" || strategies[1].PortfolioID != "P2" {
+		t.Errorf("Strategies = %v, want P1 then P2", strategies)
+	}
+	if _, ok := f.Strategy(""); ok {
+		t.Error("a leg without a portfolio ID was filed")
+	}
+
+	f.Stop()
+	dispatch(strategyTick("P3", "1", "Buy", 19500))
+	if _, ok := f.Strategy("P3"); ok || len(updates) != 5 {
+		t.Error("a stopped feed still files legs")
+	}
+}
+
+func TestStrategyBasket(t *testing.T) {
+	s := Strategy{PortfolioID: "P1"}
+	for _, tick := range []*Tick{
+		strategyTick("P1", "1", "Buy", 19500),
+		strategyTick("P1", "2", "Sell", 19600),
+	} {
+		s.Legs = append(s.Legs, tick.Strategy)
+	}
+	future := strategyTick("P1", "3", "Buy", 0).Strategy
+	future.ProductType, future.OptionType = "", ""
+	s.Legs = append(s.Legs, future)
+
+	basket, err := s.Basket(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OrderRequest{
+		{StockCode: "NIFTY", ExchangeCode: "NFO", Product: "options", Action: "buy", OrderType: "limit", Quantity: "100", Price: "112.5", Validity: "day", ExpiryDate: "27-Jul-2023", Right: "call", StrikePrice: "19500"},
+		{StockCode: "NIFTY", ExchangeCode: "NFO", Product: "options", Action: "sell", OrderType: "limit", Quantity: "100", Price: "110", Validity: "day", ExpiryDate: "27-Jul-2023", Right: "call", StrikePrice: "19600"},
+		{StockCode: "NIFTY", ExchangeCode: "NFO", Product: "futures", Action: "buy", OrderType: "limit", Quantity: "100", Price: "112.5", Validity: "day", ExpiryDate: "27-Jul-2023"},
+	}
+	if !reflect.DeepEqual(basket, want) {
+		t.Errorf("Basket =\n%+v\nwant\n%+v", basket, want)
+	}
+
+	noExpiry := s
+	noExpiry.Legs = append([]StrategyLeg(nil), s.Legs...)
+	noExpiry.Legs[1].ExpiryDate = ""
+	noAction := s
+	noAction.Legs = append([]StrategyLeg(nil), s.Legs...)
+	noAction.Legs[0].Action = ""
+	tests := []struct {
+		name     string
+		strategy Strategy
+		lots     int
+	}{
+		{"no lots", s, 0},
+		{"negative lots", s, -1},
+		{"no legs", Strategy{PortfolioID: "P1"}, 1},
+		{"leg without an expiry", noExpiry, 1},
+		{"leg without an action", noAction, 1},
+	}
+	for _, tt := range tests {
+		if basket, err := tt.strategy.Basket(tt.lots); !errors.Is(err, ErrValidation) || basket != nil {
+			t.Errorf("%s: Basket = %v, %v; want ErrValidation", tt.name, basket, err)
+		}
+	}
+}
+
+func TestRecommendationBasket(t *testing.T) {
+	tests := []struct {
+		name string
+		rec  Recommendation
+		want OrderRequest
+	}{
+		{"cash buy",
+			Recommendation{StockCode: "ITC", Action: "Buy", RecommendedPriceFrom: 450, RecommendedPriceTo: 452.35},
+			OrderRequest{StockCode: "ITC", ExchangeCode: "NSE", Product: "cash", Action: "buy", OrderType: "limit", Quantity: "10", Price: "452.35", Validity: "day"}},
+		{"option sell",
+			Recommendation{StockCode: "NIFTY", Action: "SELL", ExpiryDate: "27-Jul-2023", StrikePrice: 19500, OptionType: "PE", RecommendedPriceFrom: 82, RecommendedPriceTo: 80.5},
+			OrderRequest{StockCode: "NIFTY", ExchangeCode: "NSE", Product: "options", Action: "sell", OrderType: "limit", Quantity: "10", Price: "80.5", Validity: "day", ExpiryDate: "27-Jul-2023", Right: "put", StrikePrice: "19500"}},
+		{"future",
+			Recommendation{StockCode: "NIFTY", Action: "buy", ExpiryDate: "27-Jul-2023", RecommendedPriceFrom: 19550},
+			OrderRequest{StockCode: "NIFTY", ExchangeCode: "NSE", Product: "futures", Action: "buy", OrderType: "limit", Quantity: "10", Price: "19550", Validity: "day", ExpiryDate: "27-Jul-2023"}},
+	}
+	for _, tt := range tests {
+		basket, err := tt.rec.Basket("NSE", 10)
+		if err != nil || len(basket) != 1 || basket[0] != tt.want {
+			t.Errorf("%s: Basket = %+v, %v; want %+v", tt.name, basket, err, tt.want)
+		}
+	}
+
+	if _, err := (Recommendation{StockCode: "ITC", Action: "buy"}).Basket("NSE", 0); !errors.Is(err, ErrValidation) {
+		t.Errorf("Basket with no quantity: error = %v, want ErrValidation", err)
+	}
+	if _, err := (Recommendation{StockCode: "ITC", Action: "hold"}).Basket("NSE", 1); !errors.Is(err, ErrValidation) {
+		t.Errorf("Basket with an unknown action: error = %v, want ErrValidation", err)
+	}
+}
+
+func TestStrategyFeedSubscribeErrors(t *testing.T) {
+	f := NewBreezeInstance("key").EnableStrategyFeed()
+	if err := f.Subscribe("one_click_fno", "stock"); !errors.Is(err, ErrValidation) {
+		t.Errorf("Subscribe to an unknown stream: error = %v, want ErrValidation", err)
+	}
+	if err := f.Subscribe(); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Subscribe before connecting: error = %v, want ErrNotConnected", err)
+	}
+}
+
+func TestStrategyFeedSubscribeRollsBack(t *testing.T) {
+	server, b := connectTestFeed(t)
+	b.LiveFeedsURL = server.srv.URL
+	f := b.EnableStrategyFeed()
+
+	if err := f.Subscribe("one_click_fno"); err != nil {
+		t.Fatal(err)
+	}
+	server.waitConnect(t)
+	server.waitFrame(t, func(s string) bool { return s == `42["join",["one_click_fno"]]` })
+
+	// A failed call releases only its own hold on a stream held before it.
+	b.MaxFeedTokens = 1
+	err := f.Subscribe()
+	var limitErr *FeedLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Subscribe past the limit: error = %v, want a FeedLimitError", err)
+	}
+	if frames := server.framesAfter(50 * time.Millisecond); len(frames) != 0 {
+		t.Errorf("frames after a failed Subscribe = %v, want none", frames)
+	}
+	if tokens, _ := b.SIOOrderRefreshHandler.Subscriptions(); !reflect.DeepEqual(tokens, map[string]int{"one_click_fno": 1}) {
+		t.Errorf("streams held = %v, want one_click_fno once", tokens)
+	}
+
+	// A stream the failed call joined itself is left again.
+	if err := f.Unsubscribe("one_click_fno"); err != nil {
+		t.Fatal(err)
+	}
+	server.waitFrame(t, func(s string) bool { return s == `42["leave",["one_click_fno"]]` })
+	if err := f.Subscribe("one_click_fno", "i_click_2_gain"); !errors.As(err, &limitErr) {
+		t.Fatalf("Subscribe past the limit: error = %v, want a FeedLimitError", err)
+	}
+	server.waitFrame(t, func(s string) bool { return s == `42["join",["one_click_fno"]]` })
+	server.waitFrame(t, func(s string) bool { return s == `42["leave",["one_click_fno"]]` })
+	if tokens, _ := b.SIOOrderRefreshHandler.Subscriptions(); len(tokens) != 0 {
+		t.Errorf("streams held after the rollback = %v, want none", tokens)
+	}
+	if frames := server.framesAfter(20 * time.Millisecond); len(frames) != 0 {
+		t.Errorf("unexpected frames %v", frames)
+	}
+}
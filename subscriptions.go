@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// OverflowPolicy decides what happens to a tick when a subscriber's buffer
+// is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest buffered tick to make room.
+	DropOldest OverflowPolicy = iota
+	// DropNewest discards the incoming tick.
+	DropNewest
+	// Block waits for the subscriber to drain its buffer, stalling the feed
+	// for every other consumer meanwhile.
+	Block
+)
+
+const defaultSubscriberBuffer = 256
+
+type SubscribeOptions struct {
+	Buffer   int
+	Overflow OverflowPolicy
+}
+
+type tickSubscriber struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	ch       chan *Tick
+	overflow OverflowPolicy
+	tokens   []string
+	// joined counts the leading tokens whose feed SubscribeFeeds joined;
+	// only those are released on unsubscribe.
+	joined int
+	mu     sync.Mutex
+	closed bool
+}
+
+// send delivers t according to the overflow policy. The caller must hold
+// s.mu.
+func (s *tickSubscriber) send(t *Tick) {
+	switch s.overflow {
+	case Block:
+		select {
+		case s.ch <- t:
+		case <-s.ctx.Done():
+		}
+	case DropNewest:
+		select {
+		case s.ch <- t:
+		default:
+		}
+	default:
+		for {
+			select {
+			case s.ch <- t:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+			default:
+			}
+		}
+	}
+}
+
+// tickHub fans decoded ticks out to the channels returned by Subscribe.
+type tickHub struct {
+	mu      sync.RWMutex
+	byToken map[string][]*tickSubscriber
+}
+
+func newTickHub() *tickHub {
+	return &tickHub{byToken: map[string][]*tickSubscriber{}}
+}
+
+func (h *tickHub) active() bool {
+	if h == nil {
+		return false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.byToken) > 0
+}
+
+func (h *tickHub) add(s *tickSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, token := range s.tokens {
+		h.byToken[token] = append(h.byToken[token], s)
+	}
+}
+
+func (h *tickHub) remove(s *tickSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, token := range s.tokens {
+		subs := h.byToken[token]
+		for i, sub := range subs {
+			if sub == s {
+				subs = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+		if len(subs) == 0 {
+			delete(h.byToken, token)
+		} else {
+			h.byToken[token] = subs
+		}
+	}
+}
+
+// dispatch copies t once and hands the copy to every subscriber of its
+// symbol; subscribers share it and must not modify it.
+func (h *tickHub) dispatch(t *Tick) {
+	symbol := t.Symbol()
+	if symbol == "" {
+		return
+	}
+	h.mu.RLock()
+	subs := h.byToken[symbol]
+	h.mu.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+	shared := new(Tick)
+	*shared = *t
+	for _, s := range subs {
+		s.mu.Lock()
+		if !s.closed {
+			s.send(shared)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Subscribe streams the quote, depth or commodity ticks of the given feed
+// tokens ("4.1!2885", "4.2!2885") on a channel with the default buffer and
+// the DropOldest policy. See SubscribeWith.
+func (b *BreezeInstance) Subscribe(ctx context.Context, tokens ...string) (<-chan *Tick, error) {
+	return b.SubscribeWith(ctx, SubscribeOptions{}, tokens...)
+}
+
+// SubscribeWith streams the ticks of tokens until ctx is cancelled, when
+// the channel is closed. Each subscriber holds its own reference to the
+// tokens, so the feed leaves a token only after its last holder, channel or
+// callback consumer, has unsubscribed. Order and strategy ticks carry no
+// token and are only delivered to OnTypedTick.
+func (b *BreezeInstance) SubscribeWith(ctx context.Context, opts SubscribeOptions, tokens ...string) (<-chan *Tick, error) {
+	if len(tokens) == 0 {
+		return nil, &ValidationError{Message: "At least one feed token is required"}
+	}
+	if b.SIORateRefreshHandler == nil {
+		return nil, &NotConnectedError{Message: "LIVESTREAM_SOCKET_CONNECTION_DISCONNECTED"}
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = defaultSubscriberBuffer
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	seen := map[string]bool{}
+	sub := &tickSubscriber{
+		ctx:      ctx,
+		cancel:   cancel,
+		ch:       make(chan *Tick, opts.Buffer),
+		overflow: opts.Overflow,
+	}
+	for _, token := range tokens {
+		if token != "" && !seen[token] {
+			seen[token] = true
+			sub.tokens = append(sub.tokens, token)
+		}
+	}
+
+	b.ticks.add(sub)
+	for _, token := range sub.tokens {
+		if _, err := b.SubscribeFeeds(token, "", "", "", "", "", "", "", true, false, false); err != nil {
+			b.unsubscribe(sub)
+			return nil, err
+		}
+		sub.joined++
+	}
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(sub)
+	}()
+	return sub.ch, nil
+}
+
+func (b *BreezeInstance) unsubscribe(sub *tickSubscriber) {
+	// Cancelling first releases a dispatch blocked on a full channel.
+	sub.cancel()
+	sub.mu.Lock()
+	if sub.closed {
+		sub.mu.Unlock()
+		return
+	}
+	sub.closed = true
+	close(sub.ch)
+	sub.mu.Unlock()
+
+	b.ticks.remove(sub)
+	for _, token := range sub.tokens[:sub.joined] {
+		b.UnsubscribeFeeds(token, "", "", "", "", "", "", "", true, false, false)
+	}
+}
+
+// FeedSubscriptions lists what one socket connection is joined to, with
+// the number of holders of each token and OHLC room.
+type FeedSubscriptions struct {
+	Hostname string
+	Tokens   map[string]int
+	Rooms    map[string]int
+}
+
+// Subscriptions reports the subscriptions of every connected feed, for
+// debugging.
+func (b *BreezeInstance) Subscriptions() []FeedSubscriptions {
+	out := []FeedSubscriptions{}
+	for _, handler := range []*SocketEventBreeze{b.SIORateRefreshHandler, b.SIOOhlcvStreamHandler, b.SIOOrderRefreshHandler} {
+		if handler == nil {
+			continue
+		}
+		tokens, rooms := handler.Subscriptions()
+		out = append(out, FeedSubscriptions{Hostname: handler.hostname, Tokens: tokens, Rooms: rooms})
+	}
+	return out
+}
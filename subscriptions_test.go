@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func quoteTick(symbol string, ltp float64) *Tick {
+	return &Tick{Kind: TickQuote, Quote: Quote{Symbol: symbol, Last: ltp}}
+}
+
+func newTestSubscriber(buffer int, overflow OverflowPolicy, tokens ...string) *tickSubscriber {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &tickSubscriber{ctx: ctx, cancel: cancel, ch: make(chan *Tick, buffer), overflow: overflow, tokens: tokens}
+}
+
+// drain returns the prices of the ticks buffered on ch.
+func drain(ch <-chan *Tick) []float64 {
+	var got []float64
+	for {
+		select {
+		case t, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, t.Quote.Last)
+		default:
+			return got
+		}
+	}
+}
+
+func TestTickSubscriberOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow OverflowPolicy
+		want     []float64
+	}{
+		{"drop oldest", DropOldest, []float64{3, 4}},
+		{"drop newest", DropNewest, []float64{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSubscriber(2, tt.overflow)
+			for i := 1; i <= 4; i++ {
+				s.send(quoteTick("4.1!2885", float64(i)))
+			}
+			if got := drain(s.ch); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("buffered %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTickSubscriberBlock(t *testing.T) {
+	s := newTestSubscriber(1, Block)
+	s.send(quoteTick("4.1!2885", 1))
+
+	sent := make(chan struct{})
+	go func() {
+		s.send(quoteTick("4.1!2885", 2))
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("send on a full buffer did not block")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if got := (<-s.ch).Quote.Last; got != 1 {
+		t.Errorf("first tick = %v, want 1", got)
+	}
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("send still blocked after the buffer drained")
+	}
+	if got := (<-s.ch).Quote.Last; got != 2 {
+		t.Errorf("second tick = %v, want 2", got)
+	}
+
+	// Cancelling the subscriber releases a blocked send without delivering.
+	s.send(quoteTick("4.1!2885", 3))
+	released := make(chan struct{})
+	go func() {
+		s.send(quoteTick("4.1!2885", 4))
+		close(released)
+	}()
+	s.cancel()
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("cancel did not release a blocked send")
+	}
+	if got := drain(s.ch); fmt.Sprint(got) != "[3]" {
+		t.Errorf("buffered %v after cancel, want [3]", got)
+	}
+}
+
+func TestTickHubDispatch(t *testing.T) {
+	h := newTickHub()
+	reliance := newTestSubscriber(4, DropOldest, "4.1!2885")
+	both := newTestSubscriber(4, DropOldest, "4.1!2885", "4.1!1594")
+	closed := newTestSubscriber(4, DropOldest, "4.1!1594")
+	closed.closed = true
+	for _, s := range []*tickSubscriber{reliance, both, closed} {
+		h.add(s)
+	}
+
+	h.dispatch(quoteTick("4.1!2885", 2500))
+	h.dispatch(quoteTick("4.1!1594", 1400))
+	h.dispatch(quoteTick("4.1!9999", 1))
+	h.dispatch(&Tick{Kind: TickOrder})
+
+	if got := drain(reliance.ch); fmt.Sprint(got) != "[2500]" {
+		t.Errorf("2885 subscriber got %v", got)
+	}
+	if got := drain(both.ch); fmt.Sprint(got) != "[2500 1400]" {
+		t.Errorf("two-token subscriber got %v", got)
+	}
+	if got := drain(closed.ch); len(got) != 0 {
+		t.Errorf("closed subscriber got %v", got)
+	}
+
+	// The copy handed out is shared, not the caller's tick.
+	tick := quoteTick("4.1!2885", 2501)
+	h.dispatch(tick)
+	tick.Quote.Last = 0
+	if got := drain(both.ch); fmt.Sprint(got) != "[2501]" {
+		t.Errorf("dispatched tick changed with the caller's: %v", got)
+	}
+
+	h.remove(both)
+	h.remove(reliance)
+	if h.byToken["4.1!2885"] != nil || len(h.byToken["4.1!1594"]) != 1 {
+		t.Errorf("after remove: %v", h.byToken)
+	}
+	h.remove(closed)
+	if h.active() {
+		t.Error("hub still active with no subscribers")
+	}
+}
+
+// connectTestFeed connects b's quote feed to a fake socket server with
+// batching off, so every Watch and Unwatch is flushed at once.
+func connectTestFeed(t *testing.T) (*fakeSocketServer, *BreezeInstance) {
+	t.Helper()
+	server := newFakeSocketServer(t)
+	b := NewBreezeInstance("key")
+	b.LiveStreamURL = server.srv.URL
+	b.SubscribeBatchWindow = -1
+	if err := b.WSConnect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.WSDisconnect() })
+	server.waitConnect(t)
+	return server, b
+}
+
+func stockFrame(symbol string, ltp float64) string {
+	return fmt.Sprintf(`42["stock",["%s",%v,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,1700000000,20]]`, symbol, ltp)
+}
+
+func receiveTick(t *testing.T, ch <-chan *Tick) *Tick {
+	t.Helper()
+	select {
+	case tick, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed")
+		}
+		return tick
+	case <-time.After(2 * time.Second):
+		t.Fatal("no tick delivered")
+	}
+	return nil
+}
+
+func TestSubscribeDeliversAndCloses(t *testing.T) {
+	server, b := connectTestFeed(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reliance, err := b.Subscribe(ctx, "4.1!2885", "4.1!2885", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	infosys, err := b.Subscribe(context.Background(), "4.1!1594")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.waitFrame(t, func(s string) bool { return s == `42["join",["4.1!2885"]]` })
+	server.waitFrame(t, func(s string) bool { return s == `42["join",["4.1!1594"]]` })
+
+	server.send(stockFrame("4.1!1594", 1400))
+	server.send(stockFrame("4.1!2885", 2500))
+	if tick := receiveTick(t, reliance); tick.Kind != TickQuote || tick.Quote.Symbol != "4.1!2885" {
+		t.Errorf("2885 channel got %+v", tick)
+	}
+	if tick := receiveTick(t, infosys); tick.Quote.Symbol != "4.1!1594" {
+		t.Errorf("1594 channel got %+v", tick)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-reliance:
+		if ok {
+			t.Error("tick delivered after cancel")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+	server.waitFrame(t, func(s string) bool { return s == `42["leave",["4.1!2885"]]` })
+	if tokens, _ := b.SIORateRefreshHandler.Subscriptions(); tokens["4.1!2885"] != 0 || tokens["4.1!1594"] != 1 {
+		t.Errorf("tokens after cancel = %v", tokens)
+	}
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	b := NewBreezeInstance("key")
+	if _, err := b.Subscribe(context.Background()); !errors.Is(err, ErrValidation) {
+		t.Errorf("Subscribe with no tokens: error = %v, want ErrValidation", err)
+	}
+	if _, err := b.Subscribe(context.Background(), "4.1!2885"); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Subscribe before connecting: error = %v, want ErrNotConnected", err)
+	}
+	if b.ticks.active() {
+		t.Error("failed Subscribe left a subscriber behind")
+	}
+}
+
+func TestHandlerRegistry(t *testing.T) {
+	var r handlerRegistry[string]
+	var calls []string
+	removeA := r.add(func(s string) { calls = append(calls, "a:"+s) })
+	var removeB func()
+	removeB = r.add(func(s string) {
+		calls = append(calls, "b:"+s)
+		removeB()
+	})
+	r.add(func(s string) { calls = append(calls, "c:"+s) })
+
+	r.dispatch("1")
+	r.dispatch("2")
+	removeA()
+	removeA()
+	r.dispatch("3")
+	if got := strings.Join(calls, " "); got != "a:1 b:1 c:1 a:2 c:2 c:3" {
+		t.Errorf("calls = %s", got)
+	}
+	if !r.active() {
+		t.Error("registry inactive with a handler left")
+	}
+}
@@ -0,0 +1,581 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errMalformedTick = errors.New("malformed tick")
+
+// maxInternedStrings caps the decoder's string table so a feed that keeps
+// sending new strings cannot grow it without bound.
+const maxInternedStrings = 1 << 16
+
+// TickDecoder decodes raw feed messages into a Tick without reflection or
+// intermediate maps. Symbols and flags are interned, so quote, depth and
+// commodity ticks decode without allocating once their symbol has been
+// seen. A TickDecoder is not safe for concurrent use.
+type TickDecoder struct {
+	strings map[string]string
+}
+
+func NewTickDecoder() *TickDecoder {
+	return &TickDecoder{strings: map[string]string{}}
+}
+
+// Decode fills t from data, the JSON array a "stock" or "order" event
+// carries, and returns its kind. Messages of an unrecognised shape return
+// TickUnknown with no error.
+func (d *TickDecoder) Decode(data []byte, t *Tick) (TickKind, error) {
+	arr, ok := newJSONArray(data)
+	if !ok {
+		return TickUnknown, errMalformedTick
+	}
+	first, ok := arr.next()
+	if !ok || !first.str {
+		return TickUnknown, errMalformedTick
+	}
+
+	bang := bytes.IndexByte(first.raw, '!')
+	if bang < 0 {
+		rest := arr.count()
+		if rest < 0 {
+			return TickUnknown, errMalformedTick
+		}
+		n := 1 + rest
+		arr, _ = newJSONArray(data)
+		if n == 19 {
+			t.Kind = TickRecommendation
+			return t.Kind, d.decodeRecommendation(&arr, &t.Recommendation)
+		}
+		if n == 28 {
+			t.Kind = TickStrategy
+			return t.Kind, d.decodeStrategy(&arr, &t.Strategy)
+		}
+		schema, length, exact := orderSchemaFor(n)
+		if schema == nil {
+			noteUnknownOrderFrame(n, 0)
+			t.Kind = TickUnknown
+			return TickUnknown, nil
+		}
+		if !exact {
+			noteUnknownOrderFrame(n, length)
+		}
+		t.Kind = TickOrder
+		return t.Kind, d.decodeOrder(&arr, length, &t.Order)
+	}
+
+	symbol := d.intern(first.raw)
+	exchange, dataType := symbol[:bang], ""
+	if dot := strings.IndexByte(exchange, '.'); dot >= 0 {
+		exchange, dataType = exchange[:dot], exchange[dot+1:]
+	}
+	token := symbol[bang+1:]
+
+	switch {
+	case exchange == "6":
+		t.Kind = TickCommodity
+		t.Commodity.Symbol, t.Commodity.Token = symbol, token
+		return t.Kind, d.decodeCommodity(&arr, &t.Commodity)
+	case dataType == "1":
+		t.Kind = TickQuote
+		t.Quote.Symbol, t.Quote.Exchange, t.Quote.Token = symbol, exchange, token
+		return t.Kind, d.decodeQuote(&arr, &t.Quote)
+	default:
+		t.Kind = TickDepth
+		t.Depth.Symbol, t.Depth.Exchange, t.Depth.Token = symbol, exchange, token
+		return t.Kind, d.decodeDepth(&arr, exchange, &t.Depth)
+	}
+}
+
+func (d *TickDecoder) decodeQuote(arr *jsonArray, q *Quote) error {
+	var v [22]jsonValue
+	n := arr.fill(v[:])
+	if n < 11 {
+		return errMalformedTick
+	}
+	q.Open, q.Last, q.High, q.Low = v[0].float(), v[1].float(), v[2].float(), v[3].float()
+	q.Change = v[4].float()
+	q.BidPrice, q.BidQty = v[5].float(), v[6].int()
+	q.AskPrice, q.AskQty = v[7].float(), v[8].int()
+	q.LTQ, q.AvgPrice = v[9].int(), v[10].float()
+
+	// Equity quotes have 21 fields; F&O quotes insert OI and its change
+	// after the average price.
+	rest := v[11:n]
+	q.HasOI = n == 22
+	q.OI, q.ChangeOI = 0, 0
+	if q.HasOI {
+		q.OI, q.ChangeOI = rest[0].float(), rest[1].float()
+		rest = rest[2:]
+	}
+	var tail [9]jsonValue
+	copy(tail[:], rest)
+	q.TTQ, q.TotalBuyQty, q.TotalSellQty = tail[0].int(), tail[1].int(), tail[2].int()
+	q.TTV = tail[3].float()
+	q.Trend = d.internValue(tail[4])
+	q.LowerCircuit, q.UpperCircuit = tail[5].float(), tail[6].float()
+	q.LTT = tail[7].unix()
+	q.Close = tail[8].float()
+	return nil
+}
+
+func (d *TickDecoder) decodeDepth(arr *jsonArray, exchange string, dp *Depth) error {
+	ts, ok := arr.next()
+	if !ok {
+		return errMalformedTick
+	}
+	dp.Time = ts.unix()
+	levels, ok := arr.next()
+	if !ok {
+		return errMalformedTick
+	}
+	rows, ok := newJSONArray(levels.raw)
+	if !ok {
+		return errMalformedTick
+	}
+	dp.NumLevels = 0
+	for dp.NumLevels < maxDepthLevels {
+		row, ok := rows.next()
+		if !ok {
+			break
+		}
+		cells, ok := newJSONArray(row.raw)
+		if !ok {
+			return errMalformedTick
+		}
+		var c [8]jsonValue
+		cells.fill(c[:])
+		l := &dp.Levels[dp.NumLevels]
+		*l = DepthLevel{}
+		switch exchange {
+		case "1":
+			l.BuyPrice, l.BuyQty = c[0].float(), c[1].int()
+			l.SellPrice, l.SellQty = c[2].float(), c[3].int()
+		case "8":
+			l.BuyPrice, l.BuyQty, l.BuyOrders = c[0].float(), c[1].int(), c[2].int()
+			l.SellPrice, l.SellQty, l.SellOrders = c[3].float(), c[4].int(), c[5].int()
+		default:
+			l.BuyPrice, l.BuyQty, l.BuyOrders = c[0].float(), c[1].int(), c[2].int()
+			l.BuyFlag = d.internValue(c[3])
+			l.SellPrice, l.SellQty, l.SellOrders = c[4].float(), c[5].int(), c[6].int()
+			l.SellFlag = d.internValue(c[7])
+		}
+		dp.NumLevels++
+	}
+	return nil
+}
+
+func (d *TickDecoder) decodeCommodity(arr *jsonArray, c *CommodityTick) error {
+	var v [21]jsonValue
+	if arr.fill(v[:]) < len(v) {
+		return errMalformedTick
+	}
+	c.AndiOPVolume = v[0].int()
+	c.IndexFlag = d.internValue(v[2])
+	c.TTQ, c.Last, c.LTQ = v[3].int(), v[4].float(), v[5].int()
+	c.LTT = v[6].unix()
+	c.AvgPrice = v[7].float()
+	c.TotalBuyQty, c.TotalSellQty = v[8].int(), v[9].int()
+	c.Close, c.Open, c.High, c.Low = v[11].float(), v[12].float(), v[13].float(), v[14].float()
+	c.OI, c.TotalTrades = v[16].float(), v[17].int()
+	c.HighestEver, c.LowestEver, c.TTV = v[18].float(), v[19].float(), v[20].float()
+
+	c.NumLevels = 0
+	for c.NumLevels < maxDepthLevels {
+		row, ok := arr.next()
+		if !ok {
+			break
+		}
+		cells, ok := newJSONArray(row.raw)
+		if !ok {
+			return errMalformedTick
+		}
+		var r [8]jsonValue
+		cells.fill(r[:])
+		c.Levels[c.NumLevels] = DepthLevel{
+			BuyQty:     r[0].int(),
+			BuyPrice:   r[1].float(),
+			BuyOrders:  r[2].int(),
+			SellQty:    r[4].int(),
+			SellPrice:  r[5].float(),
+			SellOrders: r[6].int(),
+		}
+		c.NumLevels++
+	}
+	return nil
+}
+
+// maxOrderFields sizes the scratch array decodeOrder fills. No schema in
+// orderSchemas may be longer.
+const maxOrderFields = 48
+
+// orderLayout gives the position of each OrderUpdate field in one order
+// notification format; -1 marks a field the format lacks.
+type orderLayout struct {
+	flow, limitMarket, orderType, limitRate, product, status           int
+	optionType, strike, expiry                                         int
+	orderDate, tradeDate, reference, quantity, open, executed          int
+	cancelled, expired, disclosed, stopLoss, blocked, avgRate, channel int
+}
+
+// orderLayouts places the OrderUpdate fields in each frame of
+// orderSchemas; fields a frame lacks are -1.
+var orderLayouts = func() map[int]orderLayout {
+	layouts := map[int]orderLayout{}
+	for n, s := range orderSchemas {
+		layouts[n] = orderLayout{
+			flow:        s.index("orderFlow"),
+			limitMarket: s.index("limitMarketFlag"),
+			orderType:   s.index("orderType"),
+			limitRate:   s.index("orderLimitRate"),
+			product:     s.index("productType"),
+			status:      s.index("orderStatus"),
+			optionType:  s.index("optionType"),
+			strike:      s.index("strikePrice"),
+			expiry:      s.index("expiryDate"),
+			orderDate:   s.index("orderDate"),
+			tradeDate:   s.index("orderTradeDate", "tradeDate"),
+			reference:   s.index("orderReference"),
+			quantity:    s.index("orderQuantity", "orderTotalQuantity"),
+			open:        s.index("openQuantity"),
+			executed:    s.index("orderExecutedQuantity", "executedQuantity"),
+			cancelled:   s.index("cancelledQuantity"),
+			expired:     s.index("expiredQuantity"),
+			disclosed:   s.index("orderDisclosedQuantity"),
+			stopLoss:    s.index("orderStopLossTrigger", "stopLossTrigger"),
+			blocked:     s.index("orderAmountBlocked", "totalAmountBlocked"),
+			avgRate:     s.index("averageExecutedRate"),
+			channel:     s.index("channel"),
+		}
+	}
+	return layouts
+}()
+
+// decodeOrder reads the first n fields of an order frame, n being the
+// length of the schema it is decoded through.
+func (d *TickDecoder) decodeOrder(arr *jsonArray, n int, o *OrderUpdate) error {
+	var v [maxOrderFields]jsonValue
+	arr.fill(v[:n])
+	at := func(i int) jsonValue {
+		if i < 0 {
+			return jsonValue{}
+		}
+		return v[i]
+	}
+	layout := orderLayouts[n]
+	*o = OrderUpdate{
+		SourceNumber:        d.stringValue(v[0]),
+		Group:               d.stringValue(v[1]),
+		UserID:              d.stringValue(v[2]),
+		Key:                 d.stringValue(v[3]),
+		MessageSequence:     v[6].int(),
+		MessageDate:         d.stringValue(v[7]),
+		MessageTime:         d.stringValue(v[8]),
+		MatchAccount:        d.stringValue(v[12]),
+		ExchangeCode:        d.internValue(v[13]),
+		StockCode:           d.internValue(v[14]),
+		OrderFlow:           d.internValue(at(layout.flow)),
+		LimitMarketFlag:     d.internValue(at(layout.limitMarket)),
+		OrderType:           d.internValue(at(layout.orderType)),
+		LimitRate:           at(layout.limitRate).float(),
+		ProductType:         d.internValue(at(layout.product)),
+		OptionType:          d.internValue(at(layout.optionType)),
+		StrikePrice:         at(layout.strike).float(),
+		ExpiryDate:          d.internValue(at(layout.expiry)),
+		OrderStatus:         d.internValue(at(layout.status)),
+		OrderDate:           d.stringValue(at(layout.orderDate)),
+		TradeDate:           d.stringValue(at(layout.tradeDate)),
+		OrderReference:      d.stringValue(at(layout.reference)),
+		Quantity:            at(layout.quantity).int(),
+		OpenQuantity:        at(layout.open).int(),
+		ExecutedQuantity:    at(layout.executed).int(),
+		CancelledQuantity:   at(layout.cancelled).int(),
+		ExpiredQuantity:     at(layout.expired).int(),
+		DisclosedQuantity:   at(layout.disclosed).int(),
+		StopLossTrigger:     at(layout.stopLoss).float(),
+		AmountBlocked:       at(layout.blocked).float(),
+		AverageExecutedRate: at(layout.avgRate).float(),
+		Channel:             d.internValue(at(layout.channel)),
+	}
+	return nil
+}
+
+func (d *TickDecoder) decodeStrategy(arr *jsonArray, s *StrategyLeg) error {
+	var v [28]jsonValue
+	arr.fill(v[:])
+	*s = StrategyLeg{
+		StrategyDate:         d.stringValue(v[0]),
+		ModificationDate:     d.stringValue(v[1]),
+		PortfolioID:          d.stringValue(v[2]),
+		CallAction:           d.internValue(v[3]),
+		PortfolioName:        d.stringValue(v[4]),
+		ExchangeCode:         d.internValue(v[5]),
+		ProductType:          d.internValue(v[6]),
+		Underlying:           d.internValue(v[8]),
+		ExpiryDate:           d.internValue(v[9]),
+		OptionType:           d.internValue(v[11]),
+		StrikePrice:          v[12].float(),
+		Action:               d.internValue(v[13]),
+		RecommendedPriceFrom: v[14].float(),
+		RecommendedPriceTo:   v[15].float(),
+		MinimumLotQuantity:   v[16].int(),
+		LastTradedPrice:      v[17].float(),
+		BestBidPrice:         v[18].float(),
+		BestOfferPrice:       v[19].float(),
+		LastTradedQuantity:   v[20].int(),
+		TargetPrice:          v[21].float(),
+		ExpectedProfitPerLot: v[22].float(),
+		StopLossPrice:        v[23].float(),
+		ExpectedLossPerLot:   v[24].float(),
+		TotalMargin:          v[25].float(),
+		LegNo:                d.internValue(v[26]),
+		Status:               d.internValue(v[27]),
+	}
+	return nil
+}
+
+func (d *TickDecoder) decodeRecommendation(arr *jsonArray, r *Recommendation) error {
+	var v [19]jsonValue
+	arr.fill(v[:])
+	*r = Recommendation{
+		StockName:               d.stringValue(v[0]),
+		StockCode:               d.internValue(v[1]),
+		Action:                  d.internValue(v[2]),
+		ExpiryDate:              d.internValue(v[3]),
+		StrikePrice:             v[4].float(),
+		OptionType:              d.internValue(v[5]),
+		StockDescription:        d.stringValue(v[6]),
+		RecommendedPriceAndDate: d.stringValue(v[7]),
+		RecommendedPriceFrom:    v[8].float(),
+		RecommendedPriceTo:      v[9].float(),
+		RecommendedDate:         d.stringValue(v[10]),
+		TargetPrice:             v[11].float(),
+		StopLossPrice:           v[12].float(),
+		PartProfitPercentage:    v[13].float(),
+		ProfitPrice:             v[14].float(),
+		ExitPrice:               v[15].float(),
+		RecommendedUpdate:       d.stringValue(v[16]),
+		Status:                  d.internValue(v[17]),
+		SubscriptionType:        d.internValue(v[18]),
+	}
+	return nil
+}
+
+// intern returns b as a string, reusing the copy made the first time b was
+// seen. The map lookup with string(b) does not allocate.
+func (d *TickDecoder) intern(b []byte) string {
+	if s, ok := d.strings[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	if len(d.strings) < maxInternedStrings {
+		d.strings[s] = s
+	}
+	return s
+}
+
+// internValue is stringValue for fields drawn from a small set of values.
+func (d *TickDecoder) internValue(v jsonValue) string {
+	if v.escaped {
+		return v.unescape()
+	}
+	if len(v.raw) == 0 {
+		return ""
+	}
+	return d.intern(v.raw)
+}
+
+func (d *TickDecoder) stringValue(v jsonValue) string {
+	if v.escaped {
+		return v.unescape()
+	}
+	if len(v.raw) == 0 {
+		return ""
+	}
+	return string(v.raw)
+}
+
+// jsonValue is one array element as found in the input: the contents of a
+// string without its quotes, or the text of a number, literal or nested
+// array.
+type jsonValue struct {
+	raw     []byte
+	str     bool
+	escaped bool
+}
+
+// float reads a number sent either bare or quoted; anything else is 0.
+func (v jsonValue) float() float64 {
+	if len(v.raw) == 0 {
+		return 0
+	}
+	f, err := strconv.ParseFloat(string(v.raw), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func (v jsonValue) int() int64 {
+	if len(v.raw) == 0 {
+		return 0
+	}
+	if i, err := strconv.ParseInt(string(v.raw), 10, 64); err == nil {
+		return i
+	}
+	return int64(v.float())
+}
+
+// unix reads epoch seconds; 0 and missing values give the zero Time.
+func (v jsonValue) unix() time.Time {
+	if sec := v.int(); sec != 0 {
+		return time.Unix(sec, 0)
+	}
+	return time.Time{}
+}
+
+func (v jsonValue) unescape() string {
+	quoted := make([]byte, 0, len(v.raw)+2)
+	quoted = append(quoted, '"')
+	quoted = append(quoted, v.raw...)
+	quoted = append(quoted, '"')
+	var s string
+	json.Unmarshal(quoted, &s)
+	return s
+}
+
+// jsonArray walks the elements of a JSON array without decoding them.
+type jsonArray struct {
+	data []byte
+	pos  int
+	err  bool
+}
+
+func newJSONArray(data []byte) (jsonArray, bool) {
+	a := jsonArray{data: data}
+	a.skipSpace()
+	if a.pos >= len(data) || data[a.pos] != '[' {
+		return a, false
+	}
+	a.pos++
+	return a, true
+}
+
+func (a *jsonArray) skipSpace() {
+	for a.pos < len(a.data) {
+		switch a.data[a.pos] {
+		case ' ', '\t', '\n', '\r':
+			a.pos++
+		default:
+			return
+		}
+	}
+}
+
+// next returns the following element, or false at the end of the array or
+// on malformed input.
+func (a *jsonArray) next() (jsonValue, bool) {
+	if a.err {
+		return jsonValue{}, false
+	}
+	a.skipSpace()
+	if a.pos >= len(a.data) {
+		a.err = true
+		return jsonValue{}, false
+	}
+	switch a.data[a.pos] {
+	case ']':
+		return jsonValue{}, false
+	case ',':
+		a.pos++
+		a.skipSpace()
+	}
+	if a.pos >= len(a.data) {
+		a.err = true
+		return jsonValue{}, false
+	}
+
+	start := a.pos
+	switch a.data[a.pos] {
+	case '"':
+		v := jsonValue{str: true}
+		for a.pos++; a.pos < len(a.data); a.pos++ {
+			switch a.data[a.pos] {
+			case '\\':
+				v.escaped = true
+				a.pos++
+			case '"':
+				v.raw = a.data[start+1 : a.pos]
+				a.pos++
+				return v, true
+			}
+		}
+	case '[', '{':
+		depth := 0
+		for ; a.pos < len(a.data); a.pos++ {
+			switch a.data[a.pos] {
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					a.pos++
+					return jsonValue{raw: a.data[start:a.pos]}, true
+				}
+			case '"':
+				for a.pos++; a.pos < len(a.data) && a.data[a.pos] != '"'; a.pos++ {
+					if a.data[a.pos] == '\\' {
+						a.pos++
+					}
+				}
+			}
+		}
+	default:
+		for a.pos < len(a.data) {
+			switch a.data[a.pos] {
+			case ',', ']', ' ', '\t', '\n', '\r':
+				raw := a.data[start:a.pos]
+				if string(raw) == "null" {
+					raw = nil
+				}
+				return jsonValue{raw: raw}, true
+			}
+			a.pos++
+		}
+	}
+	a.err = true
+	return jsonValue{}, false
+}
+
+// fill reads up to len(v) elements into v and returns how many were read.
+func (a *jsonArray) fill(v []jsonValue) int {
+	for i := range v {
+		e, ok := a.next()
+		if !ok {
+			return i
+		}
+		v[i] = e
+	}
+	return len(v)
+}
+
+// count returns the number of remaining elements, or -1 if the array is
+// malformed.
+func (a *jsonArray) count() int {
+	n := 0
+	for {
+		if _, ok := a.next(); !ok {
+			break
+		}
+		n++
+	}
+	if a.err {
+		return -1
+	}
+	return n
+}
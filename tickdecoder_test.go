@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Sample feed frames as the "stock" event carries them.
+var (
+	foQuoteFrame = []byte(`["4.1!43650",182.5,176.35,190,170.1,-3.37,176.3,650,176.4,1200,50,178.92,4123450,12.5,9876500,415000,398000,1767000000.5,"",0.05,650.6,1706160600,182.5]`)
+	depthFrame   = []byte(`["4.2!2885",1706160600,[[2450.1,120,4,"N",2450.3,80,2,"N"],[2450,300,9,"N",2450.35,95,3,"N"],[2449.95,75,1,"N",2450.4,410,6,"N"],[2449.9,500,12,"N",2450.5,60,2,"N"],[2449.85,40,1,"N",2450.55,220,5,"N"]]]`)
+)
+
+func orderFrame(tb testing.TB) []byte {
+	tb.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "orders", "fno_43.json"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return raw
+}
+
+// TestDecodeDoesNotAllocate holds TickDecoder to its steady-state promise
+// once the symbols of the frames have been interned.
+func TestDecodeDoesNotAllocate(t *testing.T) {
+	frames := map[string][]byte{"quote": foQuoteFrame, "depth": depthFrame}
+	for name, frame := range frames {
+		d := NewTickDecoder()
+		var tick Tick
+		if _, err := d.Decode(frame, &tick); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		allocs := testing.AllocsPerRun(100, func() { d.Decode(frame, &tick) })
+		if allocs != 0 {
+			t.Errorf("%s: Decode allocates %v times per frame, want 0", name, allocs)
+		}
+	}
+}
+
+func benchmarkDecode(b *testing.B, frame []byte, want TickKind) {
+	d := NewTickDecoder()
+	var tick Tick
+	if kind, err := d.Decode(frame, &tick); err != nil || kind != want {
+		b.Fatalf("Decode = %v, %v; want %v", kind, err, want)
+	}
+	b.SetBytes(int64(len(frame)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Decode(frame, &tick)
+	}
+}
+
+func BenchmarkDecodeQuote(b *testing.B) { benchmarkDecode(b, foQuoteFrame, TickQuote) }
+func BenchmarkDecodeDepth(b *testing.B) { benchmarkDecode(b, depthFrame, TickDepth) }
+func BenchmarkDecodeOrder(b *testing.B) { benchmarkDecode(b, orderFrame(b), TickOrder) }
+
+// benchmarkParseData measures the map-based path onMessage takes for
+// OnTicks: unmarshalling the frame and building the tick map.
+func benchmarkParseData(b *testing.B, frame []byte) {
+	breeze := NewBreezeInstance("")
+	var fields []interface{}
+	if err := json.Unmarshal(frame, &fields); err != nil || breeze.parseData(fields) == nil {
+		b.Fatalf("frame does not parse: %v", err)
+	}
+	b.SetBytes(int64(len(frame)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var fields []interface{}
+		json.Unmarshal(frame, &fields)
+		breeze.parseData(fields)
+	}
+}
+
+func BenchmarkParseDataQuote(b *testing.B) { benchmarkParseData(b, foQuoteFrame) }
+func BenchmarkParseDataDepth(b *testing.B) { benchmarkParseData(b, depthFrame) }
+func BenchmarkParseDataOrder(b *testing.B) { benchmarkParseData(b, orderFrame(b)) }
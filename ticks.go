@@ -0,0 +1,232 @@
+package main
+
+import "time"
+
+type TickKind uint8
+
+const (
+	TickUnknown TickKind = iota
+	TickQuote
+	TickDepth
+	TickCommodity
+	TickOrder
+	TickStrategy
+	TickRecommendation
+)
+
+func (k TickKind) String() string {
+	switch k {
+	case TickQuote:
+		return "quote"
+	case TickDepth:
+		return "depth"
+	case TickCommodity:
+		return "commodity"
+	case TickOrder:
+		return "order"
+	case TickStrategy:
+		return "strategy"
+	case TickRecommendation:
+		return "recommendation"
+	}
+	return "unknown"
+}
+
+// maxDepthLevels bounds the levels a Depth or CommodityTick carries; deeper
+// levels in a feed message are dropped.
+const maxDepthLevels = 20
+
+// Quote is an exchange quote tick ("4.1!2885"). OI and ChangeOI are only
+// sent for F&O contracts, which HasOI reports.
+type Quote struct {
+	Symbol       string
+	Exchange     string
+	Token        string
+	Open         float64
+	Last         float64
+	High         float64
+	Low          float64
+	Change       float64
+	BidPrice     float64
+	BidQty       int64
+	AskPrice     float64
+	AskQty       int64
+	LTQ          int64
+	AvgPrice     float64
+	TTQ          int64
+	TotalBuyQty  int64
+	TotalSellQty int64
+	TTV          float64
+	Trend        string
+	LowerCircuit float64
+	UpperCircuit float64
+	LTT          time.Time
+	Close        float64
+	OI           float64
+	ChangeOI     float64
+	HasOI        bool
+}
+
+type DepthLevel struct {
+	BuyPrice   float64
+	BuyQty     int64
+	BuyOrders  int64
+	BuyFlag    string
+	SellPrice  float64
+	SellQty    int64
+	SellOrders int64
+	SellFlag   string
+}
+
+// Depth is a market depth tick ("4.2!2885"). Only Levels[:NumLevels] are
+// valid; the array is reused between ticks.
+type Depth struct {
+	Symbol    string
+	Exchange  string
+	Token     string
+	Time      time.Time
+	Levels    [maxDepthLevels]DepthLevel
+	NumLevels int
+}
+
+// CommodityTick is the combined quote and depth tick MCX sends ("6.1!...").
+type CommodityTick struct {
+	Symbol       string
+	Token        string
+	AndiOPVolume int64
+	IndexFlag    string
+	TTQ          int64
+	Last         float64
+	LTQ          int64
+	LTT          time.Time
+	AvgPrice     float64
+	TotalBuyQty  int64
+	TotalSellQty int64
+	Close        float64
+	Open         float64
+	High         float64
+	Low          float64
+	OI           float64
+	TotalTrades  int64
+	HighestEver  float64
+	LowestEver   float64
+	TTV          float64
+	Levels       [maxDepthLevels]DepthLevel
+	NumLevels    int
+}
+
+// OrderUpdate is an order notification. The flow, type, product and status
+// fields hold the raw Tux codes the feed sends.
+type OrderUpdate struct {
+	SourceNumber        string
+	Group               string
+	UserID              string
+	Key                 string
+	MessageSequence     int64
+	MessageDate         string
+	MessageTime         string
+	MatchAccount        string
+	ExchangeCode        string
+	StockCode           string
+	OrderFlow           string
+	LimitMarketFlag     string
+	OrderType           string
+	LimitRate           float64
+	ProductType         string
+	OptionType          string
+	StrikePrice         float64
+	ExpiryDate          string
+	OrderStatus         string
+	OrderDate           string
+	TradeDate           string
+	OrderReference      string
+	Quantity            int64
+	OpenQuantity        int64
+	ExecutedQuantity    int64
+	CancelledQuantity   int64
+	ExpiredQuantity     int64
+	DisclosedQuantity   int64
+	StopLossTrigger     float64
+	AmountBlocked       float64
+	AverageExecutedRate float64
+	Channel             string
+}
+
+// StrategyLeg is a strategy alert: one leg of a one-click F&O strategy
+// from the one_click_fno stream. Legs of a strategy share PortfolioID.
+type StrategyLeg struct {
+	StrategyDate         string
+	ModificationDate     string
+	PortfolioID          string
+	CallAction           string
+	PortfolioName        string
+	ExchangeCode         string
+	ProductType          string
+	Underlying           string
+	ExpiryDate           string
+	OptionType           string
+	StrikePrice          float64
+	Action               string
+	RecommendedPriceFrom float64
+	RecommendedPriceTo   float64
+	MinimumLotQuantity   int64
+	LastTradedPrice      float64
+	BestBidPrice         float64
+	BestOfferPrice       float64
+	LastTradedQuantity   int64
+	TargetPrice          float64
+	ExpectedProfitPerLot float64
+	StopLossPrice        float64
+	ExpectedLossPerLot   float64
+	TotalMargin          float64
+	LegNo                string
+	Status               string
+}
+
+// Recommendation is an iClick-2-Gain call from the i_click_2_gain stream.
+type Recommendation struct {
+	StockName               string
+	StockCode               string
+	Action                  string
+	ExpiryDate              string
+	StrikePrice             float64
+	OptionType              string
+	StockDescription        string
+	RecommendedPriceAndDate string
+	RecommendedPriceFrom    float64
+	RecommendedPriceTo      float64
+	RecommendedDate         string
+	TargetPrice             float64
+	StopLossPrice           float64
+	PartProfitPercentage    float64
+	ProfitPrice             float64
+	ExitPrice               float64
+	RecommendedUpdate       string
+	Status                  string
+	SubscriptionType        string
+}
+
+// Tick holds one decoded feed message; Kind says which member is set. A
+// Tick is meant to be reused across Decode calls.
+type Tick struct {
+	Kind           TickKind
+	Quote          Quote
+	Depth          Depth
+	Commodity      CommodityTick
+	Order          OrderUpdate
+	Strategy       StrategyLeg
+	Recommendation Recommendation
+}
+
+// Symbol returns the feed symbol of quote, depth and commodity ticks.
+func (t *Tick) Symbol() string {
+	switch t.Kind {
+	case TickQuote:
+		return t.Quote.Symbol
+	case TickDepth:
+		return t.Depth.Symbol
+	case TickCommodity:
+		return t.Commodity.Symbol
+	}
+	return ""
+}
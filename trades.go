@@ -0,0 +1,61 @@
+package main
+
+import "strings"
+
+type Trade struct {
+	MatchAccount         string `json:"match_account"`
+	OrderID              string `json:"order_id"`
+	ExchangeTradeID      string `json:"exchange_trade_id"`
+	ExchangeCode         string `json:"exchange_code"`
+	StockCode            string `json:"stock_code"`
+	ProductType          string `json:"product_type"`
+	Action               string `json:"action"`
+	Quantity             Int    `json:"quantity"`
+	ExecutedQuantity     Int    `json:"executed_quantity"`
+	AverageCost          Float  `json:"average_cost"`
+	TradePrice           Float  `json:"trade_price"`
+	BrokerageAmount      Float  `json:"brokerage_amount"`
+	TotalTaxes           Float  `json:"total_taxes"`
+	TotalTransactionCost Float  `json:"total_transaction_cost"`
+	EqCost               Float  `json:"eq_cost"`
+	SettlementID         string `json:"settlement_id"`
+	TradeDate            string `json:"trade_date"`
+	ExecutionTime        string `json:"execution_time"`
+	ExpiryDate           string `json:"expiry_date"`
+	Right                string `json:"right"`
+	StrikePrice          Float  `json:"strike_price"`
+}
+
+// GetTradeList returns the executed trades between fromDate and toDate.
+// productType, action and stockCode narrow the list when set.
+func (a *ApificationBreeze) GetTradeList(fromDate, toDate, exchangeCode, productType, action, stockCode string) ([]Trade, error) {
+	if err := validateOrderList(exchangeCode, fromDate, toDate); err != nil {
+		return nil, err
+	}
+	productType = strings.ToLower(productType)
+	action = strings.ToLower(action)
+	if productType != "" && !contains(PRODUCT_TYPES, productType) {
+		return nil, NewValidationError("Product type should be one of " + strings.Join(PRODUCT_TYPES, ", "))
+	}
+	if action != "" && !contains(ACTION_TYPES, action) {
+		return nil, NewValidationError("Action should be one of " + strings.Join(ACTION_TYPES, ", "))
+	}
+
+	body := map[string]string{
+		"from_date":     fromDate,
+		"to_date":       toDate,
+		"exchange_code": exchangeCode,
+		"product_type":  productType,
+		"action":        action,
+		"stock_code":    stockCode,
+	}
+	return callAPI[[]Trade](a, "GET", "/"+string(TRADE), body)
+}
+
+// GetTradeDetail returns the fills of one order.
+func (a *ApificationBreeze) GetTradeDetail(exchangeCode, orderID string) ([]Trade, error) {
+	if exchangeCode == "" || orderID == "" {
+		return nil, NewValidationError("Exchange code or order ID cannot be empty")
+	}
+	return callAPI[[]Trade](a, "GET", "/"+string(TRADE), orderIDBody(exchangeCode, orderID))
+}
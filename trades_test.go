@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+const tradesResponse = `{"Success":[
+	{"order_id":"20230720N100000001","exchange_trade_id":"5001","exchange_code":"NSE","stock_code":"ITC","product_type":"Cash","action":"Buy","quantity":"10","average_cost":"420.5","brokerage_amount":"2.1","settlement_id":"2023137"},
+	{"order_id":"20230720N100000001","exchange_trade_id":"5002","exchange_code":"NSE","stock_code":"ITC","product_type":"Cash","action":"Buy","quantity":5,"average_cost":420.55,"brokerage_amount":"1.05","settlement_id":"2023137"}
+],"Status":200,"Error":null}`
+
+func TestGetTradeList(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	f.respond(http.StatusOK, tradesResponse)
+
+	trades, err := a.GetTradeList("2023-07-20T00:00:00.000Z", "2023-07-21T00:00:00.000Z", "NSE", "Cash", "Buy", "ITC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 2 || trades[0].Quantity != 10 || trades[1].Quantity != 5 || trades[1].AverageCost != 420.55 || trades[0].ExchangeTradeID != "5001" {
+		t.Errorf("trades = %+v", trades)
+	}
+	call := f.last(t)
+	if call.Method != "GET" || call.Path != "/trades" {
+		t.Errorf("request = %s %s, want GET /trades", call.Method, call.Path)
+	}
+	checkSigned(t, call)
+	checkBody(t, call, map[string]string{
+		"from_date":     "2023-07-20T00:00:00.000Z",
+		"to_date":       "2023-07-21T00:00:00.000Z",
+		"exchange_code": "NSE",
+		"product_type":  "cash",
+		"action":        "buy",
+		"stock_code":    "ITC",
+	})
+}
+
+func TestGetTradeListValidation(t *testing.T) {
+	tests := []struct {
+		name                                                      string
+		fromDate, toDate, exchangeCode, productType, action, code string
+	}{
+		{"missing exchange", "2023-07-20", "2023-07-21", "", "", "", ""},
+		{"missing from date", "", "2023-07-21", "NSE", "", "", ""},
+		{"missing to date", "2023-07-20", "", "NSE", "", "", ""},
+		{"unknown product", "2023-07-20", "2023-07-21", "NSE", "delivery", "", ""},
+		{"unknown action", "2023-07-20", "2023-07-21", "NSE", "", "short", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, a := newFakeBreezeAPI(t)
+			if _, err := a.GetTradeList(tt.fromDate, tt.toDate, tt.exchangeCode, tt.productType, tt.action, tt.code); !errors.Is(err, ErrValidation) {
+				t.Errorf("GetTradeList error = %v, want ErrValidation", err)
+			}
+			if calls := f.received(); len(calls) != 0 {
+				t.Errorf("invalid request reached the API: %v", calls)
+			}
+		})
+	}
+}
+
+func TestGetTradeDetail(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	f.respond(http.StatusOK, tradesResponse)
+
+	trades, err := a.GetTradeDetail("NSE", "20230720N100000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 2 || trades[0].OrderID != "20230720N100000001" || trades[1].BrokerageAmount != 1.05 {
+		t.Errorf("trades = %+v", trades)
+	}
+	call := f.last(t)
+	if call.Method != "GET" || call.Path != "/trades" {
+		t.Errorf("request = %s %s, want GET /trades", call.Method, call.Path)
+	}
+	checkSigned(t, call)
+	checkBody(t, call, map[string]string{"exchange_code": "NSE", "order_id": "20230720N100000001"})
+
+	for _, args := range [][2]string{{"", "1"}, {"NSE", ""}} {
+		if _, err := a.GetTradeDetail(args[0], args[1]); !errors.Is(err, ErrValidation) {
+			t.Errorf("GetTradeDetail(%q, %q) error = %v, want ErrValidation", args[0], args[1], err)
+		}
+	}
+	if calls := f.received(); len(calls) != 1 {
+		t.Errorf("%d requests, want the invalid lookups refused locally", len(calls))
+	}
+}
+
+func TestGetTradeListAPIError(t *testing.T) {
+	f, a := newFakeBreezeAPI(t)
+	f.respond(http.StatusOK, `{"Success":null,"Status":500,"Error":"No Data Found"}`)
+	trades, err := a.GetTradeList("2023-07-20", "2023-07-21", "NSE", "", "", "")
+	if !errors.Is(err, ErrAPI) || trades != nil {
+		t.Errorf("GetTradeList = %v, %v; want nil and ErrAPI", trades, err)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownTuxCode matches UnknownTuxCodeError.
+var ErrUnknownTuxCode = errors.New("unknown tux code")
+
+// UnknownTuxCodeError reports a code missing from the tables, usually one
+// added by the broker after Version.
+type UnknownTuxCodeError struct {
+	Field   string
+	Code    string
+	Version string
+}
+
+func (e *UnknownTuxCodeError) Error() string {
+	return fmt.Sprintf("unknown %s code %q in tux tables version %s", e.Field, e.Code, e.Version)
+}
+
+func (e *UnknownTuxCodeError) Is(target error) bool {
+	return target == ErrUnknownTuxCode
+}
+
+// TuxTables translates the single-letter codes of order notifications into
+// the values the REST API uses. Version changes whenever a code is added or
+// renamed, so an unknown code can be traced to a stale table.
+type TuxTables struct {
+	Version string
+	Values  map[string]map[string]string
+}
+
+var DefaultTuxTables = TuxTables{
+	Version: "2023.1",
+	Values: map[string]map[string]string{
+		"orderFlow": {
+			"B": "Buy",
+			"S": "Sell",
+			"N": "NA",
+		},
+		"limitMarketFlag": {
+			"L": "Limit",
+			"M": "Market",
+			"S": "StopLoss",
+		},
+		"orderType": {
+			"T": "Day",
+			"I": "IoC",
+			"V": "VTC",
+		},
+		"productType": {
+			"F": "Futures",
+			"O": "Options",
+			"P": "FuturePlus",
+			"U": "FuturePlus_sltp",
+			"I": "OptionPlus",
+			"C": "Cash",
+			"Y": "eATM",
+			"B": "BTST",
+			"M": "Margin",
+			"T": "MarginPlus",
+		},
+		"orderStatus": {
+			"A": "All",
+			"R": "Requested",
+			"Q": "Queued",
+			"O": "Ordered",
+			"P": "Partially Executed",
+			"E": "Executed",
+			"J": "Rejected",
+			"X": "Expired",
+			"B": "Partially Executed And Expired",
+			"D": "Partially Executed And Cancelled",
+			"F": "Freezed",
+			"C": "Cancelled",
+		},
+		"optionType": {
+			"C": "Call",
+			"P": "Put",
+			"*": "Others",
+		},
+	},
+}
+
+// Lookup returns the user value of code in field.
+func (t TuxTables) Lookup(field, code string) (string, error) {
+	if value, ok := t.Values[field][strings.TrimSpace(code)]; ok {
+		return value, nil
+	}
+	return "", &UnknownTuxCodeError{Field: field, Code: code, Version: t.Version}
+}
+
+// copyValues returns a deep copy, so a BreezeInstance can amend its
+// TuxToUserValue without touching DefaultTuxTables.
+func (t TuxTables) copyValues() map[string]map[string]string {
+	copied := make(map[string]map[string]string, len(t.Values))
+	for field, values := range t.Values {
+		copied[field] = copyMessages(values)
+	}
+	return copied
+}
+
+// tuxValue translates a code from an order message through TuxToUserValue.
+// An unknown or non-string code is passed through as received rather than
+// blanked, so the information is not lost.
+func (b *BreezeInstance) tuxValue(field string, code interface{}) interface{} {
+	s, ok := code.(string)
+	if !ok {
+		return code
+	}
+	if value, ok := b.TuxToUserValue[field][strings.TrimSpace(s)]; ok {
+		return value
+	}
+	return s
+}
+
+func tuxString(field, code string) string {
+	if value, err := DefaultTuxTables.Lookup(field, code); err == nil {
+		return value
+	}
+	return "Unknown(" + code + ")"
+}
+
+// The enums below hold the raw Tux code; String returns the user value and
+// Valid reports whether the code is in DefaultTuxTables.
+
+type OrderFlow string
+
+const (
+	OrderFlowBuy  OrderFlow = "B"
+	OrderFlowSell OrderFlow = "S"
+	OrderFlowNA   OrderFlow = "N"
+)
+
+func (f OrderFlow) String() string { return tuxString("orderFlow", string(f)) }
+func (f OrderFlow) Valid() bool {
+	_, err := DefaultTuxTables.Lookup("orderFlow", string(f))
+	return err == nil
+}
+
+type LimitMarketFlag string
+
+const (
+	LimitMarketLimit    LimitMarketFlag = "L"
+	LimitMarketMarket   LimitMarketFlag = "M"
+	LimitMarketStopLoss LimitMarketFlag = "S"
+)
+
+func (f LimitMarketFlag) String() string { return tuxString("limitMarketFlag", string(f)) }
+func (f LimitMarketFlag) Valid() bool {
+	_, err := DefaultTuxTables.Lookup("limitMarketFlag", string(f))
+	return err == nil
+}
+
+// OrderValidity is the orderType field of order messages.
+type OrderValidity string
+
+const (
+	OrderValidityDay OrderValidity = "T"
+	OrderValidityIoC OrderValidity = "I"
+	OrderValidityVTC OrderValidity = "V"
+)
+
+func (v OrderValidity) String() string { return tuxString("orderType", string(v)) }
+func (v OrderValidity) Valid() bool {
+	_, err := DefaultTuxTables.Lookup("orderType", string(v))
+	return err == nil
+}
+
+type ProductCode string
+
+const (
+	ProductFutures        ProductCode = "F"
+	ProductOptions        ProductCode = "O"
+	ProductFuturePlus     ProductCode = "P"
+	ProductFuturePlusSLTP ProductCode = "U"
+	ProductOptionPlus     ProductCode = "I"
+	ProductCash           ProductCode = "C"
+	ProductEATM           ProductCode = "Y"
+	ProductBTST           ProductCode = "B"
+	ProductMargin         ProductCode = "M"
+	ProductMarginPlus     ProductCode = "T"
+)
+
+func (p ProductCode) String() string { return tuxString("productType", string(p)) }
+func (p ProductCode) Valid() bool {
+	_, err := DefaultTuxTables.Lookup("productType", string(p))
+	return err == nil
+}
+
+type OrderStatus string
+
+const (
+	OrderStatusAll                           OrderStatus = "A"
+	OrderStatusRequested                     OrderStatus = "R"
+	OrderStatusQueued                        OrderStatus = "Q"
+	OrderStatusOrdered                       OrderStatus = "O"
+	OrderStatusPartiallyExecuted             OrderStatus = "P"
+	OrderStatusExecuted                      OrderStatus = "E"
+	OrderStatusRejected                      OrderStatus = "J"
+	OrderStatusExpired                       OrderStatus = "X"
+	OrderStatusPartiallyExecutedAndExpired   OrderStatus = "B"
+	OrderStatusPartiallyExecutedAndCancelled OrderStatus = "D"
+	OrderStatusFreezed                       OrderStatus = "F"
+	OrderStatusCancelled                     OrderStatus = "C"
+)
+
+func (s OrderStatus) String() string { return tuxString("orderStatus", string(s)) }
+func (s OrderStatus) Valid() bool {
+	_, err := DefaultTuxTables.Lookup("orderStatus", string(s))
+	return err == nil
+}
+
+type OptionType string
+
+const (
+	OptionTypeCall   OptionType = "C"
+	OptionTypePut    OptionType = "P"
+	OptionTypeOthers OptionType = "*"
+)
+
+func (o OptionType) String() string { return tuxString("optionType", string(o)) }
+func (o OptionType) Valid() bool {
+	_, err := DefaultTuxTables.Lookup("optionType", string(o))
+	return err == nil
+}
+
+func (u *OrderUpdate) Flow() OrderFlow              { return OrderFlow(u.OrderFlow) }
+func (u *OrderUpdate) LimitMarket() LimitMarketFlag { return LimitMarketFlag(u.LimitMarketFlag) }
+func (u *OrderUpdate) Validity() OrderValidity      { return OrderValidity(u.OrderType) }
+func (u *OrderUpdate) Product() ProductCode         { return ProductCode(u.ProductType) }
+func (u *OrderUpdate) Status() OrderStatus          { return OrderStatus(u.OrderStatus) }
+func (u *OrderUpdate) Option() OptionType           { return OptionType(u.OptionType) }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTuxTablesLookup(t *testing.T) {
+	tests := []struct {
+		field, code string
+		want        string
+	}{
+		{"orderFlow", "B", "Buy"},
+		{"limitMarketFlag", "S", "StopLoss"},
+		{"orderType", "I", "IoC"},
+		{"productType", "U", "FuturePlus_sltp"},
+		{"orderStatus", " D ", "Partially Executed And Cancelled"},
+		{"optionType", "*", "Others"},
+	}
+	for _, tt := range tests {
+		got, err := DefaultTuxTables.Lookup(tt.field, tt.code)
+		if err != nil || got != tt.want {
+			t.Errorf("Lookup(%s, %q) = %q, %v; want %q", tt.field, tt.code, got, err, tt.want)
+		}
+	}
+
+	for _, tc := range [][2]string{{"orderFlow", "Z"}, {"orderFlow", ""}, {"settlement", "B"}} {
+		_, err := DefaultTuxTables.Lookup(tc[0], tc[1])
+		if !errors.Is(err, ErrUnknownTuxCode) {
+			t.Errorf("Lookup(%s, %q): error = %v, want ErrUnknownTuxCode", tc[0], tc[1], err)
+		}
+		var unknown *UnknownTuxCodeError
+		if !errors.As(err, &unknown) || unknown.Field != tc[0] || unknown.Code != tc[1] || unknown.Version != DefaultTuxTables.Version {
+			t.Errorf("Lookup(%s, %q): error = %#v", tc[0], tc[1], err)
+		}
+	}
+}
+
+func TestUnknownTuxCodeErrorWrapped(t *testing.T) {
+	_, err := DefaultTuxTables.Lookup("orderStatus", "K")
+	wrapped := fmt.Errorf("order 20230720N100000001: %w", err)
+	if !errors.Is(wrapped, ErrUnknownTuxCode) {
+		t.Errorf("errors.Is(%v, ErrUnknownTuxCode) = false", wrapped)
+	}
+	if errors.Is(wrapped, ErrValidation) {
+		t.Error("an unknown tux code matched ErrValidation")
+	}
+	if want := `unknown orderStatus code "K" in tux tables version 2023.1`; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestTuxEnums(t *testing.T) {
+	tests := []struct {
+		value fmt.Stringer
+		valid bool
+		want  string
+	}{
+		{OrderFlowSell, true, "Sell"},
+		{OrderFlowNA, true, "NA"},
+		{OrderFlow("X"), false, "Unknown(X)"},
+		{LimitMarketMarket, true, "Market"},
+		{LimitMarketFlag(""), false, "Unknown()"},
+		{OrderValidityVTC, true, "VTC"},
+		{OrderValidity("D"), false, "Unknown(D)"},
+		{ProductEATM, true, "eATM"},
+		{ProductMarginPlus, true, "MarginPlus"},
+		{ProductCode("Z"), false, "Unknown(Z)"},
+		{OrderStatusFreezed, true, "Freezed"},
+		{OrderStatusPartiallyExecutedAndExpired, true, "Partially Executed And Expired"},
+		{OrderStatus("K"), false, "Unknown(K)"},
+		{OptionTypePut, true, "Put"},
+		{OptionType("CE"), false, "Unknown(CE)"},
+	}
+	for _, tt := range tests {
+		valid := tt.value.(interface{ Valid() bool }).Valid()
+		if got := tt.value.String(); got != tt.want || valid != tt.valid {
+			t.Errorf("%#v: String, Valid = %q, %v; want %q, %v", tt.value, got, valid, tt.want, tt.valid)
+		}
+	}
+}
+
+func TestOrderUpdateEnums(t *testing.T) {
+	u := &OrderUpdate{
+		OrderFlow:       "B",
+		LimitMarketFlag: "L",
+		OrderType:       "T",
+		ProductType:     "O",
+		OrderStatus:     "P",
+		OptionType:      "C",
+	}
+	if u.Flow() != OrderFlowBuy || u.LimitMarket() != LimitMarketLimit || u.Validity() != OrderValidityDay ||
+		u.Product() != ProductOptions || u.Status() != OrderStatusPartiallyExecuted || u.Option() != OptionTypeCall {
+		t.Errorf("enums = %v %v %v %v %v %v", u.Flow(), u.LimitMarket(), u.Validity(), u.Product(), u.Status(), u.Option())
+	}
+}
+
+func TestTuxValue(t *testing.T) {
+	b := NewBreezeInstance("key")
+	b.TuxToUserValue["orderFlow"]["B"] = "BUY"
+	if got := DefaultTuxTables.Values["orderFlow"]["B"]; got != "Buy" {
+		t.Fatalf("amending an instance changed DefaultTuxTables: %q", got)
+	}
+
+	tests := []struct {
+		field string
+		code  interface{}
+		want  interface{}
+	}{
+		{"orderFlow", "B", "BUY"},
+		{"orderStatus", "E ", "Executed"},
+		{"orderStatus", "K", "K"},
+		{"unknownField", "B", "B"},
+		{"orderFlow", 1.0, 1.0},
+		{"orderFlow", nil, nil},
+	}
+	for _, tt := range tests {
+		if got := b.tuxValue(tt.field, tt.code); got != tt.want {
+			t.Errorf("tuxValue(%s, %#v) = %#v, want %#v", tt.field, tt.code, got, tt.want)
+		}
+	}
+}